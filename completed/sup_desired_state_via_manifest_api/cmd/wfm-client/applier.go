@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"skeleton/pkg/common"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	applierDryRun = "dry-run"
+	applierExec   = "exec"
+	applierHelm   = "helm"
+
+	// maxCommandOutputBytes bounds the output of applier commands quoted in errors, and thereby
+	// in status reports.
+	maxCommandOutputBytes = 512
+)
+
+// Applier applies deployments of one deploymentProfile.type to the local runtime. Every
+// call receives the complete descriptor; Undeploy receives the last applied one.
+type Applier interface {
+	Deploy(ctx context.Context, d appliedDeployment) error
+	// Update replaces the previously applied deployment, whose descriptor is nil when unknown.
+	Update(ctx context.Context, previous, d appliedDeployment) error
+	Undeploy(ctx context.Context, d appliedDeployment) error
+	// Status returns the deployment state of an applied deployment as reported by the runtime.
+	Status(ctx context.Context, d appliedDeployment) (string, error)
+}
+
+type appliedDeployment struct {
+	ID         string
+	Digest     string
+	Descriptor *common.ApplicationDeploymentDescriptor
+}
+
+func (d appliedDeployment) profileType() string {
+	if d.Descriptor == nil {
+		return ""
+	}
+	return d.Descriptor.Spec.DeploymentProfile.Type
+}
+
+type applierOptions struct {
+	ExecHook        string // command run by the exec applier
+	ExecHookTimeout time.Duration
+	HelmBinary      string // helm executable run by the helm applier
+	HelmKubeContext string // kubeconfig context passed to helm, when set
+	DeviceID        string // passed to the exec hook
+}
+
+// applierSet selects the Applier of a deployment by its deploymentProfile.type.
+type applierSet struct {
+	byType   map[string]Applier
+	fallback Applier
+}
+
+// newApplierSet builds the appliers from TYPE=APPLIER mappings; profile types without a
+// mapping are applied by the fallback applier.
+func newApplierSet(mappings []string, fallback string, opts applierOptions) (applierSet, error) {
+	set := applierSet{byType: make(map[string]Applier, len(mappings))}
+	var err error
+	if set.fallback, err = newApplier(fallback, opts); err != nil {
+		return applierSet{}, err
+	}
+	for _, mapping := range mappings {
+		profileType, name, ok := strings.Cut(mapping, "=")
+		if !ok || profileType == "" {
+			return applierSet{}, fmt.Errorf("invalid applier mapping %q, expected TYPE=APPLIER", mapping)
+		}
+		if set.byType[profileType], err = newApplier(name, opts); err != nil {
+			return applierSet{}, err
+		}
+	}
+	return set, nil
+}
+
+func newApplier(name string, opts applierOptions) (Applier, error) {
+	switch name {
+	case applierDryRun:
+		return dryRunApplier{}, nil
+	case applierExec:
+		if opts.ExecHook == "" {
+			return nil, errors.New("the exec applier requires --exec-hook")
+		}
+		return execApplier{command: opts.ExecHook, timeout: opts.ExecHookTimeout, deviceID: opts.DeviceID}, nil
+	case applierHelm:
+		return helmApplier{binary: opts.HelmBinary, kubeContext: opts.HelmKubeContext}, nil
+	default:
+		return nil, fmt.Errorf("unknown applier %q", name)
+	}
+}
+
+func (as applierSet) forDeployment(d appliedDeployment) Applier {
+	if a, ok := as.byType[d.profileType()]; ok {
+		return a
+	}
+	return as.fallback
+}
+
+// dryRunApplier only logs what it would apply and reports every deployment as installed.
+type dryRunApplier struct{}
+
+func (dryRunApplier) Deploy(_ context.Context, d appliedDeployment) error {
+	infof("dry-run deploy deploymentId=%s type=%s components=%v", d.ID, d.profileType(), componentNames(d.Descriptor))
+	return nil
+}
+
+func (dryRunApplier) Update(_ context.Context, _, d appliedDeployment) error {
+	infof("dry-run update deploymentId=%s type=%s components=%v", d.ID, d.profileType(), componentNames(d.Descriptor))
+	return nil
+}
+
+func (dryRunApplier) Undeploy(_ context.Context, d appliedDeployment) error {
+	infof("dry-run undeploy deploymentId=%s type=%s components=%v", d.ID, d.profileType(), componentNames(d.Descriptor))
+	return nil
+}
+
+func (dryRunApplier) Status(context.Context, appliedDeployment) (string, error) {
+	return common.DeploymentStateInstalled, nil
+}
+
+// execApplier runs a local command for every action as
+//
+//	<command> deploy|update|undeploy|status <deploymentId>
+//
+// with the descriptor as YAML on stdin. A non-zero exit status fails the action. For status
+// the command prints the deployment state on stdout; empty output means Installed.
+type execApplier struct {
+	command  string
+	timeout  time.Duration
+	deviceID string
+}
+
+func (e execApplier) Deploy(ctx context.Context, d appliedDeployment) error {
+	_, err := e.run(ctx, "deploy", d)
+	return err
+}
+
+func (e execApplier) Update(ctx context.Context, _, d appliedDeployment) error {
+	_, err := e.run(ctx, "update", d)
+	return err
+}
+
+func (e execApplier) Undeploy(ctx context.Context, d appliedDeployment) error {
+	_, err := e.run(ctx, "undeploy", d)
+	return err
+}
+
+func (e execApplier) Status(ctx context.Context, d appliedDeployment) (string, error) {
+	out, err := e.run(ctx, "status", d)
+	if err != nil {
+		return "", err
+	}
+	state := strings.TrimSpace(string(out))
+	switch state {
+	case "":
+		return common.DeploymentStateInstalled, nil
+	case common.DeploymentStatePending, common.DeploymentStateInstalling, common.DeploymentStateInstalled, common.DeploymentStateFailed:
+		return state, nil
+	default:
+		return "", fmt.Errorf("exec hook reported unknown state %q", truncate(state, maxCommandOutputBytes))
+	}
+}
+
+func (e execApplier) run(ctx context.Context, action string, d appliedDeployment) ([]byte, error) {
+	var stdin []byte
+	if d.Descriptor != nil {
+		var err error
+		if stdin, err = yaml.Marshal(d.Descriptor); err != nil {
+			return nil, fmt.Errorf("descriptor marshal failed: %w", err)
+		}
+	}
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.command, action, d.ID)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"WFM_ACTION="+action,
+		"WFM_DEVICE_ID="+e.deviceID,
+		"WFM_DEPLOYMENT_ID="+d.ID,
+		"WFM_DIGEST="+d.Digest,
+		"WFM_PROFILE_TYPE="+d.profileType(),
+	)
+	tracef("exec hook command=%s action=%s deploymentId=%s", e.command, action, d.ID)
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("exec hook %s: %w: %s", action, err, truncate(msg, maxCommandOutputBytes))
+		}
+		return nil, fmt.Errorf("exec hook %s: %w", action, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+func componentNames(desc *common.ApplicationDeploymentDescriptor) []string {
+	if desc == nil {
+		return nil
+	}
+	names := make([]string, 0, len(desc.Spec.DeploymentProfile.Components))
+	for _, component := range desc.Spec.DeploymentProfile.Components {
+		names = append(names, component.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// truncate shortens s to at most n bytes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
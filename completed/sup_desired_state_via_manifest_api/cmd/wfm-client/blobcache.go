@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+
+	"skeleton/pkg/common"
+)
+
+// maxBlobCacheEntries bounds the blob cache; the oldest descriptors are evicted first.
+const maxBlobCacheEntries = 256
+
+// blobCache holds digest-verified deployment descriptors by digest. It is shared by the
+// device loops of a gateway, so that a deployment rolled out to several devices behind the
+// gateway is downloaded once.
+type blobCache struct {
+	mu          sync.Mutex
+	descriptors map[string]common.ApplicationDeploymentDescriptor
+	order       []string // digests in insertion order
+}
+
+func newBlobCache() *blobCache {
+	return &blobCache{descriptors: map[string]common.ApplicationDeploymentDescriptor{}}
+}
+
+func (bc *blobCache) get(digest string) (*common.ApplicationDeploymentDescriptor, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	desc, ok := bc.descriptors[digest]
+	if !ok {
+		return nil, false
+	}
+	return &desc, true
+}
+
+// put stores a descriptor whose digest has been verified by the caller.
+func (bc *blobCache) put(digest string, desc *common.ApplicationDeploymentDescriptor) {
+	if desc == nil {
+		return
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if _, ok := bc.descriptors[digest]; ok {
+		return
+	}
+	if len(bc.order) >= maxBlobCacheEntries {
+		delete(bc.descriptors, bc.order[0])
+		bc.order = bc.order[1:]
+	}
+	bc.descriptors[digest] = *desc
+	bc.order = append(bc.order, digest)
+}
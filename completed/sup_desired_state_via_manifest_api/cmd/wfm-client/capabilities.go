@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+
+	"skeleton/pkg/common"
+)
+
+const capabilitiesAPIVersion = "device.margo.org/v1alpha1"
+
+// deviceInfo is the operator-provided part of the capabilities report.
+type deviceInfo struct {
+	Vendor       string
+	ModelNumber  string
+	SerialNumber string
+	Roles        []string
+	Resources    *deviceResources // overrides the detected resources when set
+}
+
+type deviceResources struct {
+	CpuCores float64
+	Memory   string
+	Storage  string
+}
+
+// collectCapabilities builds the capabilities document from the configured device info and
+// the configured resources, or those detected on this host.
+func collectCapabilities(cfg clientConfig) (common.DeviceCapabilities, error) {
+	resources, err := deviceResourcesOf(cfg.Device)
+	if err != nil {
+		return common.DeviceCapabilities{}, err
+	}
+	return common.DeviceCapabilities{
+		ApiVersion: capabilitiesAPIVersion,
+		Kind:       common.DeviceCapabilitiesKind,
+		Properties: common.DeviceCapabilitiesProperties{
+			Id:           cfg.DeviceID,
+			Vendor:       cfg.Device.Vendor,
+			ModelNumber:  cfg.Device.ModelNumber,
+			SerialNumber: cfg.Device.SerialNumber,
+			Roles:        cfg.Device.Roles,
+			Resources:    resources,
+		},
+	}, nil
+}
+
+func deviceResourcesOf(device deviceInfo) (common.DeviceResources, error) {
+	if r := device.Resources; r != nil {
+		return common.DeviceResources{Cpu: common.DeviceCpu{Cores: r.CpuCores}, Memory: r.Memory, Storage: r.Storage}, nil
+	}
+	memory, err := detectMemoryBytes()
+	if err != nil {
+		return common.DeviceResources{}, fmt.Errorf("memory detection failed: %w", err)
+	}
+	storage, err := detectStorageBytes("/")
+	if err != nil {
+		return common.DeviceResources{}, fmt.Errorf("storage detection failed: %w", err)
+	}
+	return common.DeviceResources{
+		Cpu:     common.DeviceCpu{Cores: float64(runtime.NumCPU())},
+		Memory:  fmt.Sprintf("%dMi", memory>>20),
+		Storage: fmt.Sprintf("%dGi", storage>>30),
+	}, nil
+}
+
+// reportCapabilitiesIfChanged sends the capabilities on the first call and whenever they
+// differ from the last successful report. Failures are retried on the next call.
+func reportCapabilitiesIfChanged(ctx context.Context, c *http.Client, cfg clientConfig, st *state) error {
+	capabilities, err := collectCapabilities(cfg)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(capabilities)
+	if err != nil {
+		return fmt.Errorf("capabilities marshal failed: %w", err)
+	}
+	digest := common.CalculateDigest(body)
+	if digest == st.CapabilitiesDigest {
+		return nil
+	}
+
+	capabilitiesURL := resolveURL(cfg.BaseURL, fmt.Sprintf("/api/v1/client/%s/capabilities", cfg.DeviceID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, capabilitiesURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("capabilities request build failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("capabilities request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected capabilities status %d", resp.StatusCode)
+	}
+
+	st.CapabilitiesDigest = digest
+	r := capabilities.Properties.Resources
+	infof("capabilities reported deviceId=%s cores=%v memory=%s storage=%s roles=%v", cfg.DeviceID, r.Cpu.Cores, r.Memory, r.Storage, capabilities.Properties.Roles)
+	return nil
+}
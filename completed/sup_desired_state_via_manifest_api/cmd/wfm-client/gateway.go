@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gatewayConfig lists the downstream devices a gateway serves, following the
+// single-client-for-multiple-devices proposal. Every device gets its own poll loop, state
+// and appliers; unset fields default to the client flags.
+type gatewayConfig struct {
+	Devices []gatewayDevice `yaml:"devices"`
+}
+
+type gatewayDevice struct {
+	ID           string            `yaml:"id"`
+	Vendor       string            `yaml:"vendor"`
+	ModelNumber  string            `yaml:"modelNumber"`
+	SerialNumber string            `yaml:"serialNumber"`
+	Roles        []string          `yaml:"roles"`
+	Resources    *gatewayResources `yaml:"resources"`
+	// Appliers holds TYPE=APPLIER mappings like --applier.
+	Appliers        []string      `yaml:"appliers"`
+	DefaultApplier  string        `yaml:"defaultApplier"`
+	ExecHook        string        `yaml:"execHook"`
+	ExecHookTimeout time.Duration `yaml:"execHookTimeout"`
+	HelmBinary      string        `yaml:"helmBinary"`
+	HelmKubeContext string        `yaml:"helmKubeContext"`
+}
+
+// gatewayResources replaces the resources detected on the gateway host, which say nothing
+// about the device behind it.
+type gatewayResources struct {
+	CpuCores float64 `yaml:"cpuCores"`
+	Memory   string  `yaml:"memory"`
+	Storage  string  `yaml:"storage"`
+}
+
+func loadGatewayConfig(path string) (*gatewayConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var gc gatewayConfig
+	if err := yaml.Unmarshal(raw, &gc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(gc.Devices) == 0 {
+		return nil, fmt.Errorf("%s: no devices", path)
+	}
+	seen := make(map[string]struct{}, len(gc.Devices))
+	for i, device := range gc.Devices {
+		if device.ID == "" {
+			return nil, fmt.Errorf("%s: device %d has no id", path, i)
+		}
+		if device.ID != filepath.Base(device.ID) || device.ID == "." || device.ID == ".." {
+			return nil, fmt.Errorf("%s: invalid device id %q", path, device.ID)
+		}
+		if _, ok := seen[device.ID]; ok {
+			return nil, fmt.Errorf("%s: duplicate device id %q", path, device.ID)
+		}
+		seen[device.ID] = struct{}{}
+	}
+	return &gc, nil
+}
+
+// gatewayDeviceConfigs derives the configuration of every downstream device from the
+// gateway configuration, using base (built from the client flags) for unset fields. Each
+// device keeps its state in <state-dir>/devices/<id>.
+func gatewayDeviceConfigs(gc *gatewayConfig, base clientConfig, mappings []string, fallback string, opts applierOptions) ([]clientConfig, error) {
+	if base.DeviceID != "" {
+		return nil, errors.New("--device-id cannot be combined with --gateway-config")
+	}
+	configs := make([]clientConfig, 0, len(gc.Devices))
+	for _, device := range gc.Devices {
+		cfg := base
+		cfg.DeviceID = device.ID
+		cfg.StateDir = filepath.Join(base.StateDir, "devices", device.ID)
+		cfg.Device = deviceInfo{
+			Vendor:       valueOr(device.Vendor, base.Device.Vendor),
+			ModelNumber:  valueOr(device.ModelNumber, base.Device.ModelNumber),
+			SerialNumber: valueOr(device.SerialNumber, device.ID),
+			Roles:        base.Device.Roles,
+		}
+		if len(device.Roles) > 0 {
+			cfg.Device.Roles = device.Roles
+		}
+		if r := device.Resources; r != nil {
+			cfg.Device.Resources = &deviceResources{CpuCores: r.CpuCores, Memory: r.Memory, Storage: r.Storage}
+		}
+
+		deviceMappings := mappings
+		if len(device.Appliers) > 0 {
+			deviceMappings = device.Appliers
+		}
+		deviceOpts := opts
+		deviceOpts.DeviceID = device.ID
+		deviceOpts.ExecHook = valueOr(device.ExecHook, opts.ExecHook)
+		deviceOpts.HelmBinary = valueOr(device.HelmBinary, opts.HelmBinary)
+		deviceOpts.HelmKubeContext = valueOr(device.HelmKubeContext, opts.HelmKubeContext)
+		if device.ExecHookTimeout > 0 {
+			deviceOpts.ExecHookTimeout = device.ExecHookTimeout
+		}
+		appliers, err := newApplierSet(deviceMappings, valueOr(device.DefaultApplier, fallback), deviceOpts)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", device.ID, err)
+		}
+		cfg.Appliers = appliers
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
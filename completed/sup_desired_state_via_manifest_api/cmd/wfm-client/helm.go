@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"skeleton/pkg/common"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// maxReleaseNameLength is the longest release name helm accepts.
+	maxReleaseNameLength = 53
+
+	helmPropertyRepository = "repository"
+	helmPropertyRevision   = "revision"
+	helmPropertyTimeout    = "timeout"
+	helmPropertyWait       = "wait"
+)
+
+var releaseNameInvalidRe = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// helmApplier applies helm.v3 deployment profiles: every component is a release of the chart
+// named by its repository and revision properties, installed into the descriptor namespace
+// with the values its parameters target.
+type helmApplier struct {
+	binary      string
+	kubeContext string
+}
+
+// helmRelease is a component as installed by helm.
+type helmRelease struct {
+	Name       string
+	Namespace  string
+	Component  common.DeploymentComponent
+	Repository string
+	Revision   string
+	Timeout    string
+	Wait       bool
+}
+
+func (h helmApplier) Deploy(ctx context.Context, d appliedDeployment) error {
+	releases, err := helmReleases(d)
+	if err != nil {
+		return err
+	}
+	for _, release := range releases {
+		if err := h.upgrade(ctx, d, release); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update upgrades the releases of all components and uninstalls those of components the
+// new descriptor no longer has.
+func (h helmApplier) Update(ctx context.Context, previous, d appliedDeployment) error {
+	if err := h.Deploy(ctx, d); err != nil {
+		return err
+	}
+	if previous.Descriptor == nil {
+		return nil
+	}
+	releases, err := helmReleases(d)
+	if err != nil {
+		return err
+	}
+	stale, err := helmReleases(previous)
+	if err != nil {
+		return err
+	}
+	stale = slices.DeleteFunc(stale, func(old helmRelease) bool {
+		return slices.ContainsFunc(releases, func(r helmRelease) bool {
+			return r.Name == old.Name && r.Namespace == old.Namespace
+		})
+	})
+	return h.uninstall(ctx, stale)
+}
+
+func (h helmApplier) Undeploy(ctx context.Context, d appliedDeployment) error {
+	releases, err := helmReleases(d)
+	if err != nil {
+		return err
+	}
+	return h.uninstall(ctx, releases)
+}
+
+// Status aggregates the release statuses: the deployment has failed when any release has,
+// and is installed when all releases are deployed.
+func (h helmApplier) Status(ctx context.Context, d appliedDeployment) (string, error) {
+	releases, err := helmReleases(d)
+	if err != nil {
+		return "", err
+	}
+	state := common.DeploymentStateInstalled
+	for _, release := range releases {
+		out, err := h.run(ctx, "status", release.Name, "--namespace", release.Namespace, "--output", "json")
+		if err != nil {
+			return "", err
+		}
+		var status struct {
+			Info struct {
+				Status string `json:"status"`
+			} `json:"info"`
+		}
+		if err := json.Unmarshal(out, &status); err != nil {
+			return "", fmt.Errorf("helm status %s: %w", release.Name, err)
+		}
+		switch status.Info.Status {
+		case "deployed":
+		case "failed":
+			return common.DeploymentStateFailed, nil
+		case "pending-install", "pending-upgrade", "pending-rollback":
+			state = common.DeploymentStateInstalling
+		default:
+			if state == common.DeploymentStateInstalled {
+				state = common.DeploymentStatePending
+			}
+		}
+	}
+	return state, nil
+}
+
+// upgrade installs or upgrades the release of a component, so that a deploy interrupted
+// half way can be retried.
+func (h helmApplier) upgrade(ctx context.Context, d appliedDeployment, release helmRelease) error {
+	values, err := common.ComponentValues(d.Descriptor, release.Component.Name)
+	if err != nil {
+		return err
+	}
+	raw, err := yaml.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("values marshal failed: %w", err)
+	}
+	valuesFile, err := os.CreateTemp("", "wfm-values-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(valuesFile.Name())
+	_, err = valuesFile.Write(raw)
+	if closeErr := valuesFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("values file write failed: %w", err)
+	}
+
+	args := []string{"upgrade", release.Name, release.Repository, "--install", "--namespace", release.Namespace, "--create-namespace", "--values", valuesFile.Name()}
+	if release.Revision != "" {
+		args = append(args, "--version", release.Revision)
+	}
+	if release.Wait {
+		args = append(args, "--wait")
+	}
+	if release.Timeout != "" {
+		args = append(args, "--timeout", release.Timeout)
+	}
+	_, err = h.run(ctx, args...)
+	return err
+}
+
+// uninstall removes the releases in reverse order; helm skips releases that are already gone.
+func (h helmApplier) uninstall(ctx context.Context, releases []helmRelease) error {
+	for i := len(releases) - 1; i >= 0; i-- {
+		if _, err := h.run(ctx, "uninstall", releases[i].Name, "--namespace", releases[i].Namespace, "--ignore-not-found"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h helmApplier) run(ctx context.Context, args ...string) ([]byte, error) {
+	if h.kubeContext != "" {
+		args = append(args, "--kube-context", h.kubeContext)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	tracef("helm %s", strings.Join(args, " "))
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("helm %s: %w: %s", args[0], err, truncate(msg, maxCommandOutputBytes))
+		}
+		return nil, fmt.Errorf("helm %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// helmReleases returns the releases of the components of a deployment in descriptor order.
+func helmReleases(d appliedDeployment) ([]helmRelease, error) {
+	if d.Descriptor == nil {
+		return nil, errors.New("descriptor not available")
+	}
+	namespace := d.Descriptor.Metadata.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	releases := make([]helmRelease, 0, len(d.Descriptor.Spec.DeploymentProfile.Components))
+	for _, component := range d.Descriptor.Spec.DeploymentProfile.Components {
+		release := helmRelease{
+			Name:       releaseName(d.ID, component.Name),
+			Namespace:  namespace,
+			Component:  component,
+			Repository: component.Properties[helmPropertyRepository],
+			Revision:   component.Properties[helmPropertyRevision],
+			Timeout:    component.Properties[helmPropertyTimeout],
+		}
+		if release.Repository == "" {
+			return nil, fmt.Errorf("component %s: %s property is required", component.Name, helmPropertyRepository)
+		}
+		if release.Timeout != "" {
+			if _, err := time.ParseDuration(release.Timeout); err != nil {
+				return nil, fmt.Errorf("component %s: invalid %s %q", component.Name, helmPropertyTimeout, release.Timeout)
+			}
+		}
+		if wait, ok := component.Properties[helmPropertyWait]; ok {
+			var err error
+			if release.Wait, err = strconv.ParseBool(wait); err != nil {
+				return nil, fmt.Errorf("component %s: invalid %s %q", component.Name, helmPropertyWait, wait)
+			}
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// releaseName derives a valid, stable release name from the component name, suffixed with a
+// hash of the deployment ID so that deployments of the same application do not collide.
+func releaseName(deploymentID, component string) string {
+	sum := sha256.Sum256([]byte(deploymentID))
+	suffix := "-" + hex.EncodeToString(sum[:4])
+	name := strings.Trim(releaseNameInvalidRe.ReplaceAllString(strings.ToLower(component), "-"), "-")
+	if len(name) > maxReleaseNameLength-len(suffix) {
+		name = strings.TrimRight(name[:maxReleaseNameLength-len(suffix)], "-")
+	}
+	if name == "" {
+		name = "component"
+	}
+	return name + suffix
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"skeleton/pkg/common"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fakeHelmScript records every invocation in calls, one line of arguments each, keeps the
+// values file of every upgraded release as <release>.yaml and reports all releases deployed.
+// Every invocation fails while the file fail exists.
+const fakeHelmScript = `#!/bin/sh
+dir=%s
+echo "$*" >> "$dir/calls"
+if [ -f "$dir/fail" ]; then
+	echo "Error: $(cat "$dir/fail")" >&2
+	exit 1
+fi
+case "$1" in
+upgrade)
+	release=$2
+	while [ $# -gt 0 ]; do
+		if [ "$1" = --values ]; then
+			cp "$2" "$dir/$release.yaml"
+		fi
+		shift
+	done
+	;;
+status)
+	echo '{"info":{"status":"deployed"}}'
+	;;
+esac
+`
+
+const testDeploymentID = "a3e2f5dc-912e-494f-8395-52cf3769bc06"
+
+// fakeHelm returns a helm applier running the fake helm script, and the directory the script
+// records its invocations in.
+func fakeHelm(t *testing.T) (helmApplier, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake helm binary is a shell script")
+	}
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "helm")
+	script := strings.Replace(fakeHelmScript, "%s", "'"+dir+"'", 1)
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake helm: %v", err)
+	}
+	return helmApplier{binary: binary, kubeContext: "line-2"}, dir
+}
+
+// helmCalls returns the recorded invocations. The name of the temporary values file is
+// replaced with VALUES.
+func helmCalls(t *testing.T, dir string) []string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatalf("read helm calls: %v", err)
+	}
+	var calls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		args := strings.Fields(line)
+		for i := 1; i < len(args); i++ {
+			if args[i-1] == "--values" {
+				args[i] = "VALUES"
+			}
+		}
+		calls = append(calls, strings.Join(args, " "))
+	}
+	return calls
+}
+
+// helmValues returns the values the release was last upgraded with.
+func helmValues(t *testing.T, dir, release string) map[string]any {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(dir, release+".yaml"))
+	if err != nil {
+		t.Fatalf("read values of %s: %v", release, err)
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(raw, &values); err != nil {
+		t.Fatalf("parse values of %s: %v", release, err)
+	}
+	return values
+}
+
+// testHelmDeployment returns a deployment of the named components of the test descriptor:
+// api pins its chart revision and waits for the release, db uses the chart defaults.
+func testHelmDeployment(components ...string) appliedDeployment {
+	properties := map[string]map[string]string{
+		"api": {"repository": "oci://registry.example.com/charts/api", "revision": "1.2.0", "wait": "true", "timeout": "5m"},
+		"db":  {"repository": "oci://registry.example.com/charts/db"},
+	}
+	descriptor := &common.ApplicationDeploymentDescriptor{
+		ApiVersion: "margo.org",
+		Kind:       "ApplicationDeployment",
+		Metadata:   common.ApplicationMetadata{Name: "shop", Namespace: "plant-1"},
+		Spec: common.ApplicationSpec{
+			DeploymentProfile: common.DeploymentProfile{Type: "helm.v3"},
+			Parameters: map[string]common.ApplicationParam{
+				"region": {Value: "eu-west", Targets: []common.ApplicationParamTarget{
+					{Pointer: "global.region"},
+				}},
+				"replicas": {Value: 3, Targets: []common.ApplicationParamTarget{
+					{Pointer: "replicaCount", Components: []string{"api"}},
+				}},
+				"debug": {Value: false, Targets: []common.ApplicationParamTarget{
+					{Pointer: "logging.debug", Components: []string{"api"}},
+				}},
+				"password": {Value: "s3cret", Targets: []common.ApplicationParamTarget{
+					{Pointer: "auth.password", Components: []string{"db"}},
+					{Pointer: "database.password", Components: []string{"api"}},
+				}},
+			},
+		},
+	}
+	for _, name := range components {
+		descriptor.Spec.DeploymentProfile.Components = append(descriptor.Spec.DeploymentProfile.Components,
+			common.DeploymentComponent{Name: name, Properties: properties[name]})
+	}
+	return appliedDeployment{ID: testDeploymentID, Digest: "sha256:0", Descriptor: descriptor}
+}
+
+func TestHelmApplierDeploy(t *testing.T) {
+	helm, dir := fakeHelm(t)
+	d := testHelmDeployment("api", "db")
+	if err := helm.Deploy(context.Background(), d); err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+
+	api, db := releaseName(testDeploymentID, "api"), releaseName(testDeploymentID, "db")
+	want := []string{
+		"upgrade " + api + " oci://registry.example.com/charts/api --install --namespace plant-1 --create-namespace --values VALUES --version 1.2.0 --wait --timeout 5m --kube-context line-2",
+		"upgrade " + db + " oci://registry.example.com/charts/db --install --namespace plant-1 --create-namespace --values VALUES --kube-context line-2",
+	}
+	if got := helmCalls(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("helm calls:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A target without components applies to all of them, the others only to the named ones.
+	// Values keep their type.
+	wantValues := map[string]map[string]any{
+		api: {
+			"global":       map[string]any{"region": "eu-west"},
+			"replicaCount": 3,
+			"logging":      map[string]any{"debug": false},
+			"database":     map[string]any{"password": "s3cret"},
+		},
+		db: {
+			"global": map[string]any{"region": "eu-west"},
+			"auth":   map[string]any{"password": "s3cret"},
+		},
+	}
+	for release, want := range wantValues {
+		if got := helmValues(t, dir, release); !reflect.DeepEqual(got, want) {
+			t.Errorf("values of %s = %v, want %v", release, got, want)
+		}
+	}
+
+	state, err := helm.Status(context.Background(), d)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if state != common.DeploymentStateInstalled {
+		t.Errorf("state = %s, want %s", state, common.DeploymentStateInstalled)
+	}
+}
+
+func TestHelmApplierUpdateUninstallsDroppedComponents(t *testing.T) {
+	helm, dir := fakeHelm(t)
+	if err := helm.Update(context.Background(), testHelmDeployment("api", "db"), testHelmDeployment("api")); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	api, db := releaseName(testDeploymentID, "api"), releaseName(testDeploymentID, "db")
+	want := []string{
+		"upgrade " + api + " oci://registry.example.com/charts/api --install --namespace plant-1 --create-namespace --values VALUES --version 1.2.0 --wait --timeout 5m --kube-context line-2",
+		"uninstall " + db + " --namespace plant-1 --ignore-not-found --kube-context line-2",
+	}
+	if got := helmCalls(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("helm calls:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestHelmApplierUndeploy(t *testing.T) {
+	helm, dir := fakeHelm(t)
+	if err := helm.Undeploy(context.Background(), testHelmDeployment("api", "db")); err != nil {
+		t.Fatalf("undeploy: %v", err)
+	}
+
+	// Releases are uninstalled in reverse order
+	api, db := releaseName(testDeploymentID, "api"), releaseName(testDeploymentID, "db")
+	want := []string{
+		"uninstall " + db + " --namespace plant-1 --ignore-not-found --kube-context line-2",
+		"uninstall " + api + " --namespace plant-1 --ignore-not-found --kube-context line-2",
+	}
+	if got := helmCalls(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("helm calls:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// A failed uninstall must fail the undeploy, whatever helm reports, so that it is retried.
+func TestHelmApplierUndeployFails(t *testing.T) {
+	helm, dir := fakeHelm(t)
+	if err := os.WriteFile(filepath.Join(dir, "fail"), []byte("Kubernetes cluster unreachable: resource not found"), 0o644); err != nil {
+		t.Fatalf("write fail: %v", err)
+	}
+	err := helm.Undeploy(context.Background(), testHelmDeployment("api", "db"))
+	if err == nil || !strings.Contains(err.Error(), "cluster unreachable") {
+		t.Fatalf("undeploy error = %v, want the helm error", err)
+	}
+	if calls := helmCalls(t, dir); len(calls) != 1 {
+		t.Errorf("%d helm calls after the failed uninstall, want 1", len(calls))
+	}
+}
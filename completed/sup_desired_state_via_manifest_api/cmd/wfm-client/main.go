@@ -5,17 +5,21 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,25 +34,55 @@ var (
 	verbose  bool
 )
 
+const (
+	// requestOverheadBytes approximates the cost of one extra HTTP round trip (headers, framing)
+	// when comparing the bundle size against fetching descriptors individually.
+	requestOverheadBytes = 1024
+	// bundleChangeRatio is the share of changed deployments above which the bundle is
+	// fetched when the server does not advertise sizeBytes.
+	bundleChangeRatio = 0.5
+	// watchPollInterval is the pause between manifest requests in watch mode, where the
+	// server holds each request open until the manifest changes.
+	watchPollInterval = time.Second
+)
+
 type clientConfig struct {
-	BaseURL      string
-	DeviceID     string
-	PollInterval time.Duration
+	BaseURL       string
+	DeviceID      string
+	StateDir      string // holds the onboarding identity, the WFM root CA certificate and the client state
+	ResetState    bool   // discard a client state written for another device
+	PollInterval  time.Duration
+	Watch         bool               // wait for manifest changes when the server advertises watch mode
+	TrustedKeys   []crypto.PublicKey // out-of-band provisioned manifest signing keys
+	RequireSigned bool
+	Device        deviceInfo // reported as device capabilities
+	Appliers      applierSet // apply deployments by deploymentProfile.type
+	Blobs         *blobCache // verified descriptors, shared by the devices of a gateway
 }
 
 // This struct holds the latest manifest and deployment state fetched from the server.
-// Production code would persist this state.
+// It is persisted in the state directory (see saveState) so that the rollback protection
+// survives restarts.
 type state struct {
-	ManifestETag    string
-	ManifestVersion uint64
-	Deployments     map[string]deploymentCacheEntry // deploymentId -> cache entry
-	BundleFetched   bool
+	DeviceID        string                          `json:"deviceId"`
+	ManifestETag    string                          `json:"manifestETag"`
+	ManifestVersion uint64                          `json:"manifestVersion"`
+	Deployments     map[string]deploymentCacheEntry `json:"deployments"` // deploymentId -> cache entry
+	BundleFetched   bool                            `json:"bundleFetched"`
+	// CapabilitiesDigest is the digest of the last capabilities document the server accepted.
+	// It is not persisted so that capabilities are reported again on every start.
+	CapabilitiesDigest string `json:"-"`
+	// ManifestWait is the longest manifest wait the server advertised; zero while the server
+	// does not support watch mode.
+	ManifestWait time.Duration `json:"-"`
 }
 
 type deploymentCacheEntry struct {
-	Digest        string
-	ApplicationID string
-	Name          string
+	Digest        string `json:"digest"`
+	ApplicationID string `json:"applicationId"`
+	Name          string `json:"name"`
+	// Descriptor is the applied descriptor, kept to select the applier and undeploy later.
+	Descriptor *common.ApplicationDeploymentDescriptor `json:"descriptor,omitempty"`
 }
 
 type resolvedDeployment struct {
@@ -61,9 +95,30 @@ func main() {
 		Usage: "Workload Fleet Management API Client",
 		Flags: []cli.Flag{
 			&cli.StringFlag{Name: "wfm-base-url", Value: "http://localhost:8080", Usage: "Base URL of WFM API server"},
-			&cli.StringFlag{Name: "device-id", Value: "c92cb339-c99c-4eca-9dd4-f8484dd16cfb", Usage: "Device identifier"},
+			&cli.StringFlag{Name: "device-id", Usage: "Device identifier; when empty the client onboards with --client-cert"},
+			&cli.StringFlag{Name: "state-dir", Value: "./wfm-client-state", Usage: "Directory for client state (manifest state, onboarding identity, root CA certificate)"},
+			&cli.BoolFlag{Name: "reset-state", Usage: "Discard a client state written for another device ID instead of refusing to start"},
+			&cli.StringFlag{Name: "client-cert", Usage: "PEM client certificate presented when onboarding (default: --tls-cert)"},
+			&cli.StringFlag{Name: "client-key", Usage: "PEM private key of --client-cert, signing the onboarding proof of possession (default: --tls-key)"},
+			&cli.StringFlag{Name: "tls-cert", Usage: "PEM client certificate for mutual TLS"},
+			&cli.StringFlag{Name: "tls-key", Usage: "PEM private key of --tls-cert"},
+			&cli.StringFlag{Name: "ca", Usage: "PEM CA certificates trusted to verify the WFM server (default: system roots)"},
 			&cli.DurationFlag{Name: "poll-interval", Value: 30 * time.Second, Usage: "Polling interval for manifest"},
+			&cli.BoolFlag{Name: "watch", Value: true, Usage: "Wait for manifest changes instead of polling when the server supports watch mode"},
 			&cli.BoolFlag{Name: "verbose", Usage: "Enable verbose logging"},
+			&cli.StringSliceFlag{Name: "trusted-key", Usage: "PEM public key or certificate trusted to sign manifests (ES256/RS256); repeatable"},
+			&cli.BoolFlag{Name: "require-signed-manifest", Usage: "Reject unsigned manifests (requires --trusted-key, which implies it)"},
+			&cli.StringFlag{Name: "vendor", Value: "unknown", Usage: "Device vendor reported as capability"},
+			&cli.StringFlag{Name: "model-number", Value: "unknown", Usage: "Device model number reported as capability"},
+			&cli.StringFlag{Name: "serial-number", Usage: "Device serial number reported as capability (default: hostname)"},
+			&cli.StringSliceFlag{Name: "role", Value: []string{"Standalone Device"}, Usage: "Device role reported as capability (Standalone Cluster, Cluster Leader, Standalone Device); repeatable"},
+			&cli.StringSliceFlag{Name: "applier", Usage: "TYPE=APPLIER: apply deployments of deploymentProfile.type TYPE with APPLIER (dry-run, exec, helm); repeatable"},
+			&cli.StringFlag{Name: "default-applier", Value: applierDryRun, Usage: "Applier for deployment profile types without an --applier mapping"},
+			&cli.StringFlag{Name: "exec-hook", Usage: "Command run by the exec applier with the action and deployment ID as arguments and the descriptor on stdin"},
+			&cli.DurationFlag{Name: "exec-hook-timeout", Value: 10 * time.Minute, Usage: "Timeout of a single exec hook invocation"},
+			&cli.StringFlag{Name: "helm-binary", Value: "helm", Usage: "Helm v3 executable run by the helm applier"},
+			&cli.StringFlag{Name: "helm-kube-context", Usage: "Kubeconfig context used by the helm applier (default: current context)"},
+			&cli.StringFlag{Name: "gateway-config", Usage: "YAML file listing the downstream devices served by this client as a gateway"},
 		},
 		Action: run,
 	}
@@ -74,16 +129,98 @@ func main() {
 
 func run(ctx context.Context, cmd *cli.Command) error {
 	cfg := clientConfig{
-		BaseURL:      strings.TrimRight(cmd.String("wfm-base-url"), "/"),
-		DeviceID:     cmd.String("device-id"),
-		PollInterval: cmd.Duration("poll-interval"),
+		BaseURL:       strings.TrimRight(cmd.String("wfm-base-url"), "/"),
+		DeviceID:      cmd.String("device-id"),
+		StateDir:      cmd.String("state-dir"),
+		ResetState:    cmd.Bool("reset-state"),
+		PollInterval:  cmd.Duration("poll-interval"),
+		Watch:         cmd.Bool("watch"),
+		RequireSigned: cmd.Bool("require-signed-manifest"),
+		Device: deviceInfo{
+			Vendor:       cmd.String("vendor"),
+			ModelNumber:  cmd.String("model-number"),
+			SerialNumber: cmd.String("serial-number"),
+			Roles:        cmd.StringSlice("role"),
+		},
+		Blobs: newBlobCache(),
 	}
 	verbose = cmd.Bool("verbose")
+	opts := applierOptions{
+		ExecHook:        cmd.String("exec-hook"),
+		ExecHookTimeout: cmd.Duration("exec-hook-timeout"),
+		HelmBinary:      cmd.String("helm-binary"),
+		HelmKubeContext: cmd.String("helm-kube-context"),
+	}
 
-	httpClient := &http.Client{Timeout: 15 * time.Second}
-	st := &state{Deployments: map[string]deploymentCacheEntry{}}
+	for _, path := range cmd.StringSlice("trusted-key") {
+		key, err := common.LoadPublicKey(path)
+		if err != nil {
+			return fmt.Errorf("trusted key: %w", err)
+		}
+		cfg.TrustedKeys = append(cfg.TrustedKeys, key)
+	}
+	if cfg.RequireSigned && len(cfg.TrustedKeys) == 0 {
+		return errors.New("--require-signed-manifest needs at least one --trusted-key")
+	}
+	// Pinned keys would be pointless if whoever can alter the response could downgrade it to
+	// an unsigned manifest, so they imply signed manifests
+	cfg.RequireSigned = len(cfg.TrustedKeys) > 0
 
-	infof("client start deviceId=%s base=%s interval=%s", cfg.DeviceID, cfg.BaseURL, cfg.PollInterval)
+	tlsConfig, err := newTLSConfig(cmd.String("tls-cert"), cmd.String("tls-key"), cmd.String("ca"))
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	httpClient := &http.Client{Timeout: 15 * time.Second, Transport: transport}
+
+	var devices []clientConfig
+	if path := cmd.String("gateway-config"); path != "" {
+		// downstream devices cannot onboard through the gateway, so their IDs are configured
+		gc, err := loadGatewayConfig(path)
+		if err != nil {
+			return fmt.Errorf("gateway: %w", err)
+		}
+		if devices, err = gatewayDeviceConfigs(gc, cfg, cmd.StringSlice("applier"), cmd.String("default-applier"), opts); err != nil {
+			return fmt.Errorf("gateway: %w", err)
+		}
+		// keep a warm connection per device loop
+		transport.MaxIdleConnsPerHost = len(devices)
+	} else {
+		if cfg.Device.SerialNumber == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return fmt.Errorf("serial number: %w", err)
+			}
+			cfg.Device.SerialNumber = hostname
+		}
+		if cfg.DeviceID == "" {
+			certPath, keyPath := cmd.String("client-cert"), cmd.String("client-key")
+			if certPath == "" {
+				certPath = cmd.String("tls-cert")
+			}
+			if keyPath == "" {
+				keyPath = cmd.String("tls-key")
+			}
+			deviceID, err := resolveDeviceID(ctx, httpClient, cfg, certPath, keyPath)
+			if err != nil {
+				return fmt.Errorf("onboarding: %w", err)
+			}
+			cfg.DeviceID = deviceID
+		}
+		opts.DeviceID = cfg.DeviceID
+		if cfg.Appliers, err = newApplierSet(cmd.StringSlice("applier"), cmd.String("default-applier"), opts); err != nil {
+			return fmt.Errorf("applier: %w", err)
+		}
+		devices = []clientConfig{cfg}
+	}
+
+	states := make([]*state, len(devices))
+	for i, device := range devices {
+		if states[i], err = loadState(device); err != nil {
+			return fmt.Errorf("state: device %s: %w", device.DeviceID, err)
+		}
+	}
 
 	sigs := make(chan os.Signal, 2)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
@@ -91,17 +228,42 @@ func run(ctx context.Context, cmd *cli.Command) error {
 	defer cancel()
 	go func() { <-sigs; cancel() }()
 
+	var wg sync.WaitGroup
+	for i := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runDevice(ctx, httpClient, devices[i], states[i])
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// runDevice polls and applies the manifest of one device until ctx is canceled. A gateway
+// runs one loop per downstream device. In watch mode the server holds the manifest request
+// open until a change, so the next request follows shortly; errors fall back to the poll
+// interval.
+func runDevice(ctx context.Context, c *http.Client, cfg clientConfig, st *state) {
+	infof("client start deviceId=%s base=%s interval=%s watch=%t trustedKeys=%d manifestVersion=%d", cfg.DeviceID, cfg.BaseURL, cfg.PollInterval, cfg.Watch, len(cfg.TrustedKeys), st.ManifestVersion)
+
 	for {
-		if err := pollOnce(ctx, httpClient, cfg, st); err != nil {
+		if err := reportCapabilitiesIfChanged(ctx, c, cfg, st); err != nil && !errors.Is(err, context.Canceled) {
+			warnf("capabilities report error deviceId=%s: %v", cfg.DeviceID, err)
+		}
+		interval := cfg.PollInterval
+		if err := pollOnce(ctx, c, cfg, st); err != nil {
 			if errors.Is(err, context.Canceled) {
-				return nil
+				return
 			}
-			warnf("poll error: %v", err)
+			warnf("poll error deviceId=%s: %v", cfg.DeviceID, err)
+		} else if manifestWait(cfg, st) > 0 {
+			interval = watchPollInterval
 		}
 		select {
 		case <-ctx.Done():
-			return nil
-		case <-time.After(cfg.PollInterval):
+			return
+		case <-time.After(interval):
 		}
 	}
 }
@@ -120,6 +282,10 @@ func errorf(format string, args ...any) {
 	log.Printf("❌ "+format, args...)
 }
 
+func securityf(format string, args ...any) {
+	log.Printf("🛡️  SECURITY "+format, args...)
+}
+
 func successf(format string, args ...any) {
 	if verbose {
 		log.Printf("✅ "+format, args...)
@@ -136,9 +302,12 @@ func tracef(format string, args ...any) {
 	}
 }
 
-// pollOnce fetches the manifest, performs diff, fetches new/updated deployments
+// pollOnce fetches the manifest and applies it as a whole. Every descriptor is staged and its
+// digest verified before anything is reconciled, and the manifest version and ETag are only
+// committed once every deployment was applied. Any failure keeps the previous version and
+// ETag, so the next poll fetches the same manifest again and retries the failed deployments.
 func pollOnce(ctx context.Context, c *http.Client, cfg clientConfig, st *state) error {
-	manifest, etag, err := fetchManifest(ctx, c, cfg, st.ManifestETag)
+	manifest, etag, err := fetchManifest(ctx, c, cfg, st)
 	if err != nil {
 		return err
 	}
@@ -152,32 +321,59 @@ func pollOnce(ctx context.Context, c *http.Client, cfg clientConfig, st *state)
 		return nil
 	}
 
+	resolved, bundleFetched, err := stageDeployments(ctx, c, cfg, st, manifest)
+	if err != nil {
+		return fmt.Errorf("manifest version %d aborted, keeping version %d: %w", manifest.ManifestVersion, st.ManifestVersion, err)
+	}
+
+	desiredIDs := make(map[string]struct{}, len(manifest.Deployments))
+	for _, d := range manifest.Deployments {
+		desiredIDs[d.DeploymentId] = struct{}{}
+	}
+	if err := reconcileDeployments(ctx, c, cfg, st, desiredIDs, resolved); err != nil {
+		// the deployments applied so far are kept, the manifest is not committed
+		if saveErr := saveState(cfg, st); saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+		return fmt.Errorf("manifest version %d not fully applied, keeping version %d: %w", manifest.ManifestVersion, st.ManifestVersion, err)
+	}
+
+	// commit the manifest only once all of its deployments have been reconciled
 	st.ManifestVersion = manifest.ManifestVersion
 	if etag == "" {
 		warnf("manifest response missing ETag; server is non-compliant with spec, cache validator cleared")
 	}
 	st.ManifestETag = etag
+	if bundleFetched {
+		st.BundleFetched = true
+	}
+	return saveState(cfg, st)
+}
 
-	desiredIDs := make(map[string]struct{}, len(manifest.Deployments))
+// stageDeployments resolves the descriptor of every deployment in the manifest from the
+// bundle, the local cache or the server. It fails if any digest is invalid or any
+// descriptor cannot be fetched and verified; a failed bundle only falls back to
+// fetching the descriptors individually.
+func stageDeployments(ctx context.Context, c *http.Client, cfg clientConfig, st *state, manifest *common.GetDeploymentManifestResponse) (map[string]resolvedDeployment, bool, error) {
 	resolved := make(map[string]resolvedDeployment, len(manifest.Deployments))
+	bundleFetched := false
 
-	// Initial sync: fetch bundle to accelerate first sync
-	if !st.BundleFetched && len(st.Deployments) == 0 {
-		entries, ok := fetchBundleOnce(ctx, c, cfg.BaseURL, manifest.Bundle, manifest.Deployments)
+	// Initial sync or many changes: fetch bundle to reduce the number of round trips
+	if shouldFetchBundle(st, cfg.Blobs, manifest) {
+		entries, ok := fetchBundle(ctx, c, cfg.BaseURL, manifest.Bundle, manifest.Deployments)
 		if ok {
 			for depID, entry := range entries {
 				resolved[depID] = entry
+				cfg.Blobs.put(entry.Digest, entry.Descriptor)
 			}
-			st.BundleFetched = true
+			bundleFetched = true
 		}
 	}
 
 	// Continuous sync: fetch individual deployment descriptors whenever needed
 	for _, d := range manifest.Deployments {
-		desiredIDs[d.DeploymentId] = struct{}{}
-
-		if !isSupportedDigest(d.DeploymentId, d.Digest) {
-			continue
+		if err := checkDigest(d.DeploymentId, d.Digest); err != nil {
+			return nil, false, err
 		}
 
 		if entry, ok := resolved[d.DeploymentId]; ok {
@@ -194,49 +390,106 @@ func pollOnce(ctx context.Context, c *http.Client, cfg clientConfig, st *state)
 			continue
 		}
 
+		if desc, ok := cfg.Blobs.get(d.Digest); ok {
+			// descriptor already fetched for another device of this gateway
+			tracef("descriptor served from blob cache deviceId=%s deploymentId=%s digest=%s", cfg.DeviceID, d.DeploymentId, d.Digest)
+			resolved[d.DeploymentId] = resolvedDeployment{Digest: d.Digest, Descriptor: desc}
+			continue
+		}
+
 		// new descriptor: resolve from server
 		pd, err := fetchDeployment(ctx, c, resolveURL(cfg.BaseURL, d.URL), d.Digest)
 		if err != nil {
 			errorf("fetch deployment failed deploymentId=%s digest=%s err=%v", d.DeploymentId, d.Digest, err)
-			continue
+			reportDeploymentStatus(ctx, c, cfg, newDeploymentStatus(d.DeploymentId, d.Digest, common.DeploymentStateFailed, nil,
+				&common.StatusError{Code: "FETCH_FAILED", Message: err.Error()}))
+			return nil, false, fmt.Errorf("deployment %s: %w", d.DeploymentId, err)
 		}
 		resolved[d.DeploymentId] = resolvedDeployment{Digest: d.Digest, Descriptor: &pd}
+		cfg.Blobs.put(d.Digest, &pd)
 	}
-
-	reconcileDeployments(st, desiredIDs, resolved)
-	return nil
+	return resolved, bundleFetched, nil
 }
 
-func isSupportedDigest(deploymentID, digest string) bool {
-	if !digestRe.MatchString(digest) {
-		warnf("skip invalid digest deploymentId=%s digest=%s", deploymentID, digest)
+// shouldFetchBundle decides whether to download the bundle instead of the changed descriptors.
+// Descriptors in the blob cache need no download. The bundle is always preferred on initial
+// sync. Afterwards the advisory sizeBytes values are compared when the server provides them;
+// otherwise the share of changed deployments decides.
+func shouldFetchBundle(st *state, blobs *blobCache, manifest *common.GetDeploymentManifestResponse) bool {
+	if manifest.Bundle == nil || manifest.Bundle.URL == "" {
 		return false
 	}
 
-	if !strings.HasPrefix(digest, "sha256:") {
-		warnf("unsupported digest algorithm deploymentId=%s digest=%s", deploymentID, digest)
+	changed := 0
+	individualBytes := uint64(0)
+	sizesKnown := manifest.Bundle.SizeBytes > 0
+	for _, d := range manifest.Deployments {
+		if current, have := st.Deployments[d.DeploymentId]; have && current.Digest == d.Digest {
+			continue
+		}
+		if _, cached := blobs.get(d.Digest); cached {
+			continue
+		}
+		changed++
+		if d.SizeBytes == 0 {
+			sizesKnown = false
+		}
+		individualBytes += d.SizeBytes + requestOverheadBytes
+	}
+	if changed == 0 {
 		return false
 	}
+	if !st.BundleFetched && len(st.Deployments) == 0 {
+		return true
+	}
 
-	return true
+	if sizesKnown {
+		tracef("fetch strategy changed=%d individualBytes~%d bundleBytes~%d", changed, individualBytes, manifest.Bundle.SizeBytes)
+		return manifest.Bundle.SizeBytes+requestOverheadBytes <= individualBytes
+	}
+	tracef("fetch strategy changed=%d total=%d (sizeBytes not provided)", changed, len(manifest.Deployments))
+	return float64(changed) >= bundleChangeRatio*float64(len(manifest.Deployments))
+}
+
+// checkDigest rejects digests that are malformed or use an algorithm other than sha256.
+func checkDigest(deploymentID, digest string) error {
+	if !digestRe.MatchString(digest) {
+		return fmt.Errorf("invalid digest deploymentId=%s digest=%q", deploymentID, digest)
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		return fmt.Errorf("unsupported digest algorithm deploymentId=%s digest=%s", deploymentID, digest)
+	}
+	return nil
 }
 
-func fetchManifest(ctx context.Context, c *http.Client, cfg clientConfig, etag string) (*common.GetDeploymentManifestResponse, string, error) {
+// fetchManifest fetches the manifest unless it still matches st.ManifestETag. In watch mode
+// the server waits up to the advertised time for a change before answering 304.
+func fetchManifest(ctx context.Context, c *http.Client, cfg clientConfig, st *state) (*common.GetDeploymentManifestResponse, string, error) {
 	manifestURL := resolveURL(cfg.BaseURL, fmt.Sprintf("/api/v1/devices/%s/deployments", cfg.DeviceID))
+	wait := manifestWait(cfg, st)
+	if wait > 0 {
+		manifestURL += fmt.Sprintf("?wait=%d", int(wait/time.Second))
+		// the request is held open up to the wait on top of the usual round trip
+		watchClient := *c
+		watchClient.Timeout = c.Timeout + wait
+		c = &watchClient
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("manifest request build failed: %w", err)
 	}
-	if etag != "" {
+	if st.ManifestETag != "" {
 		// send previous manifest ETag via If-None-Match to save some bandwidth
-		req.Header.Set("If-None-Match", etag)
+		req.Header.Set("If-None-Match", st.ManifestETag)
 	}
+	req.Header.Set("Accept", manifestAcceptHeader(cfg))
 
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, "", fmt.Errorf("manifest request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	recordManifestWait(cfg, st, resp.Header)
 
 	switch resp.StatusCode {
 	case http.StatusNotModified:
@@ -247,6 +500,9 @@ func fetchManifest(ctx context.Context, c *http.Client, cfg clientConfig, etag s
 		if err != nil {
 			return nil, "", fmt.Errorf("manifest read failed: %w", err)
 		}
+		if raw, err = verifyManifest(cfg, resp.Header.Get("Content-Type"), raw); err != nil {
+			return nil, "", err
+		}
 		var manifest common.GetDeploymentManifestResponse
 		if err := json.Unmarshal(raw, &manifest); err != nil {
 			return nil, "", fmt.Errorf("manifest parse error: %w", err)
@@ -258,6 +514,74 @@ func fetchManifest(ctx context.Context, c *http.Client, cfg clientConfig, etag s
 	}
 }
 
+// manifestWait returns how long the next manifest request asks the server to wait for a
+// change. Only conditional requests are held open, so it is zero until a manifest is known.
+func manifestWait(cfg clientConfig, st *state) time.Duration {
+	if !cfg.Watch || st.ManifestETag == "" {
+		return 0
+	}
+	return st.ManifestWait
+}
+
+// recordManifestWait tracks the watch mode advertised by the server.
+func recordManifestWait(cfg clientConfig, st *state, header http.Header) {
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(header.Get(common.ManifestMaxWaitHeader)); err == nil && seconds > 0 {
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait == st.ManifestWait {
+		return
+	}
+	if cfg.Watch {
+		infof("manifest watch mode deviceId=%s maxWait=%s", cfg.DeviceID, wait)
+	}
+	st.ManifestWait = wait
+}
+
+// manifestAcceptHeader only accepts the signed manifest whenever signing keys are trusted.
+func manifestAcceptHeader(cfg clientConfig) string {
+	if cfg.RequireSigned {
+		return common.SignedManifestMediaType
+	}
+	return common.ManifestMediaType
+}
+
+// verifyManifest inspects the Content-Type of a manifest response and returns the
+// unsigned manifest JSON. Signed manifests are verified against the trusted keys.
+func verifyManifest(cfg clientConfig, contentType string, raw []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("manifest content type %q invalid: %w", contentType, err)
+	}
+
+	switch mediaType {
+	case common.SignedManifestMediaType:
+		if len(cfg.TrustedKeys) == 0 {
+			securityf("signed manifest received but no trusted keys configured; aborting update")
+			return nil, errors.New("manifest signature cannot be verified")
+		}
+		payload, header, err := common.VerifyJWS(raw, cfg.TrustedKeys)
+		if err != nil {
+			alg, kid := "", ""
+			if header != nil {
+				alg, kid = header.Alg, header.Kid
+			}
+			securityf("manifest signature verification failed alg=%s kid=%s err=%v; aborting update", alg, kid, err)
+			return nil, fmt.Errorf("manifest signature invalid: %w", err)
+		}
+		tracef("manifest signature valid alg=%s kid=%s", header.Alg, header.Kid)
+		return payload, nil
+	case common.ManifestMediaType:
+		if cfg.RequireSigned {
+			securityf("unsigned manifest received while signed manifests are required; aborting update")
+			return nil, errors.New("unsigned manifest rejected")
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("unsupported manifest content type %q", mediaType)
+	}
+}
+
 func fetchDeployment(ctx context.Context, c *http.Client, url, expectedDigest string) (common.ApplicationDeploymentDescriptor, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -288,7 +612,7 @@ func fetchDeployment(ctx context.Context, c *http.Client, url, expectedDigest st
 	return desc, nil
 }
 
-func fetchBundleOnce(ctx context.Context, c *http.Client, baseURL string, b *common.BundleDTO, deployments []common.DeploymentDTO) (map[string]resolvedDeployment, bool) {
+func fetchBundle(ctx context.Context, c *http.Client, baseURL string, b *common.BundleDTO, deployments []common.DeploymentDTO) (map[string]resolvedDeployment, bool) {
 	// bundle may be null when the server has no deployments assigned to this client
 	if b == nil || b.URL == "" {
 		return nil, true
@@ -383,7 +707,9 @@ func processBundleArchive(raw []byte, deployments []common.DeploymentDTO) (map[s
 	return entries, processed, true
 }
 
-func reconcileDeployments(st *state, desiredIDs map[string]struct{}, resolved map[string]resolvedDeployment) {
+// reconcileDeployments applies the changes of the desired deployments and reports their status.
+// It returns the joined errors of the changes that failed.
+func reconcileDeployments(ctx context.Context, c *http.Client, cfg clientConfig, st *state, desiredIDs map[string]struct{}, resolved map[string]resolvedDeployment) error {
 	// this map contains a plan that captures removals (nil entries) as well as updates and additions
 	plan := make(map[string]*resolvedDeployment, len(st.Deployments)+len(resolved))
 
@@ -398,9 +724,17 @@ func reconcileDeployments(st *state, desiredIDs map[string]struct{}, resolved ma
 		plan[depID] = &entryCopy
 	}
 
+	var errs []error
 	for depID, desired := range plan {
-		applyDeploymentChange(st, depID, desired)
+		status, err := applyDeploymentChange(ctx, cfg, st, depID, desired)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("deployment %s: %w", depID, err))
+		}
+		if status != nil {
+			reportDeploymentStatus(ctx, c, cfg, *status)
+		}
 	}
+	return errors.Join(errs...)
 }
 
 // resolveURL returns an absolute URL for a possibly relative reference.
@@ -421,39 +755,86 @@ func resolveURL(base, ref string) string {
 	return strings.TrimRight(base, "/") + "/" + ref
 }
 
-// applyDeploymentChange logs the action and mutates cached deployment state based on the desired descriptor
-func applyDeploymentChange(st *state, deploymentID string, desired *resolvedDeployment) {
+// applyDeploymentChange applies the change to the desired descriptor through the applier of
+// its deployment profile type and updates the cached deployment state on success. It returns
+// the status to report, or nil when nothing changed, and the error of a failed change. A
+// failed change leaves the cache as it was, so that it is retried.
+func applyDeploymentChange(ctx context.Context, cfg clientConfig, st *state, deploymentID string, desired *resolvedDeployment) (*common.DeploymentStatus, error) {
 	existing, have := st.Deployments[deploymentID]
+	current := appliedDeployment{ID: deploymentID, Digest: existing.Digest, Descriptor: existing.Descriptor}
 
 	if desired == nil {
 		if !have {
-			return
+			return nil, nil
+		}
+		actionf("undeploy", "deviceId=%s deploymentId=%s appId=%s name=%s digest=%s", cfg.DeviceID, deploymentID, existing.ApplicationID, existing.Name, existing.Digest)
+		if err := cfg.Appliers.forDeployment(current).Undeploy(ctx, current); err != nil {
+			errorf("undeploy failed deploymentId=%s err=%v", deploymentID, err)
+			return failedStatus(current, "UNDEPLOY_FAILED", err), err
 		}
-		actionf("undeploy", "deploymentId=%s appId=%s name=%s digest=%s", deploymentID, existing.ApplicationID, existing.Name, existing.Digest)
 		delete(st.Deployments, deploymentID)
-		return
+		status := newDeploymentStatus(deploymentID, existing.Digest, common.DeploymentStateRemoved, nil, nil)
+		return &status, nil
 	}
 
 	digest := desired.Digest
 	desc := desired.Descriptor
 
-	next := deploymentCacheEntry{Digest: digest}
+	next := deploymentCacheEntry{Digest: digest, Descriptor: desc}
 	if desc != nil {
 		next.ApplicationID = desc.Metadata.Annotations.ApplicationId
 		next.Name = desc.Metadata.Name
 	} else if have {
 		next.ApplicationID = existing.ApplicationID
 		next.Name = existing.Name
+		next.Descriptor = existing.Descriptor
+	}
+	if have && existing.Digest == digest {
+		tracef("noop deploymentId=%s appId=%s name=%s digest=%s", deploymentID, next.ApplicationID, next.Name, digest)
+		st.Deployments[deploymentID] = next // keeps a descriptor resolved from the bundle
+		return nil, nil
 	}
 
+	target := appliedDeployment{ID: deploymentID, Digest: digest, Descriptor: desc}
+	if desc == nil {
+		err := errors.New("descriptor not available")
+		errorf("apply failed deploymentId=%s digest=%s err=%v", deploymentID, digest, err)
+		return failedStatus(target, "APPLY_FAILED", err), err
+	}
+	applier := cfg.Appliers.forDeployment(target)
+
+	var err error
 	switch {
 	case !have:
-		actionf("deploy", "deploymentId=%s appId=%s name=%s digest=%s", deploymentID, next.ApplicationID, next.Name, digest)
-		st.Deployments[deploymentID] = next
-	case existing.Digest != digest:
-		actionf("update", "deploymentId=%s appId=%s name=%s oldDigest=%s newDigest=%s", deploymentID, next.ApplicationID, next.Name, existing.Digest, digest)
-		st.Deployments[deploymentID] = next
+		actionf("deploy", "deviceId=%s deploymentId=%s appId=%s name=%s digest=%s", cfg.DeviceID, deploymentID, next.ApplicationID, next.Name, digest)
+		err = applier.Deploy(ctx, target)
+	case current.Descriptor != nil && current.profileType() != target.profileType():
+		// the deployment moves to another runtime: remove it from the old one first
+		actionf("update", "deviceId=%s deploymentId=%s appId=%s name=%s oldDigest=%s newDigest=%s oldType=%s newType=%s", cfg.DeviceID, deploymentID, next.ApplicationID, next.Name, existing.Digest, digest, current.profileType(), target.profileType())
+		if err = cfg.Appliers.forDeployment(current).Undeploy(ctx, current); err == nil {
+			err = applier.Deploy(ctx, target)
+		}
 	default:
-		tracef("noop deploymentId=%s appId=%s name=%s digest=%s", deploymentID, next.ApplicationID, next.Name, digest)
+		actionf("update", "deviceId=%s deploymentId=%s appId=%s name=%s oldDigest=%s newDigest=%s", cfg.DeviceID, deploymentID, next.ApplicationID, next.Name, existing.Digest, digest)
+		err = applier.Update(ctx, current, target)
 	}
+	if err != nil {
+		errorf("apply failed deploymentId=%s digest=%s err=%v", deploymentID, digest, err)
+		return failedStatus(target, "APPLY_FAILED", err), err
+	}
+	st.Deployments[deploymentID] = next
+
+	state, err := applier.Status(ctx, target)
+	if err != nil {
+		warnf("status query failed deploymentId=%s err=%v", deploymentID, err)
+		return failedStatus(target, "STATUS_UNAVAILABLE", err), nil // applied, only its state is unknown
+	}
+	status := newDeploymentStatus(deploymentID, digest, state, desc, nil)
+	return &status, nil
+}
+
+func failedStatus(d appliedDeployment, code string, err error) *common.DeploymentStatus {
+	status := newDeploymentStatus(d.ID, d.Digest, common.DeploymentStateFailed, d.Descriptor,
+		&common.StatusError{Code: code, Message: truncate(err.Error(), maxStatusMessageBytes)})
+	return &status
 }
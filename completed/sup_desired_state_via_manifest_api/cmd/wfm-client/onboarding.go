@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"skeleton/pkg/common"
+)
+
+const (
+	identityFileName = "identity.json"
+	rootCAFileName   = "root-ca.pem"
+)
+
+// identity is the result of onboarding, stored in the state directory so that the
+// client keeps its client ID across restarts.
+type identity struct {
+	ClientID               string `json:"clientId"`
+	CertificateFingerprint string `json:"certificateFingerprint"`
+}
+
+// resolveDeviceID returns the stored client ID, or onboards with the client certificate
+// when the client has none yet. With the certificate's private key the client signs a
+// proof of possession; without it the server accepts the certificate only when it is
+// also the TLS client certificate.
+func resolveDeviceID(ctx context.Context, c *http.Client, cfg clientConfig, certPath, keyPath string) (string, error) {
+	identityPath := filepath.Join(cfg.StateDir, identityFileName)
+	raw, err := os.ReadFile(identityPath)
+	switch {
+	case err == nil:
+		var id identity
+		if err := json.Unmarshal(raw, &id); err != nil || id.ClientID == "" {
+			return "", fmt.Errorf("identity file %s is corrupt: %v", identityPath, err)
+		}
+		tracef("identity loaded clientId=%s", id.ClientID)
+		return id.ClientID, nil
+	case !errors.Is(err, os.ErrNotExist):
+		return "", fmt.Errorf("identity read failed: %w", err)
+	}
+
+	if certPath == "" {
+		return "", errors.New("no device ID: pass --device-id or --client-cert to onboard")
+	}
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return "", fmt.Errorf("client certificate read failed: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("no PEM certificate found in %s", certPath)
+	}
+	var key crypto.Signer
+	if keyPath != "" {
+		pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return "", fmt.Errorf("client key: %w", err)
+		}
+		var ok bool
+		if key, ok = pair.PrivateKey.(crypto.Signer); !ok {
+			return "", fmt.Errorf("private key %s cannot be used for signing", keyPath)
+		}
+	}
+
+	if err := fetchRootCertificate(ctx, c, cfg); err != nil {
+		warnf("root CA certificate not stored: %v", err)
+	}
+
+	clientID, err := onboard(ctx, c, cfg, certPEM, block.Bytes, key)
+	if err != nil {
+		return "", err
+	}
+	id := identity{ClientID: clientID, CertificateFingerprint: common.CalculateDigest(block.Bytes)}
+	raw, err = json.MarshalIndent(id, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("identity marshal failed: %w", err)
+	}
+	if err := writeFileAtomic(identityPath, raw); err != nil {
+		return "", fmt.Errorf("identity write failed: %w", err)
+	}
+	successf("onboarded clientId=%s", clientID)
+	return clientID, nil
+}
+
+func onboard(ctx context.Context, c *http.Client, cfg clientConfig, certPEM, certDER []byte, key crypto.Signer) (string, error) {
+	request := common.OnboardingRequest{
+		PublicCertificate: base64.StdEncoding.EncodeToString(certPEM),
+	}
+	if key != nil {
+		request.SignedAt = time.Now().UTC().Truncate(time.Second)
+		signature, err := common.SignOnboardingProof(key, certDER, request.SignedAt)
+		if err != nil {
+			return "", err
+		}
+		request.Signature = base64.StdEncoding.EncodeToString(signature)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("onboarding request marshal failed: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, resolveURL(cfg.BaseURL, "/api/v1/onboarding"), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("onboarding request build failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("onboarding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("onboarding read failed: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		var onboarded common.OnboardingResponse
+		if err := json.Unmarshal(raw, &onboarded); err != nil || onboarded.ClientId == "" {
+			return "", fmt.Errorf("onboarding response invalid: %v", err)
+		}
+		return onboarded.ClientId, nil
+	default:
+		var failure common.OnboardingErrorResponse
+		_ = json.Unmarshal(raw, &failure)
+		return "", fmt.Errorf("onboarding failed status=%d error=%q", resp.StatusCode, failure.Error)
+	}
+}
+
+// fetchRootCertificate stores the WFM root CA certificate in the state directory.
+func fetchRootCertificate(ctx context.Context, c *http.Client, cfg clientConfig) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resolveURL(cfg.BaseURL, "/api/v1/onboarding/certificate"), nil)
+	if err != nil {
+		return fmt.Errorf("root certificate request build failed: %w", err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("root certificate request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("unexpected root certificate status %d", resp.StatusCode)
+	}
+	var rootCert common.RootCertificateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rootCert); err != nil {
+		return fmt.Errorf("root certificate parse error: %w", err)
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(rootCert.Certificate)
+	if err != nil {
+		return fmt.Errorf("root certificate decode error: %w", err)
+	}
+	if block, _ := pem.Decode(certPEM); block == nil || block.Type != "CERTIFICATE" {
+		return errors.New("root certificate is not a PEM certificate")
+	}
+	return writeFileAtomic(filepath.Join(cfg.StateDir, rootCAFileName), certPEM)
+}
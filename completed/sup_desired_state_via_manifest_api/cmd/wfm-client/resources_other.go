@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errResourceDetectionUnsupported = errors.New("resource detection is only supported on Linux")
+
+func detectMemoryBytes() (uint64, error) {
+	return 0, errResourceDetectionUnsupported
+}
+
+func detectStorageBytes(string) (uint64, error) {
+	return 0, errResourceDetectionUnsupported
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const stateFileName = "state.json"
+
+// loadState reads the persisted client state. A missing file yields an empty state. A corrupt
+// file, or one written for another device, is an error: silently starting over would accept
+// any manifest version and defeat the rollback protection. With ResetState a state of
+// another device is replaced instead.
+func loadState(cfg clientConfig) (*state, error) {
+	path := filepath.Join(cfg.StateDir, stateFileName)
+	empty := &state{DeviceID: cfg.DeviceID, Deployments: map[string]deploymentCacheEntry{}}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var st state
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return nil, fmt.Errorf("%s is corrupt, remove it to resynchronize from scratch: %w", path, err)
+	}
+	if st.DeviceID != cfg.DeviceID {
+		if !cfg.ResetState {
+			return nil, fmt.Errorf("%s belongs to device %s, start with --reset-state to discard it", path, st.DeviceID)
+		}
+		warnf("discarding state of device %s, starting empty for device %s", st.DeviceID, cfg.DeviceID)
+		return empty, nil
+	}
+	if st.Deployments == nil {
+		st.Deployments = map[string]deploymentCacheEntry{}
+	}
+	tracef("state loaded manifestVersion=%d deployments=%d", st.ManifestVersion, len(st.Deployments))
+	return &st, nil
+}
+
+// saveState atomically replaces the persisted client state.
+func saveState(cfg clientConfig, st *state) error {
+	raw, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("state marshal failed: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(cfg.StateDir, stateFileName), raw); err != nil {
+		return fmt.Errorf("state write failed: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data so that readers (and a restart after a crash)
+// observe either the old or the new content, never a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// persist the directory entry of the renamed file
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"skeleton/pkg/common"
+)
+
+const (
+	deploymentStatusAPIVersion = "deployment.margo.org/v1alpha1"
+	// maxStatusMessageBytes is the longest error message the WFM accepts in a status report.
+	maxStatusMessageBytes = 1024
+)
+
+// newDeploymentStatus builds a status report. Components are taken from the descriptor,
+// all in the deployment's state, since the client applies a deployment as a whole.
+func newDeploymentStatus(deploymentID, digest, state string, desc *common.ApplicationDeploymentDescriptor, statusErr *common.StatusError) common.DeploymentStatus {
+	status := common.DeploymentStatus{
+		ApiVersion:   deploymentStatusAPIVersion,
+		Kind:         common.DeploymentStatusKind,
+		DeploymentId: deploymentID,
+		Digest:       digest,
+		Status:       common.DeploymentStatusState{State: state, Error: statusErr},
+		Components:   []common.ComponentStatus{},
+	}
+	if desc != nil {
+		for _, component := range desc.Spec.DeploymentProfile.Components {
+			status.Components = append(status.Components, common.ComponentStatus{Name: component.Name, State: state})
+		}
+	}
+	return status
+}
+
+// reportDeploymentStatus sends a status report to the WFM. Reports are best effort: a
+// failure is logged and the next reconcile action reports the then current state.
+func reportDeploymentStatus(ctx context.Context, c *http.Client, cfg clientConfig, status common.DeploymentStatus) {
+	if err := postDeploymentStatus(ctx, c, cfg, status); err != nil {
+		warnf("status report failed deviceId=%s deploymentId=%s state=%s err=%v", cfg.DeviceID, status.DeploymentId, status.Status.State, err)
+		return
+	}
+	tracef("status reported deviceId=%s deploymentId=%s state=%s digest=%s", cfg.DeviceID, status.DeploymentId, status.Status.State, status.Digest)
+}
+
+func postDeploymentStatus(ctx context.Context, c *http.Client, cfg clientConfig, status common.DeploymentStatus) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("status marshal failed: %w", err)
+	}
+	statusURL := resolveURL(cfg.BaseURL, fmt.Sprintf("/api/v1/client/%s/deployment/%s/status", cfg.DeviceID, url.PathEscape(status.DeploymentId)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, statusURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("status request build failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("status request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// newTLSConfig returns the TLS 1.3 configuration used to reach the WFM, presenting the
+// client certificate when one is configured.
+func newTLSConfig(certPath, keyPath, caPath string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS13}
+
+	if (certPath == "") != (keyPath == "") {
+		return nil, errors.New("--tls-cert and --tls-key must be set together")
+	}
+	if certPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if caPath != "" {
+		data, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("CA certificates: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caPath)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"skeleton/pkg/wfm/adapter/persistence/postgresdb"
+	"skeleton/pkg/wfm/adapter/persistence/repository"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb"
+	"skeleton/pkg/wfm/core/port"
+	"skeleton/pkg/wfm/core/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dbDriverSQLite   = "sqlite"
+	dbDriverPostgres = "postgres"
+)
+
+type dataStore interface {
+	repository.DataStore
+	Migrate(ctx context.Context) error
+	Close() error
+}
+
+// openDataStore connects to the selected database, migrates it and returns it together with
+// the notifier of manifest changes. With PostgreSQL, several replicas may share the database;
+// manifest changes are published to the other replicas so that their waiting requests and
+// event streams wake up.
+func openDataStore(ctx context.Context, driver, dbPath, dsn string) (_ dataStore, _ port.ManifestNotifier, err error) {
+	var ds dataStore
+	if driver == dbDriverPostgres {
+		ds, err = postgresdb.New(ctx, dsn)
+	} else {
+		ds, err = sqlitedb.New(ctx, dbPath)
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to initialize datastore")
+		return nil, nil, err
+	}
+	if err = ds.Migrate(ctx); err != nil {
+		logrus.WithError(err).Error("Failed to migrate database")
+		_ = ds.Close()
+		return nil, nil, err
+	}
+
+	notifier := service.NewManifestNotifier()
+	if pg, ok := ds.(*postgresdb.DataStore); ok {
+		shared := postgresdb.NewManifestNotifier(pg, notifier)
+		go shared.Listen(ctx)
+		return ds, shared, nil
+	}
+	return ds, notifier, nil
+}
@@ -0,0 +1,260 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Media types of the State Manifest representations defined by the SUP.
+const (
+	ManifestMediaType       = "application/vnd.margo.manifest.v1+json"
+	SignedManifestMediaType = "application/vnd.margo.manifest.v1.jws+json"
+)
+
+// JWS algorithms supported for signed manifests (see SUP "Cryptographic Profile").
+const (
+	AlgorithmES256 = "ES256"
+	AlgorithmRS256 = "RS256"
+)
+
+const minRSAKeyBits = 3072
+
+// FlattenedJWS is the Flattened JWS JSON Serialization (RFC 7515, Section 7.2.2).
+type FlattenedJWS struct {
+	Payload   string `json:"payload"`
+	Protected string `json:"protected"`
+	Signature string `json:"signature"`
+}
+
+// JWSHeader is the JWS Protected Header. Jwk, Jku and Crit are only decoded so
+// that verifiers can reject them; signers never emit them.
+type JWSHeader struct {
+	Alg  string          `json:"alg"`
+	Kid  string          `json:"kid,omitempty"`
+	Jwk  json.RawMessage `json:"jwk,omitempty"`
+	Jku  string          `json:"jku,omitempty"`
+	Crit []string        `json:"crit,omitempty"`
+}
+
+// JWSSigner produces Flattened JWS objects with an ES256 or RS256 key.
+// Signatures are deterministic (RFC 6979 for ECDSA, PKCS #1 v1.5 for RSA), so
+// signing the same payload twice yields the same bytes and therefore the same ETag.
+type JWSSigner struct {
+	key crypto.Signer
+	alg string
+	kid string
+}
+
+func NewJWSSigner(key crypto.Signer, kid string) (*JWSSigner, error) {
+	alg, err := jwsAlgorithm(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &JWSSigner{key: key, alg: alg, kid: kid}, nil
+}
+
+func (s *JWSSigner) Algorithm() string {
+	return s.alg
+}
+
+// Sign wraps payload into a serialized Flattened JWS JSON object.
+func (s *JWSSigner) Sign(payload []byte) ([]byte, error) {
+	header, err := json.Marshal(JWSHeader{Alg: s.alg, Kid: s.kid})
+	if err != nil {
+		return nil, fmt.Errorf("jws: failed to marshal protected header: %w", err)
+	}
+	jws := FlattenedJWS{
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+		Protected: base64.RawURLEncoding.EncodeToString(header),
+	}
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *ecdsa.PrivateKey:
+		// a nil random source selects deterministic RFC 6979 signatures
+		der, err := key.Sign(nil, digest[:], crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("jws: failed to sign payload: %w", err)
+		}
+		if signature, err = ecdsaDERToRaw(der, key.Curve); err != nil {
+			return nil, err
+		}
+	default:
+		signature, err = s.key.Sign(nil, digest[:], crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("jws: failed to sign payload: %w", err)
+		}
+	}
+	jws.Signature = base64.RawURLEncoding.EncodeToString(signature)
+
+	return json.Marshal(jws)
+}
+
+// VerifyJWS verifies a serialized Flattened JWS object against a set of trusted
+// public keys and returns the decoded payload and protected header. The embedded
+// jwk and jku header parameters are never trusted; their presence fails verification.
+func VerifyJWS(raw []byte, trustedKeys []crypto.PublicKey) ([]byte, *JWSHeader, error) {
+	var jws FlattenedJWS
+	if err := json.Unmarshal(raw, &jws); err != nil {
+		return nil, nil, fmt.Errorf("jws: malformed serialization: %w", err)
+	}
+	if jws.Payload == "" || jws.Protected == "" || jws.Signature == "" {
+		return nil, nil, errors.New("jws: payload, protected and signature are required")
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err != nil {
+		return nil, nil, fmt.Errorf("jws: malformed protected header encoding: %w", err)
+	}
+	var header JWSHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, nil, fmt.Errorf("jws: malformed protected header: %w", err)
+	}
+	if len(header.Jwk) > 0 || header.Jku != "" {
+		return nil, &header, errors.New("jws: jwk and jku header parameters are not trusted")
+	}
+	if len(header.Crit) > 0 {
+		return nil, &header, fmt.Errorf("jws: unsupported critical header parameters %v", header.Crit)
+	}
+	if header.Alg != AlgorithmES256 && header.Alg != AlgorithmRS256 {
+		return nil, &header, fmt.Errorf("jws: unsupported algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(jws.Signature)
+	if err != nil {
+		return nil, &header, fmt.Errorf("jws: malformed signature encoding: %w", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		return nil, &header, fmt.Errorf("jws: malformed payload encoding: %w", err)
+	}
+
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	for _, key := range trustedKeys {
+		alg, err := jwsAlgorithm(key)
+		if err != nil || alg != header.Alg {
+			continue
+		}
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			if len(signature) != 64 {
+				continue
+			}
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if ecdsa.Verify(key, digest[:], r, s) {
+				return payload, &header, nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return payload, &header, nil
+			}
+		}
+	}
+	return nil, &header, errors.New("jws: signature does not match any trusted key")
+}
+
+// LoadPrivateKey reads a PEM encoded PKCS #8, SEC 1 (EC) or PKCS #1 (RSA) private key.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEMBlock(path)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key %s cannot be used for signing", path)
+	}
+	return signer, nil
+}
+
+// LoadPublicKey reads a PEM encoded PKIX public key or X.509 certificate.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEMBlock(path)
+	if err != nil {
+		return nil, err
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+}
+
+func readPEMBlock(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
+
+func jwsAlgorithm(key crypto.PublicKey) (string, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("jws: unsupported ECDSA curve %s, expected P-256", key.Curve.Params().Name)
+		}
+		return AlgorithmES256, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return "", fmt.Errorf("jws: RSA key has %d bits, at least %d required", key.N.BitLen(), minRSAKeyBits)
+		}
+		return AlgorithmRS256, nil
+	default:
+		return "", fmt.Errorf("jws: unsupported key type %T", key)
+	}
+}
+
+func ecdsaDERToRaw(der []byte, curve elliptic.Curve) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("jws: malformed ECDSA signature: %w", err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// onboardingProofMessage is the message signed to prove possession of the private key
+// of an onboarding certificate. It binds the proof to the certificate's fingerprint
+// and to the signing time, so that it can neither be reused for another certificate
+// nor replayed once it has expired.
+func onboardingProofMessage(certificate []byte, signedAt time.Time) []byte {
+	return []byte("margo-onboarding\n" + CalculateDigest(certificate) + "\n" + signedAt.UTC().Format(time.RFC3339))
+}
+
+// SignOnboardingProof signs the proof of possession for the DER encoded certificate with
+// its private key: SHA-256 with PKCS #1 v1.5 for RSA, ASN.1 encoded SHA-256 for ECDSA
+// and plain Ed25519.
+func SignOnboardingProof(key crypto.Signer, certificate []byte, signedAt time.Time) ([]byte, error) {
+	message := onboardingProofMessage(certificate, signedAt)
+	var signature []byte
+	var err error
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		signature, err = key.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		signature, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("onboarding: failed to sign proof: %w", err)
+	}
+	return signature, nil
+}
+
+// VerifyOnboardingProof verifies a signature made by SignOnboardingProof with the public key of cert.
+func VerifyOnboardingProof(cert *x509.Certificate, signedAt time.Time, signature []byte) error {
+	var algorithm x509.SignatureAlgorithm
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		algorithm = x509.SHA256WithRSA
+	case x509.ECDSA:
+		algorithm = x509.ECDSAWithSHA256
+	case x509.Ed25519:
+		algorithm = x509.PureEd25519
+	default:
+		return fmt.Errorf("onboarding: unsupported public key algorithm %s", cert.PublicKeyAlgorithm)
+	}
+	if err := cert.CheckSignature(algorithm, onboardingProofMessage(cert.Raw, signedAt), signature); err != nil {
+		return fmt.Errorf("onboarding: proof signature invalid: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RenderedComponentKind is the kind of the document the WFM renders per deployment component.
+const RenderedComponentKind = "RenderedComponent"
+
+// RenderedComponent is a deployment component with the deployment parameters resolved into
+// its values, so that a device can apply it without a template engine.
+type RenderedComponent struct {
+	ApiVersion string                    `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string                    `yaml:"kind" json:"kind"`
+	Metadata   RenderedComponentMetadata `yaml:"metadata" json:"metadata"`
+	Spec       RenderedComponentSpec     `yaml:"spec" json:"spec"`
+}
+
+type RenderedComponentMetadata struct {
+	DeploymentId string `yaml:"deploymentId" json:"deploymentId"`
+	Name         string `yaml:"name" json:"name"`
+	Namespace    string `yaml:"namespace" json:"namespace"`
+}
+
+type RenderedComponentSpec struct {
+	Type       string            `yaml:"type" json:"type"` // deploymentProfile.type
+	Properties map[string]string `yaml:"properties" json:"properties"`
+	Values     map[string]any    `yaml:"values" json:"values"`
+}
+
+// ComponentValues builds the values document of a component from the parameters whose
+// targets apply to it. A target without components applies to all components of the
+// deployment. Pointers are dot separated paths into the values, e.g. settings.limits.cpu.
+func ComponentValues(descriptor *ApplicationDeploymentDescriptor, component string) (map[string]any, error) {
+	names := make([]string, 0, len(descriptor.Spec.Parameters))
+	for name := range descriptor.Spec.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := map[string]any{}
+	for _, name := range names {
+		param := descriptor.Spec.Parameters[name]
+		for _, target := range param.Targets {
+			if len(target.Components) > 0 && !slices.Contains(target.Components, component) {
+				continue
+			}
+			if err := setValue(values, target.Pointer, param.Value); err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", name, err)
+			}
+		}
+	}
+	return values, nil
+}
+
+func setValue(values map[string]any, pointer string, value any) error {
+	keys := strings.Split(pointer, ".")
+	for i, key := range keys {
+		if key == "" {
+			return fmt.Errorf("invalid pointer %q", pointer)
+		}
+		if i == len(keys)-1 {
+			if _, exists := values[key]; exists {
+				return fmt.Errorf("pointer %q conflicts with another parameter", pointer)
+			}
+			values[key] = value
+			return nil
+		}
+		switch next := values[key].(type) {
+		case nil:
+			child := map[string]any{}
+			values[key] = child
+			values = child
+		case map[string]any:
+			values = next
+		default:
+			return fmt.Errorf("pointer %q conflicts with another parameter", pointer)
+		}
+	}
+	return nil
+}
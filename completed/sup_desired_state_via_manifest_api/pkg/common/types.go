@@ -2,6 +2,12 @@ package common
 
 // Shared types between server and client.
 
+import "time"
+
+// ManifestMaxWaitHeader advertises watch mode on manifest responses: the longest wait, in
+// seconds, a conditional manifest request may ask for with the wait query parameter.
+const ManifestMaxWaitHeader = "Wfm-Max-Manifest-Wait"
+
 // ApplicationDeploymentDescriptor represents the YAML structure accepted by the API
 type ApplicationDeploymentDescriptor struct {
 	ApiVersion string              `yaml:"apiVersion" json:"apiVersion" validate:"required"`
@@ -37,7 +43,9 @@ type DeploymentComponent struct {
 }
 
 type ApplicationParam struct {
-	Value   string                   `yaml:"value" json:"value" validate:"required"`
+	// Value keeps its YAML type (string, number, boolean, list or map), so that the rendered
+	// values do too.
+	Value   any                      `yaml:"value" json:"value" validate:"required"`
 	Targets []ApplicationParamTarget `yaml:"targets" json:"targets" validate:"required"`
 }
 
@@ -53,14 +61,117 @@ type GetDeploymentManifestResponse struct {
 	Deployments     []DeploymentDTO `json:"deployments"`
 }
 
+// SizeBytes fields are advisory estimates of the decoded payload length. They are
+// omitted when unknown and MUST NOT be used for integrity checks.
 type BundleDTO struct {
 	MediaType string `json:"mediaType"`
 	Digest    string `json:"digest"`
+	SizeBytes uint64 `json:"sizeBytes,omitempty"`
 	URL       string `json:"url"`
 }
 
 type DeploymentDTO struct {
 	DeploymentId string `json:"deploymentId"`
 	Digest       string `json:"digest"`
+	SizeBytes    uint64 `json:"sizeBytes,omitempty"`
 	URL          string `json:"url"`
+	// Components lists the rendered components; only present when the WFM renders deployments.
+	Components []ComponentDTO `json:"components,omitempty"`
+	// Target is the device the deployment is for; only present in the aggregated manifest of
+	// a gateway.
+	Target string `json:"target,omitempty"`
+}
+
+type ComponentDTO struct {
+	Name      string `json:"name"`
+	Digest    string `json:"digest"`
+	SizeBytes uint64 `json:"sizeBytes,omitempty"`
+	URL       string `json:"url"`
+}
+
+// Onboarding DTOs (see margo_workload_api_wip.yaml). Certificates are Base64-encoded PEM.
+type RootCertificateResponse struct {
+	Certificate string `json:"certificate"`
+}
+
+// OnboardingRequest proves possession of the certificate's private key either by the same
+// certificate being presented in the TLS handshake, or by Signature: the Base64 encoded
+// signature of the certificate's fingerprint and SignedAt (see SignOnboardingProof).
+type OnboardingRequest struct {
+	PublicCertificate string    `json:"public_certificate"`
+	SignedAt          time.Time `json:"signed_at,omitzero"`
+	Signature         string    `json:"signature,omitempty"`
+}
+
+type OnboardingResponse struct {
+	ClientId string `json:"client_id"`
+}
+
+type OnboardingErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// DeviceCapabilitiesKind is the kind of the DeviceCapabilities document (see margo_workload_api_wip.yaml).
+const DeviceCapabilitiesKind = "DeviceCapabilities"
+
+type DeviceCapabilities struct {
+	ApiVersion string                       `json:"apiVersion"`
+	Kind       string                       `json:"kind"`
+	Properties DeviceCapabilitiesProperties `json:"properties"`
+}
+
+type DeviceCapabilitiesProperties struct {
+	Id           string          `json:"id"`
+	Vendor       string          `json:"vendor"`
+	ModelNumber  string          `json:"modelNumber"`
+	SerialNumber string          `json:"serialNumber"`
+	Roles        []string        `json:"roles"`
+	Resources    DeviceResources `json:"resources"`
+}
+
+type DeviceResources struct {
+	Cpu     DeviceCpu `json:"cpu"`
+	Memory  string    `json:"memory"`
+	Storage string    `json:"storage"`
+}
+
+type DeviceCpu struct {
+	Cores float64 `json:"cores"`
+}
+
+// DeploymentStatusKind is the kind of the DeploymentStatus document (see margo_workload_api_wip.yaml).
+const DeploymentStatusKind = "DeploymentStatus"
+
+// Deployment and component states. Removed extends the WIP API to report undeployments.
+const (
+	DeploymentStatePending    = "Pending"
+	DeploymentStateInstalling = "Installing"
+	DeploymentStateInstalled  = "Installed"
+	DeploymentStateFailed     = "Failed"
+	DeploymentStateRemoved    = "Removed"
+)
+
+type DeploymentStatus struct {
+	ApiVersion   string                `json:"apiVersion"`
+	Kind         string                `json:"kind"`
+	DeploymentId string                `json:"deploymentId"`
+	Digest       string                `json:"digest,omitempty"` // applied descriptor digest
+	Status       DeploymentStatusState `json:"status"`
+	Components   []ComponentStatus     `json:"components"`
+}
+
+type DeploymentStatusState struct {
+	State string       `json:"state"`
+	Error *StatusError `json:"error,omitempty"`
+}
+
+type ComponentStatus struct {
+	Name  string       `json:"name"`
+	State string       `json:"state"`
+	Error *StatusError `json:"error,omitempty"`
+}
+
+type StatusError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
 }
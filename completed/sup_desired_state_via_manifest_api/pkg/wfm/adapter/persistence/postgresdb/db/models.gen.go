@@ -0,0 +1,193 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+type ApplicationDeployment struct {
+	ID                string
+	DescriptorDigest  string
+	DeviceID          string
+	FleetDeploymentID string
+}
+
+type ApplicationDeploymentComponent struct {
+	DeviceID     string
+	DeploymentID string
+	Name         string
+	Position     int64
+	Digest       string
+}
+
+type ApplicationDeploymentManifest struct {
+	DeviceID     string
+	Version      int64
+	BundleDigest sql.NullString
+}
+
+type BundleBlob struct {
+	Digest            string
+	Archive           []byte
+	SizeBytes         int64
+	CreatedAt         time.Time
+	UnreferencedSince sql.NullTime
+}
+
+type DecommissionedDevice struct {
+	ID              string
+	ManifestVersion int64
+}
+
+type DeploymentBlob struct {
+	Digest            string
+	Descriptor        []byte
+	SizeBytes         int64
+	CreatedAt         time.Time
+	UnreferencedSince sql.NullTime
+}
+
+type DeploymentComponentStatus struct {
+	StatusID     int64
+	Name         string
+	State        string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+type DeploymentStatus struct {
+	ID           int64
+	DeviceID     string
+	DeploymentID string
+	ApiVersion   string
+	Digest       string
+	State        string
+	ErrorCode    string
+	ErrorMessage string
+	ReportedAt   time.Time
+}
+
+type Device struct {
+	ID          string
+	DisplayName string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type DeviceBundleBlob struct {
+	DeviceID string
+	Digest   string
+}
+
+type DeviceCapability struct {
+	DeviceID     string
+	ApiVersion   string
+	Vendor       string
+	ModelNumber  string
+	SerialNumber string
+	CpuCores     float64
+	Memory       string
+	Storage      string
+	ReportedAt   time.Time
+}
+
+type DeviceCapabilityRole struct {
+	DeviceID string
+	Role     string
+}
+
+type DeviceCertificate struct {
+	Fingerprint string
+	DeviceID    string
+	Certificate []byte
+	Subject     string
+	NotAfter    time.Time
+	CreatedAt   time.Time
+}
+
+type DeviceComponentBlob struct {
+	DeviceID     string
+	DeploymentID string
+	Name         string
+	Digest       string
+}
+
+type DeviceDeploymentBlob struct {
+	DeviceID     string
+	DeploymentID string
+	Digest       string
+}
+
+type DeviceGateway struct {
+	DeviceID  string
+	GatewayID string
+}
+
+type DeviceLabel struct {
+	DeviceID string
+	Key      string
+	Value    string
+}
+
+type FleetDeployment struct {
+	ID               string
+	DescriptorDigest string
+	DeviceGroup      string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type FleetDeploymentSelectorLabel struct {
+	FleetDeploymentID string
+	Key               string
+	Value             string
+}
+
+type GatewayManifest struct {
+	GatewayID    string
+	Version      int64
+	BundleDigest sql.NullString
+}
+
+type ManifestEvent struct {
+	ID              int64
+	DeviceID        string
+	ManifestVersion int64
+	ManifestEtag    string
+	PublishedAt     time.Time
+}
+
+type ManifestEventDeployment struct {
+	EventID      int64
+	DeploymentID string
+}
+
+type ManifestHistory struct {
+	DeviceID     string
+	Version      int64
+	BundleDigest sql.NullString
+	PublishedAt  time.Time
+}
+
+type ManifestHistoryComponent struct {
+	DeviceID     string
+	Version      int64
+	Target       string
+	DeploymentID string
+	Name         string
+	Position     int64
+	Digest       string
+}
+
+type ManifestHistoryDeployment struct {
+	DeviceID          string
+	Version           int64
+	Target            string
+	DeploymentID      string
+	DescriptorDigest  string
+	FleetDeploymentID string
+}
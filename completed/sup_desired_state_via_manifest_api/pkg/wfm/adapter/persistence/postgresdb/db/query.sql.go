@@ -0,0 +1,2254 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: query.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const createDevice = `-- name: CreateDevice :exec
+INSERT INTO devices (
+    id, display_name, created_at, updated_at
+) VALUES (
+    $1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+)
+`
+
+type CreateDeviceParams struct {
+	ID          string
+	DisplayName string
+}
+
+func (q *Queries) CreateDevice(ctx context.Context, arg CreateDeviceParams) error {
+	_, err := q.db.ExecContext(ctx, createDevice, arg.ID, arg.DisplayName)
+	return err
+}
+
+const createFleetDeployment = `-- name: CreateFleetDeployment :exec
+INSERT INTO fleet_deployments (
+    id, descriptor_digest, device_group, created_at, updated_at
+) VALUES (
+    $1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+)
+`
+
+type CreateFleetDeploymentParams struct {
+	ID               string
+	DescriptorDigest string
+	DeviceGroup      string
+}
+
+func (q *Queries) CreateFleetDeployment(ctx context.Context, arg CreateFleetDeploymentParams) error {
+	_, err := q.db.ExecContext(ctx, createFleetDeployment, arg.ID, arg.DescriptorDigest, arg.DeviceGroup)
+	return err
+}
+
+const deleteCollectableBundleBlobs = `-- name: DeleteCollectableBundleBlobs :execrows
+DELETE FROM bundle_blobs
+WHERE unreferenced_since < $1
+`
+
+func (q *Queries) DeleteCollectableBundleBlobs(ctx context.Context, unreferencedBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteCollectableBundleBlobs, unreferencedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteCollectableDeploymentBlobs = `-- name: DeleteCollectableDeploymentBlobs :execrows
+DELETE FROM deployment_blobs
+WHERE unreferenced_since < $1
+`
+
+func (q *Queries) DeleteCollectableDeploymentBlobs(ctx context.Context, unreferencedBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteCollectableDeploymentBlobs, unreferencedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteDecommissionedDevice = `-- name: DeleteDecommissionedDevice :exec
+DELETE FROM decommissioned_devices
+WHERE id = $1
+`
+
+func (q *Queries) DeleteDecommissionedDevice(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteDecommissionedDevice, id)
+	return err
+}
+
+const deleteDeployment = `-- name: DeleteDeployment :exec
+DELETE FROM application_deployments
+WHERE device_id = $1 AND id = $2
+`
+
+type DeleteDeploymentParams struct {
+	DeviceID string
+	ID       string
+}
+
+func (q *Queries) DeleteDeployment(ctx context.Context, arg DeleteDeploymentParams) error {
+	_, err := q.db.ExecContext(ctx, deleteDeployment, arg.DeviceID, arg.ID)
+	return err
+}
+
+const deleteDeploymentComponents = `-- name: DeleteDeploymentComponents :exec
+DELETE FROM application_deployment_components
+WHERE device_id = $1 AND deployment_id = $2
+`
+
+type DeleteDeploymentComponentsParams struct {
+	DeviceID     string
+	DeploymentID string
+}
+
+func (q *Queries) DeleteDeploymentComponents(ctx context.Context, arg DeleteDeploymentComponentsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteDeploymentComponents, arg.DeviceID, arg.DeploymentID)
+	return err
+}
+
+const deleteDeploymentsByDeviceId = `-- name: DeleteDeploymentsByDeviceId :exec
+DELETE FROM application_deployments
+WHERE device_id = $1
+`
+
+func (q *Queries) DeleteDeploymentsByDeviceId(ctx context.Context, deviceID string) error {
+	_, err := q.db.ExecContext(ctx, deleteDeploymentsByDeviceId, deviceID)
+	return err
+}
+
+const deleteDevice = `-- name: DeleteDevice :exec
+DELETE FROM devices
+WHERE id = $1
+`
+
+func (q *Queries) DeleteDevice(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteDevice, id)
+	return err
+}
+
+const deleteDeviceCapabilityRoles = `-- name: DeleteDeviceCapabilityRoles :exec
+DELETE FROM device_capability_roles
+WHERE device_id = $1
+`
+
+func (q *Queries) DeleteDeviceCapabilityRoles(ctx context.Context, deviceID string) error {
+	_, err := q.db.ExecContext(ctx, deleteDeviceCapabilityRoles, deviceID)
+	return err
+}
+
+const deleteDeviceGateway = `-- name: DeleteDeviceGateway :exec
+DELETE FROM device_gateways
+WHERE device_id = $1
+`
+
+func (q *Queries) DeleteDeviceGateway(ctx context.Context, deviceID string) error {
+	_, err := q.db.ExecContext(ctx, deleteDeviceGateway, deviceID)
+	return err
+}
+
+const deleteDeviceLabels = `-- name: DeleteDeviceLabels :exec
+DELETE FROM device_labels
+WHERE device_id = $1
+`
+
+func (q *Queries) DeleteDeviceLabels(ctx context.Context, deviceID string) error {
+	_, err := q.db.ExecContext(ctx, deleteDeviceLabels, deviceID)
+	return err
+}
+
+const deleteFleetDeployment = `-- name: DeleteFleetDeployment :exec
+DELETE FROM fleet_deployments
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFleetDeployment(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteFleetDeployment, id)
+	return err
+}
+
+const deleteFleetDeploymentSelectorLabels = `-- name: DeleteFleetDeploymentSelectorLabels :exec
+DELETE FROM fleet_deployment_selector_labels
+WHERE fleet_deployment_id = $1
+`
+
+func (q *Queries) DeleteFleetDeploymentSelectorLabels(ctx context.Context, fleetDeploymentID string) error {
+	_, err := q.db.ExecContext(ctx, deleteFleetDeploymentSelectorLabels, fleetDeploymentID)
+	return err
+}
+
+const deleteManifestByDeviceId = `-- name: DeleteManifestByDeviceId :exec
+DELETE FROM application_deployment_manifests
+WHERE device_id = $1
+`
+
+func (q *Queries) DeleteManifestByDeviceId(ctx context.Context, deviceID string) error {
+	_, err := q.db.ExecContext(ctx, deleteManifestByDeviceId, deviceID)
+	return err
+}
+
+const getCollectableBundleBlobStats = `-- name: GetCollectableBundleBlobStats :one
+SELECT COUNT(*) AS blobs, CAST(COALESCE(SUM(size_bytes), 0) AS BIGINT) AS size_bytes
+FROM bundle_blobs
+WHERE unreferenced_since < $1
+`
+
+type GetCollectableBundleBlobStatsRow struct {
+	Blobs     int64
+	SizeBytes int64
+}
+
+func (q *Queries) GetCollectableBundleBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (GetCollectableBundleBlobStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getCollectableBundleBlobStats, unreferencedBefore)
+	var i GetCollectableBundleBlobStatsRow
+	err := row.Scan(&i.Blobs, &i.SizeBytes)
+	return i, err
+}
+
+const getCollectableDeploymentBlobStats = `-- name: GetCollectableDeploymentBlobStats :one
+SELECT COUNT(*) AS blobs, CAST(COALESCE(SUM(size_bytes), 0) AS BIGINT) AS size_bytes
+FROM deployment_blobs
+WHERE unreferenced_since < $1
+`
+
+type GetCollectableDeploymentBlobStatsRow struct {
+	Blobs     int64
+	SizeBytes int64
+}
+
+func (q *Queries) GetCollectableDeploymentBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (GetCollectableDeploymentBlobStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getCollectableDeploymentBlobStats, unreferencedBefore)
+	var i GetCollectableDeploymentBlobStatsRow
+	err := row.Scan(&i.Blobs, &i.SizeBytes)
+	return i, err
+}
+
+const getDecommissionedManifestVersion = `-- name: GetDecommissionedManifestVersion :one
+SELECT manifest_version FROM decommissioned_devices
+WHERE id = $1
+`
+
+func (q *Queries) GetDecommissionedManifestVersion(ctx context.Context, id string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getDecommissionedManifestVersion, id)
+	var manifest_version int64
+	err := row.Scan(&manifest_version)
+	return manifest_version, err
+}
+
+const getDeploymentByIdAndDigest = `-- name: GetDeploymentByIdAndDigest :one
+SELECT d.id, b.descriptor, d.descriptor_digest, d.device_id
+FROM application_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = $1 AND d.id = $2 AND d.descriptor_digest = $3
+`
+
+type GetDeploymentByIdAndDigestParams struct {
+	DeviceID         string
+	ID               string
+	DescriptorDigest string
+}
+
+type GetDeploymentByIdAndDigestRow struct {
+	ID               string
+	Descriptor       []byte
+	DescriptorDigest string
+	DeviceID         string
+}
+
+func (q *Queries) GetDeploymentByIdAndDigest(ctx context.Context, arg GetDeploymentByIdAndDigestParams) (GetDeploymentByIdAndDigestRow, error) {
+	row := q.db.QueryRowContext(ctx, getDeploymentByIdAndDigest, arg.DeviceID, arg.ID, arg.DescriptorDigest)
+	var i GetDeploymentByIdAndDigestRow
+	err := row.Scan(
+		&i.ID,
+		&i.Descriptor,
+		&i.DescriptorDigest,
+		&i.DeviceID,
+	)
+	return i, err
+}
+
+const getDeploymentComponentStatusesByStatusIds = `-- name: GetDeploymentComponentStatusesByStatusIds :many
+SELECT status_id, name, state, error_code, error_message FROM deployment_component_statuses
+WHERE status_id = ANY($1::bigint[])
+ORDER BY status_id, name
+`
+
+func (q *Queries) GetDeploymentComponentStatusesByStatusIds(ctx context.Context, statusIds []int64) ([]DeploymentComponentStatus, error) {
+	rows, err := q.db.QueryContext(ctx, getDeploymentComponentStatusesByStatusIds, pq.Array(statusIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeploymentComponentStatus
+	for rows.Next() {
+		var i DeploymentComponentStatus
+		if err := rows.Scan(
+			&i.StatusID,
+			&i.Name,
+			&i.State,
+			&i.ErrorCode,
+			&i.ErrorMessage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeploymentComponentsByDeviceId = `-- name: GetDeploymentComponentsByDeviceId :many
+SELECT c.deployment_id, c.name, c.digest, b.descriptor AS artifact, b.size_bytes
+FROM application_deployment_components c
+JOIN deployment_blobs b ON b.digest = c.digest
+WHERE c.device_id = $1
+ORDER BY c.deployment_id, c.position
+`
+
+type GetDeploymentComponentsByDeviceIdRow struct {
+	DeploymentID string
+	Name         string
+	Digest       string
+	Artifact     []byte
+	SizeBytes    int64
+}
+
+func (q *Queries) GetDeploymentComponentsByDeviceId(ctx context.Context, deviceID string) ([]GetDeploymentComponentsByDeviceIdRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDeploymentComponentsByDeviceId, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDeploymentComponentsByDeviceIdRow
+	for rows.Next() {
+		var i GetDeploymentComponentsByDeviceIdRow
+		if err := rows.Scan(
+			&i.DeploymentID,
+			&i.Name,
+			&i.Digest,
+			&i.Artifact,
+			&i.SizeBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeploymentsByDeviceId = `-- name: GetDeploymentsByDeviceId :many
+SELECT d.id, b.descriptor, b.size_bytes, d.descriptor_digest, d.device_id, d.fleet_deployment_id
+FROM application_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = $1
+ORDER BY d.id
+`
+
+type GetDeploymentsByDeviceIdRow struct {
+	ID                string
+	Descriptor        []byte
+	SizeBytes         int64
+	DescriptorDigest  string
+	DeviceID          string
+	FleetDeploymentID string
+}
+
+func (q *Queries) GetDeploymentsByDeviceId(ctx context.Context, deviceID string) ([]GetDeploymentsByDeviceIdRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDeploymentsByDeviceId, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDeploymentsByDeviceIdRow
+	for rows.Next() {
+		var i GetDeploymentsByDeviceIdRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Descriptor,
+			&i.SizeBytes,
+			&i.DescriptorDigest,
+			&i.DeviceID,
+			&i.FleetDeploymentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDevice = `-- name: GetDevice :one
+SELECT id, display_name, created_at, updated_at FROM devices
+WHERE id = $1
+`
+
+func (q *Queries) GetDevice(ctx context.Context, id string) (Device, error) {
+	row := q.db.QueryRowContext(ctx, getDevice, id)
+	var i Device
+	err := row.Scan(
+		&i.ID,
+		&i.DisplayName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getDeviceBundleBlob = `-- name: GetDeviceBundleBlob :one
+SELECT b.digest, b.archive, b.size_bytes
+FROM device_bundle_blobs a
+JOIN bundle_blobs b ON b.digest = a.digest
+WHERE a.device_id = $1 AND a.digest = $2
+`
+
+type GetDeviceBundleBlobParams struct {
+	DeviceID string
+	Digest   string
+}
+
+type GetDeviceBundleBlobRow struct {
+	Digest    string
+	Archive   []byte
+	SizeBytes int64
+}
+
+func (q *Queries) GetDeviceBundleBlob(ctx context.Context, arg GetDeviceBundleBlobParams) (GetDeviceBundleBlobRow, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceBundleBlob, arg.DeviceID, arg.Digest)
+	var i GetDeviceBundleBlobRow
+	err := row.Scan(&i.Digest, &i.Archive, &i.SizeBytes)
+	return i, err
+}
+
+const getDeviceCapabilities = `-- name: GetDeviceCapabilities :one
+SELECT device_id, api_version, vendor, model_number, serial_number, cpu_cores, memory, storage, reported_at FROM device_capabilities
+WHERE device_id = $1
+`
+
+func (q *Queries) GetDeviceCapabilities(ctx context.Context, deviceID string) (DeviceCapability, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceCapabilities, deviceID)
+	var i DeviceCapability
+	err := row.Scan(
+		&i.DeviceID,
+		&i.ApiVersion,
+		&i.Vendor,
+		&i.ModelNumber,
+		&i.SerialNumber,
+		&i.CpuCores,
+		&i.Memory,
+		&i.Storage,
+		&i.ReportedAt,
+	)
+	return i, err
+}
+
+const getDeviceCapabilityRoles = `-- name: GetDeviceCapabilityRoles :many
+SELECT role FROM device_capability_roles
+WHERE device_id = $1
+ORDER BY role
+`
+
+func (q *Queries) GetDeviceCapabilityRoles(ctx context.Context, deviceID string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getDeviceCapabilityRoles, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		items = append(items, role)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeviceCertificateByFingerprint = `-- name: GetDeviceCertificateByFingerprint :one
+SELECT fingerprint, device_id, certificate, subject, not_after, created_at FROM device_certificates
+WHERE fingerprint = $1
+`
+
+func (q *Queries) GetDeviceCertificateByFingerprint(ctx context.Context, fingerprint string) (DeviceCertificate, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceCertificateByFingerprint, fingerprint)
+	var i DeviceCertificate
+	err := row.Scan(
+		&i.Fingerprint,
+		&i.DeviceID,
+		&i.Certificate,
+		&i.Subject,
+		&i.NotAfter,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getDeviceComponentBlob = `-- name: GetDeviceComponentBlob :one
+SELECT b.digest, b.descriptor, b.size_bytes
+FROM device_component_blobs a
+JOIN deployment_blobs b ON b.digest = a.digest
+WHERE a.device_id = $1 AND a.deployment_id = $2 AND a.name = $3 AND a.digest = $4
+`
+
+type GetDeviceComponentBlobParams struct {
+	DeviceID     string
+	DeploymentID string
+	Name         string
+	Digest       string
+}
+
+type GetDeviceComponentBlobRow struct {
+	Digest     string
+	Descriptor []byte
+	SizeBytes  int64
+}
+
+func (q *Queries) GetDeviceComponentBlob(ctx context.Context, arg GetDeviceComponentBlobParams) (GetDeviceComponentBlobRow, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceComponentBlob,
+		arg.DeviceID,
+		arg.DeploymentID,
+		arg.Name,
+		arg.Digest,
+	)
+	var i GetDeviceComponentBlobRow
+	err := row.Scan(&i.Digest, &i.Descriptor, &i.SizeBytes)
+	return i, err
+}
+
+const getDeviceDeploymentBlob = `-- name: GetDeviceDeploymentBlob :one
+SELECT b.digest, b.descriptor, b.size_bytes
+FROM device_deployment_blobs a
+JOIN deployment_blobs b ON b.digest = a.digest
+WHERE a.device_id = $1 AND a.deployment_id = $2 AND a.digest = $3
+`
+
+type GetDeviceDeploymentBlobParams struct {
+	DeviceID     string
+	DeploymentID string
+	Digest       string
+}
+
+type GetDeviceDeploymentBlobRow struct {
+	Digest     string
+	Descriptor []byte
+	SizeBytes  int64
+}
+
+func (q *Queries) GetDeviceDeploymentBlob(ctx context.Context, arg GetDeviceDeploymentBlobParams) (GetDeviceDeploymentBlobRow, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceDeploymentBlob, arg.DeviceID, arg.DeploymentID, arg.Digest)
+	var i GetDeviceDeploymentBlobRow
+	err := row.Scan(&i.Digest, &i.Descriptor, &i.SizeBytes)
+	return i, err
+}
+
+const getDeviceGateway = `-- name: GetDeviceGateway :one
+SELECT gateway_id FROM device_gateways
+WHERE device_id = $1
+`
+
+func (q *Queries) GetDeviceGateway(ctx context.Context, deviceID string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceGateway, deviceID)
+	var gateway_id string
+	err := row.Scan(&gateway_id)
+	return gateway_id, err
+}
+
+const getDeviceGatewaysByDeviceIds = `-- name: GetDeviceGatewaysByDeviceIds :many
+SELECT device_id, gateway_id FROM device_gateways
+WHERE device_id = ANY($1::text[])
+`
+
+func (q *Queries) GetDeviceGatewaysByDeviceIds(ctx context.Context, deviceIds []string) ([]DeviceGateway, error) {
+	rows, err := q.db.QueryContext(ctx, getDeviceGatewaysByDeviceIds, pq.Array(deviceIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeviceGateway
+	for rows.Next() {
+		var i DeviceGateway
+		if err := rows.Scan(&i.DeviceID, &i.GatewayID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeviceId = `-- name: GetDeviceId :one
+SELECT id FROM devices
+WHERE id = $1
+`
+
+func (q *Queries) GetDeviceId(ctx context.Context, id string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceId, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getDeviceLabels = `-- name: GetDeviceLabels :many
+SELECT device_id, key, value FROM device_labels
+WHERE device_id = $1
+ORDER BY key
+`
+
+func (q *Queries) GetDeviceLabels(ctx context.Context, deviceID string) ([]DeviceLabel, error) {
+	rows, err := q.db.QueryContext(ctx, getDeviceLabels, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeviceLabel
+	for rows.Next() {
+		var i DeviceLabel
+		if err := rows.Scan(&i.DeviceID, &i.Key, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeviceLabelsByDeviceIds = `-- name: GetDeviceLabelsByDeviceIds :many
+SELECT device_id, key, value FROM device_labels
+WHERE device_id = ANY($1::text[])
+ORDER BY device_id, key
+`
+
+func (q *Queries) GetDeviceLabelsByDeviceIds(ctx context.Context, deviceIds []string) ([]DeviceLabel, error) {
+	rows, err := q.db.QueryContext(ctx, getDeviceLabelsByDeviceIds, pq.Array(deviceIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeviceLabel
+	for rows.Next() {
+		var i DeviceLabel
+		if err := rows.Scan(&i.DeviceID, &i.Key, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDevicesByIds = `-- name: GetDevicesByIds :many
+SELECT id, display_name, created_at, updated_at FROM devices
+WHERE id = ANY($1::text[])
+ORDER BY id
+`
+
+func (q *Queries) GetDevicesByIds(ctx context.Context, deviceIds []string) ([]Device, error) {
+	rows, err := q.db.QueryContext(ctx, getDevicesByIds, pq.Array(deviceIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Device
+	for rows.Next() {
+		var i Device
+		if err := rows.Scan(
+			&i.ID,
+			&i.DisplayName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFleetDeployment = `-- name: GetFleetDeployment :one
+SELECT f.id, f.descriptor_digest, b.descriptor, f.device_group, f.created_at, f.updated_at
+FROM fleet_deployments f
+JOIN deployment_blobs b ON b.digest = f.descriptor_digest
+WHERE f.id = $1
+`
+
+type GetFleetDeploymentRow struct {
+	ID               string
+	DescriptorDigest string
+	Descriptor       []byte
+	DeviceGroup      string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (q *Queries) GetFleetDeployment(ctx context.Context, id string) (GetFleetDeploymentRow, error) {
+	row := q.db.QueryRowContext(ctx, getFleetDeployment, id)
+	var i GetFleetDeploymentRow
+	err := row.Scan(
+		&i.ID,
+		&i.DescriptorDigest,
+		&i.Descriptor,
+		&i.DeviceGroup,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getFleetDeploymentDevices = `-- name: GetFleetDeploymentDevices :many
+SELECT device_id FROM application_deployments
+WHERE fleet_deployment_id = $1
+ORDER BY device_id
+`
+
+func (q *Queries) GetFleetDeploymentDevices(ctx context.Context, fleetDeploymentID string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getFleetDeploymentDevices, fleetDeploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var device_id string
+		if err := rows.Scan(&device_id); err != nil {
+			return nil, err
+		}
+		items = append(items, device_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFleetDeploymentSelectorLabels = `-- name: GetFleetDeploymentSelectorLabels :many
+SELECT fleet_deployment_id, key, value FROM fleet_deployment_selector_labels
+WHERE fleet_deployment_id = $1
+ORDER BY key
+`
+
+func (q *Queries) GetFleetDeploymentSelectorLabels(ctx context.Context, fleetDeploymentID string) ([]FleetDeploymentSelectorLabel, error) {
+	rows, err := q.db.QueryContext(ctx, getFleetDeploymentSelectorLabels, fleetDeploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FleetDeploymentSelectorLabel
+	for rows.Next() {
+		var i FleetDeploymentSelectorLabel
+		if err := rows.Scan(&i.FleetDeploymentID, &i.Key, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGatewayManifest = `-- name: GetGatewayManifest :one
+SELECT m.gateway_id, m.version, m.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes
+FROM gateway_manifests m
+LEFT JOIN bundle_blobs b ON b.digest = m.bundle_digest
+WHERE m.gateway_id = $1
+`
+
+type GetGatewayManifestRow struct {
+	GatewayID       string
+	Version         int64
+	BundleDigest    sql.NullString
+	BundleSizeBytes int64
+}
+
+func (q *Queries) GetGatewayManifest(ctx context.Context, gatewayID string) (GetGatewayManifestRow, error) {
+	row := q.db.QueryRowContext(ctx, getGatewayManifest, gatewayID)
+	var i GetGatewayManifestRow
+	err := row.Scan(
+		&i.GatewayID,
+		&i.Version,
+		&i.BundleDigest,
+		&i.BundleSizeBytes,
+	)
+	return i, err
+}
+
+const getLatestManifestEventId = `-- name: GetLatestManifestEventId :one
+SELECT CAST(COALESCE(MAX(id), 0) AS BIGINT) AS id FROM manifest_events
+`
+
+func (q *Queries) GetLatestManifestEventId(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getLatestManifestEventId)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getManifestByDeviceId = `-- name: GetManifestByDeviceId :one
+SELECT m.device_id, m.version, m.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes
+FROM application_deployment_manifests m
+LEFT JOIN bundle_blobs b ON b.digest = m.bundle_digest
+WHERE m.device_id = $1
+`
+
+type GetManifestByDeviceIdRow struct {
+	DeviceID        string
+	Version         int64
+	BundleDigest    sql.NullString
+	BundleSizeBytes int64
+}
+
+func (q *Queries) GetManifestByDeviceId(ctx context.Context, deviceID string) (GetManifestByDeviceIdRow, error) {
+	row := q.db.QueryRowContext(ctx, getManifestByDeviceId, deviceID)
+	var i GetManifestByDeviceIdRow
+	err := row.Scan(
+		&i.DeviceID,
+		&i.Version,
+		&i.BundleDigest,
+		&i.BundleSizeBytes,
+	)
+	return i, err
+}
+
+const getManifestEventDeploymentsByEventIds = `-- name: GetManifestEventDeploymentsByEventIds :many
+SELECT event_id, deployment_id FROM manifest_event_deployments
+WHERE event_id = ANY($1::bigint[])
+ORDER BY event_id, deployment_id
+`
+
+func (q *Queries) GetManifestEventDeploymentsByEventIds(ctx context.Context, eventIds []int64) ([]ManifestEventDeployment, error) {
+	rows, err := q.db.QueryContext(ctx, getManifestEventDeploymentsByEventIds, pq.Array(eventIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ManifestEventDeployment
+	for rows.Next() {
+		var i ManifestEventDeployment
+		if err := rows.Scan(&i.EventID, &i.DeploymentID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getManifestHistory = `-- name: GetManifestHistory :one
+SELECT h.device_id, h.version, h.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes, h.published_at
+FROM manifest_history h
+LEFT JOIN bundle_blobs b ON b.digest = h.bundle_digest
+WHERE h.device_id = $1 AND h.version = $2
+`
+
+type GetManifestHistoryParams struct {
+	DeviceID string
+	Version  int64
+}
+
+type GetManifestHistoryRow struct {
+	DeviceID        string
+	Version         int64
+	BundleDigest    sql.NullString
+	BundleSizeBytes int64
+	PublishedAt     time.Time
+}
+
+func (q *Queries) GetManifestHistory(ctx context.Context, arg GetManifestHistoryParams) (GetManifestHistoryRow, error) {
+	row := q.db.QueryRowContext(ctx, getManifestHistory, arg.DeviceID, arg.Version)
+	var i GetManifestHistoryRow
+	err := row.Scan(
+		&i.DeviceID,
+		&i.Version,
+		&i.BundleDigest,
+		&i.BundleSizeBytes,
+		&i.PublishedAt,
+	)
+	return i, err
+}
+
+const getManifestHistoryComponentBlobs = `-- name: GetManifestHistoryComponentBlobs :many
+SELECT c.target, c.deployment_id, c.name, c.digest, b.descriptor AS artifact
+FROM manifest_history_components c
+JOIN deployment_blobs b ON b.digest = c.digest
+WHERE c.device_id = $1 AND c.version = $2
+ORDER BY c.target, c.deployment_id, c.position
+`
+
+type GetManifestHistoryComponentBlobsParams struct {
+	DeviceID string
+	Version  int64
+}
+
+type GetManifestHistoryComponentBlobsRow struct {
+	Target       string
+	DeploymentID string
+	Name         string
+	Digest       string
+	Artifact     []byte
+}
+
+func (q *Queries) GetManifestHistoryComponentBlobs(ctx context.Context, arg GetManifestHistoryComponentBlobsParams) ([]GetManifestHistoryComponentBlobsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getManifestHistoryComponentBlobs, arg.DeviceID, arg.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetManifestHistoryComponentBlobsRow
+	for rows.Next() {
+		var i GetManifestHistoryComponentBlobsRow
+		if err := rows.Scan(
+			&i.Target,
+			&i.DeploymentID,
+			&i.Name,
+			&i.Digest,
+			&i.Artifact,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getManifestHistoryComponents = `-- name: GetManifestHistoryComponents :many
+SELECT c.version, c.target, c.deployment_id, c.name, c.digest, b.size_bytes
+FROM manifest_history_components c
+JOIN deployment_blobs b ON b.digest = c.digest
+WHERE c.device_id = $1 AND c.version = ANY($2::bigint[])
+ORDER BY c.version, c.target, c.deployment_id, c.position
+`
+
+type GetManifestHistoryComponentsParams struct {
+	DeviceID string
+	Versions []int64
+}
+
+type GetManifestHistoryComponentsRow struct {
+	Version      int64
+	Target       string
+	DeploymentID string
+	Name         string
+	Digest       string
+	SizeBytes    int64
+}
+
+func (q *Queries) GetManifestHistoryComponents(ctx context.Context, arg GetManifestHistoryComponentsParams) ([]GetManifestHistoryComponentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getManifestHistoryComponents, arg.DeviceID, pq.Array(arg.Versions))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetManifestHistoryComponentsRow
+	for rows.Next() {
+		var i GetManifestHistoryComponentsRow
+		if err := rows.Scan(
+			&i.Version,
+			&i.Target,
+			&i.DeploymentID,
+			&i.Name,
+			&i.Digest,
+			&i.SizeBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getManifestHistoryDeploymentBlobs = `-- name: GetManifestHistoryDeploymentBlobs :many
+SELECT d.target, d.deployment_id, d.descriptor_digest, b.descriptor, d.fleet_deployment_id
+FROM manifest_history_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = $1 AND d.version = $2
+ORDER BY d.target <> d.device_id, d.target, d.deployment_id
+`
+
+type GetManifestHistoryDeploymentBlobsParams struct {
+	DeviceID string
+	Version  int64
+}
+
+type GetManifestHistoryDeploymentBlobsRow struct {
+	Target            string
+	DeploymentID      string
+	DescriptorDigest  string
+	Descriptor        []byte
+	FleetDeploymentID string
+}
+
+func (q *Queries) GetManifestHistoryDeploymentBlobs(ctx context.Context, arg GetManifestHistoryDeploymentBlobsParams) ([]GetManifestHistoryDeploymentBlobsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getManifestHistoryDeploymentBlobs, arg.DeviceID, arg.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetManifestHistoryDeploymentBlobsRow
+	for rows.Next() {
+		var i GetManifestHistoryDeploymentBlobsRow
+		if err := rows.Scan(
+			&i.Target,
+			&i.DeploymentID,
+			&i.DescriptorDigest,
+			&i.Descriptor,
+			&i.FleetDeploymentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getManifestHistoryDeployments = `-- name: GetManifestHistoryDeployments :many
+SELECT d.version, d.target, d.deployment_id, d.descriptor_digest, b.size_bytes, d.fleet_deployment_id
+FROM manifest_history_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = $1 AND d.version = ANY($2::bigint[])
+ORDER BY d.version, d.target <> d.device_id, d.target, d.deployment_id
+`
+
+type GetManifestHistoryDeploymentsParams struct {
+	DeviceID string
+	Versions []int64
+}
+
+type GetManifestHistoryDeploymentsRow struct {
+	Version           int64
+	Target            string
+	DeploymentID      string
+	DescriptorDigest  string
+	SizeBytes         int64
+	FleetDeploymentID string
+}
+
+func (q *Queries) GetManifestHistoryDeployments(ctx context.Context, arg GetManifestHistoryDeploymentsParams) ([]GetManifestHistoryDeploymentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getManifestHistoryDeployments, arg.DeviceID, pq.Array(arg.Versions))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetManifestHistoryDeploymentsRow
+	for rows.Next() {
+		var i GetManifestHistoryDeploymentsRow
+		if err := rows.Scan(
+			&i.Version,
+			&i.Target,
+			&i.DeploymentID,
+			&i.DescriptorDigest,
+			&i.SizeBytes,
+			&i.FleetDeploymentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertBundleBlob = `-- name: InsertBundleBlob :exec
+INSERT INTO bundle_blobs (digest, archive, size_bytes)
+VALUES ($1, $2, $3)
+ON CONFLICT(digest) DO NOTHING
+`
+
+type InsertBundleBlobParams struct {
+	Digest    string
+	Archive   []byte
+	SizeBytes int64
+}
+
+func (q *Queries) InsertBundleBlob(ctx context.Context, arg InsertBundleBlobParams) error {
+	_, err := q.db.ExecContext(ctx, insertBundleBlob, arg.Digest, arg.Archive, arg.SizeBytes)
+	return err
+}
+
+const insertDeploymentBlob = `-- name: InsertDeploymentBlob :exec
+INSERT INTO deployment_blobs (digest, descriptor, size_bytes)
+VALUES ($1, $2, $3)
+ON CONFLICT(digest) DO NOTHING
+`
+
+type InsertDeploymentBlobParams struct {
+	Digest     string
+	Descriptor []byte
+	SizeBytes  int64
+}
+
+func (q *Queries) InsertDeploymentBlob(ctx context.Context, arg InsertDeploymentBlobParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeploymentBlob, arg.Digest, arg.Descriptor, arg.SizeBytes)
+	return err
+}
+
+const insertDeploymentComponent = `-- name: InsertDeploymentComponent :exec
+INSERT INTO application_deployment_components (
+    device_id, deployment_id, name, position, digest
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+`
+
+type InsertDeploymentComponentParams struct {
+	DeviceID     string
+	DeploymentID string
+	Name         string
+	Position     int64
+	Digest       string
+}
+
+func (q *Queries) InsertDeploymentComponent(ctx context.Context, arg InsertDeploymentComponentParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeploymentComponent,
+		arg.DeviceID,
+		arg.DeploymentID,
+		arg.Name,
+		arg.Position,
+		arg.Digest,
+	)
+	return err
+}
+
+const insertDeploymentComponentStatus = `-- name: InsertDeploymentComponentStatus :exec
+INSERT INTO deployment_component_statuses (
+    status_id, name, state, error_code, error_message
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+`
+
+type InsertDeploymentComponentStatusParams struct {
+	StatusID     int64
+	Name         string
+	State        string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+func (q *Queries) InsertDeploymentComponentStatus(ctx context.Context, arg InsertDeploymentComponentStatusParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeploymentComponentStatus,
+		arg.StatusID,
+		arg.Name,
+		arg.State,
+		arg.ErrorCode,
+		arg.ErrorMessage,
+	)
+	return err
+}
+
+const insertDeploymentStatus = `-- name: InsertDeploymentStatus :one
+INSERT INTO deployment_statuses (
+    device_id, deployment_id, api_version, digest, state, error_code, error_message
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id
+`
+
+type InsertDeploymentStatusParams struct {
+	DeviceID     string
+	DeploymentID string
+	ApiVersion   string
+	Digest       string
+	State        string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+func (q *Queries) InsertDeploymentStatus(ctx context.Context, arg InsertDeploymentStatusParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertDeploymentStatus,
+		arg.DeviceID,
+		arg.DeploymentID,
+		arg.ApiVersion,
+		arg.Digest,
+		arg.State,
+		arg.ErrorCode,
+		arg.ErrorMessage,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertDeviceBundleBlob = `-- name: InsertDeviceBundleBlob :exec
+INSERT INTO device_bundle_blobs (device_id, digest)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type InsertDeviceBundleBlobParams struct {
+	DeviceID string
+	Digest   string
+}
+
+func (q *Queries) InsertDeviceBundleBlob(ctx context.Context, arg InsertDeviceBundleBlobParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeviceBundleBlob, arg.DeviceID, arg.Digest)
+	return err
+}
+
+const insertDeviceCapabilityRole = `-- name: InsertDeviceCapabilityRole :exec
+INSERT INTO device_capability_roles (device_id, role)
+VALUES ($1, $2)
+`
+
+type InsertDeviceCapabilityRoleParams struct {
+	DeviceID string
+	Role     string
+}
+
+func (q *Queries) InsertDeviceCapabilityRole(ctx context.Context, arg InsertDeviceCapabilityRoleParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeviceCapabilityRole, arg.DeviceID, arg.Role)
+	return err
+}
+
+const insertDeviceCertificate = `-- name: InsertDeviceCertificate :exec
+INSERT INTO device_certificates (
+    fingerprint, device_id, certificate, subject, not_after
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+`
+
+type InsertDeviceCertificateParams struct {
+	Fingerprint string
+	DeviceID    string
+	Certificate []byte
+	Subject     string
+	NotAfter    time.Time
+}
+
+func (q *Queries) InsertDeviceCertificate(ctx context.Context, arg InsertDeviceCertificateParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeviceCertificate,
+		arg.Fingerprint,
+		arg.DeviceID,
+		arg.Certificate,
+		arg.Subject,
+		arg.NotAfter,
+	)
+	return err
+}
+
+const insertDeviceComponentBlob = `-- name: InsertDeviceComponentBlob :exec
+INSERT INTO device_component_blobs (device_id, deployment_id, name, digest)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING
+`
+
+type InsertDeviceComponentBlobParams struct {
+	DeviceID     string
+	DeploymentID string
+	Name         string
+	Digest       string
+}
+
+func (q *Queries) InsertDeviceComponentBlob(ctx context.Context, arg InsertDeviceComponentBlobParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeviceComponentBlob,
+		arg.DeviceID,
+		arg.DeploymentID,
+		arg.Name,
+		arg.Digest,
+	)
+	return err
+}
+
+const insertDeviceDeploymentBlob = `-- name: InsertDeviceDeploymentBlob :exec
+INSERT INTO device_deployment_blobs (device_id, deployment_id, digest)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type InsertDeviceDeploymentBlobParams struct {
+	DeviceID     string
+	DeploymentID string
+	Digest       string
+}
+
+func (q *Queries) InsertDeviceDeploymentBlob(ctx context.Context, arg InsertDeviceDeploymentBlobParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeviceDeploymentBlob, arg.DeviceID, arg.DeploymentID, arg.Digest)
+	return err
+}
+
+const insertDeviceLabel = `-- name: InsertDeviceLabel :exec
+INSERT INTO device_labels (device_id, key, value)
+VALUES ($1, $2, $3)
+`
+
+type InsertDeviceLabelParams struct {
+	DeviceID string
+	Key      string
+	Value    string
+}
+
+func (q *Queries) InsertDeviceLabel(ctx context.Context, arg InsertDeviceLabelParams) error {
+	_, err := q.db.ExecContext(ctx, insertDeviceLabel, arg.DeviceID, arg.Key, arg.Value)
+	return err
+}
+
+const insertFleetDeploymentSelectorLabel = `-- name: InsertFleetDeploymentSelectorLabel :exec
+INSERT INTO fleet_deployment_selector_labels (fleet_deployment_id, key, value)
+VALUES ($1, $2, $3)
+`
+
+type InsertFleetDeploymentSelectorLabelParams struct {
+	FleetDeploymentID string
+	Key               string
+	Value             string
+}
+
+func (q *Queries) InsertFleetDeploymentSelectorLabel(ctx context.Context, arg InsertFleetDeploymentSelectorLabelParams) error {
+	_, err := q.db.ExecContext(ctx, insertFleetDeploymentSelectorLabel, arg.FleetDeploymentID, arg.Key, arg.Value)
+	return err
+}
+
+const insertManifestEvent = `-- name: InsertManifestEvent :one
+INSERT INTO manifest_events (
+    device_id, manifest_version, manifest_etag
+) VALUES (
+    $1, $2, $3
+)
+RETURNING id
+`
+
+type InsertManifestEventParams struct {
+	DeviceID        string
+	ManifestVersion int64
+	ManifestEtag    string
+}
+
+func (q *Queries) InsertManifestEvent(ctx context.Context, arg InsertManifestEventParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertManifestEvent, arg.DeviceID, arg.ManifestVersion, arg.ManifestEtag)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertManifestEventDeployment = `-- name: InsertManifestEventDeployment :exec
+INSERT INTO manifest_event_deployments (event_id, deployment_id)
+VALUES ($1, $2)
+`
+
+type InsertManifestEventDeploymentParams struct {
+	EventID      int64
+	DeploymentID string
+}
+
+func (q *Queries) InsertManifestEventDeployment(ctx context.Context, arg InsertManifestEventDeploymentParams) error {
+	_, err := q.db.ExecContext(ctx, insertManifestEventDeployment, arg.EventID, arg.DeploymentID)
+	return err
+}
+
+const insertManifestHistory = `-- name: InsertManifestHistory :exec
+INSERT INTO manifest_history (device_id, version, bundle_digest)
+VALUES ($1, $2, $3)
+`
+
+type InsertManifestHistoryParams struct {
+	DeviceID     string
+	Version      int64
+	BundleDigest sql.NullString
+}
+
+func (q *Queries) InsertManifestHistory(ctx context.Context, arg InsertManifestHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, insertManifestHistory, arg.DeviceID, arg.Version, arg.BundleDigest)
+	return err
+}
+
+const insertManifestHistoryComponent = `-- name: InsertManifestHistoryComponent :exec
+INSERT INTO manifest_history_components (
+    device_id, version, target, deployment_id, name, position, digest
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+`
+
+type InsertManifestHistoryComponentParams struct {
+	DeviceID     string
+	Version      int64
+	Target       string
+	DeploymentID string
+	Name         string
+	Position     int64
+	Digest       string
+}
+
+func (q *Queries) InsertManifestHistoryComponent(ctx context.Context, arg InsertManifestHistoryComponentParams) error {
+	_, err := q.db.ExecContext(ctx, insertManifestHistoryComponent,
+		arg.DeviceID,
+		arg.Version,
+		arg.Target,
+		arg.DeploymentID,
+		arg.Name,
+		arg.Position,
+		arg.Digest,
+	)
+	return err
+}
+
+const insertManifestHistoryDeployment = `-- name: InsertManifestHistoryDeployment :exec
+INSERT INTO manifest_history_deployments (
+    device_id, version, target, deployment_id, descriptor_digest, fleet_deployment_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+`
+
+type InsertManifestHistoryDeploymentParams struct {
+	DeviceID          string
+	Version           int64
+	Target            string
+	DeploymentID      string
+	DescriptorDigest  string
+	FleetDeploymentID string
+}
+
+func (q *Queries) InsertManifestHistoryDeployment(ctx context.Context, arg InsertManifestHistoryDeploymentParams) error {
+	_, err := q.db.ExecContext(ctx, insertManifestHistoryDeployment,
+		arg.DeviceID,
+		arg.Version,
+		arg.Target,
+		arg.DeploymentID,
+		arg.DescriptorDigest,
+		arg.FleetDeploymentID,
+	)
+	return err
+}
+
+const listAllDevices = `-- name: ListAllDevices :many
+SELECT id, display_name, created_at, updated_at FROM devices
+ORDER BY id
+`
+
+func (q *Queries) ListAllDevices(ctx context.Context) ([]Device, error) {
+	rows, err := q.db.QueryContext(ctx, listAllDevices)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Device
+	for rows.Next() {
+		var i Device
+		if err := rows.Scan(
+			&i.ID,
+			&i.DisplayName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeploymentStatuses = `-- name: ListDeploymentStatuses :many
+SELECT id, device_id, deployment_id, api_version, digest, state, error_code, error_message, reported_at FROM deployment_statuses
+WHERE device_id = $1 AND deployment_id = $2
+ORDER BY id DESC
+LIMIT $3::bigint
+`
+
+type ListDeploymentStatusesParams struct {
+	DeviceID     string
+	DeploymentID string
+	Limit        int64
+}
+
+func (q *Queries) ListDeploymentStatuses(ctx context.Context, arg ListDeploymentStatusesParams) ([]DeploymentStatus, error) {
+	rows, err := q.db.QueryContext(ctx, listDeploymentStatuses, arg.DeviceID, arg.DeploymentID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeploymentStatus
+	for rows.Next() {
+		var i DeploymentStatus
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeviceID,
+			&i.DeploymentID,
+			&i.ApiVersion,
+			&i.Digest,
+			&i.State,
+			&i.ErrorCode,
+			&i.ErrorMessage,
+			&i.ReportedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeviceIdsByLabel = `-- name: ListDeviceIdsByLabel :many
+SELECT device_id FROM device_labels
+WHERE key = $1 AND value = $2
+ORDER BY device_id
+`
+
+type ListDeviceIdsByLabelParams struct {
+	Key   string
+	Value string
+}
+
+func (q *Queries) ListDeviceIdsByLabel(ctx context.Context, arg ListDeviceIdsByLabelParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listDeviceIdsByLabel, arg.Key, arg.Value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var device_id string
+		if err := rows.Scan(&device_id); err != nil {
+			return nil, err
+		}
+		items = append(items, device_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDevices = `-- name: ListDevices :many
+SELECT id, display_name, created_at, updated_at FROM devices
+WHERE id > $1
+ORDER BY id
+LIMIT $2::bigint
+`
+
+type ListDevicesParams struct {
+	ID    string
+	Limit int64
+}
+
+func (q *Queries) ListDevices(ctx context.Context, arg ListDevicesParams) ([]Device, error) {
+	rows, err := q.db.QueryContext(ctx, listDevices, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Device
+	for rows.Next() {
+		var i Device
+		if err := rows.Scan(
+			&i.ID,
+			&i.DisplayName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFleetDeploymentDevices = `-- name: ListFleetDeploymentDevices :many
+SELECT fleet_deployment_id, device_id FROM application_deployments
+WHERE fleet_deployment_id != ''
+ORDER BY fleet_deployment_id, device_id
+`
+
+type ListFleetDeploymentDevicesRow struct {
+	FleetDeploymentID string
+	DeviceID          string
+}
+
+func (q *Queries) ListFleetDeploymentDevices(ctx context.Context) ([]ListFleetDeploymentDevicesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFleetDeploymentDevices)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFleetDeploymentDevicesRow
+	for rows.Next() {
+		var i ListFleetDeploymentDevicesRow
+		if err := rows.Scan(&i.FleetDeploymentID, &i.DeviceID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFleetDeploymentSelectorLabels = `-- name: ListFleetDeploymentSelectorLabels :many
+SELECT fleet_deployment_id, key, value FROM fleet_deployment_selector_labels
+ORDER BY fleet_deployment_id, key
+`
+
+func (q *Queries) ListFleetDeploymentSelectorLabels(ctx context.Context) ([]FleetDeploymentSelectorLabel, error) {
+	rows, err := q.db.QueryContext(ctx, listFleetDeploymentSelectorLabels)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FleetDeploymentSelectorLabel
+	for rows.Next() {
+		var i FleetDeploymentSelectorLabel
+		if err := rows.Scan(&i.FleetDeploymentID, &i.Key, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFleetDeployments = `-- name: ListFleetDeployments :many
+SELECT f.id, f.descriptor_digest, b.descriptor, f.device_group, f.created_at, f.updated_at
+FROM fleet_deployments f
+JOIN deployment_blobs b ON b.digest = f.descriptor_digest
+ORDER BY f.id
+`
+
+type ListFleetDeploymentsRow struct {
+	ID               string
+	DescriptorDigest string
+	Descriptor       []byte
+	DeviceGroup      string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (q *Queries) ListFleetDeployments(ctx context.Context) ([]ListFleetDeploymentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFleetDeployments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFleetDeploymentsRow
+	for rows.Next() {
+		var i ListFleetDeploymentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.DescriptorDigest,
+			&i.Descriptor,
+			&i.DeviceGroup,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGatewayChildCapabilities = `-- name: ListGatewayChildCapabilities :many
+SELECT c.device_id, c.api_version, c.vendor, c.model_number, c.serial_number, c.cpu_cores, c.memory, c.storage, c.reported_at
+FROM device_capabilities c
+JOIN device_gateways g ON g.device_id = c.device_id
+WHERE g.gateway_id = $1
+ORDER BY c.device_id
+`
+
+func (q *Queries) ListGatewayChildCapabilities(ctx context.Context, gatewayID string) ([]DeviceCapability, error) {
+	rows, err := q.db.QueryContext(ctx, listGatewayChildCapabilities, gatewayID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeviceCapability
+	for rows.Next() {
+		var i DeviceCapability
+		if err := rows.Scan(
+			&i.DeviceID,
+			&i.ApiVersion,
+			&i.Vendor,
+			&i.ModelNumber,
+			&i.SerialNumber,
+			&i.CpuCores,
+			&i.Memory,
+			&i.Storage,
+			&i.ReportedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGatewayChildren = `-- name: ListGatewayChildren :many
+SELECT device_id FROM device_gateways
+WHERE gateway_id = $1
+ORDER BY device_id
+`
+
+func (q *Queries) ListGatewayChildren(ctx context.Context, gatewayID string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listGatewayChildren, gatewayID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var device_id string
+		if err := rows.Scan(&device_id); err != nil {
+			return nil, err
+		}
+		items = append(items, device_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listManifestEvents = `-- name: ListManifestEvents :many
+SELECT id, device_id, manifest_version, manifest_etag, published_at FROM manifest_events
+WHERE id > $1
+ORDER BY id
+LIMIT $2::bigint
+`
+
+type ListManifestEventsParams struct {
+	ID    int64
+	Limit int64
+}
+
+func (q *Queries) ListManifestEvents(ctx context.Context, arg ListManifestEventsParams) ([]ManifestEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listManifestEvents, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ManifestEvent
+	for rows.Next() {
+		var i ManifestEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeviceID,
+			&i.ManifestVersion,
+			&i.ManifestEtag,
+			&i.PublishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listManifestHistory = `-- name: ListManifestHistory :many
+SELECT h.device_id, h.version, h.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes, h.published_at
+FROM manifest_history h
+LEFT JOIN bundle_blobs b ON b.digest = h.bundle_digest
+WHERE h.device_id = $1
+    AND h.version < $2
+    AND h.published_at <= $3
+ORDER BY h.version DESC
+LIMIT $4::bigint
+`
+
+type ListManifestHistoryParams struct {
+	DeviceID       string
+	BeforeVersion  int64
+	PublishedUntil time.Time
+	Limit          int64
+}
+
+type ListManifestHistoryRow struct {
+	DeviceID        string
+	Version         int64
+	BundleDigest    sql.NullString
+	BundleSizeBytes int64
+	PublishedAt     time.Time
+}
+
+func (q *Queries) ListManifestHistory(ctx context.Context, arg ListManifestHistoryParams) ([]ListManifestHistoryRow, error) {
+	rows, err := q.db.QueryContext(ctx, listManifestHistory,
+		arg.DeviceID,
+		arg.BeforeVersion,
+		arg.PublishedUntil,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListManifestHistoryRow
+	for rows.Next() {
+		var i ListManifestHistoryRow
+		if err := rows.Scan(
+			&i.DeviceID,
+			&i.Version,
+			&i.BundleDigest,
+			&i.BundleSizeBytes,
+			&i.PublishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockDevice = `-- name: LockDevice :one
+SELECT id FROM devices
+WHERE id = $1
+FOR UPDATE
+`
+
+// Locks the device row until the end of the transaction, which serializes the writers of
+// the manifests of the device across replicas.
+func (q *Queries) LockDevice(ctx context.Context, id string) (string, error) {
+	row := q.db.QueryRowContext(ctx, lockDevice, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const lockManifestEvents = `-- name: LockManifestEvents :exec
+SELECT pg_advisory_xact_lock(hashtext('wfm_manifest_events'))
+`
+
+// Serializes the publishing transactions, so that event IDs are committed in ascending
+// order and the event stream, which reads past the last ID, cannot skip an event.
+func (q *Queries) LockManifestEvents(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, lockManifestEvents)
+	return err
+}
+
+const markUnreferencedBundleBlobs = `-- name: MarkUnreferencedBundleBlobs :execrows
+UPDATE bundle_blobs SET unreferenced_since = CURRENT_TIMESTAMP
+WHERE unreferenced_since IS NULL AND digest NOT IN (
+    SELECT bundle_digest FROM application_deployment_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM gateway_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM manifest_history WHERE bundle_digest IS NOT NULL
+)
+`
+
+func (q *Queries) MarkUnreferencedBundleBlobs(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markUnreferencedBundleBlobs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markUnreferencedDeploymentBlobs = `-- name: MarkUnreferencedDeploymentBlobs :execrows
+UPDATE deployment_blobs SET unreferenced_since = CURRENT_TIMESTAMP
+WHERE unreferenced_since IS NULL AND digest NOT IN (
+    SELECT descriptor_digest FROM application_deployments
+    UNION SELECT digest FROM application_deployment_components
+    UNION SELECT descriptor_digest FROM fleet_deployments
+    UNION SELECT descriptor_digest FROM manifest_history_deployments
+    UNION SELECT digest FROM manifest_history_components
+)
+`
+
+func (q *Queries) MarkUnreferencedDeploymentBlobs(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markUnreferencedDeploymentBlobs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const notifyManifestChanges = `-- name: NotifyManifestChanges :exec
+SELECT pg_notify('wfm_manifests', device_id)
+FROM unnest($1::text[]) AS device_id
+`
+
+// Publishes the devices whose manifest may have changed to the replicas listening on the
+// wfm_manifests channel, one notification per device.
+func (q *Queries) NotifyManifestChanges(ctx context.Context, deviceIds []string) error {
+	_, err := q.db.ExecContext(ctx, notifyManifestChanges, pq.Array(deviceIds))
+	return err
+}
+
+const pruneManifestHistory = `-- name: PruneManifestHistory :execrows
+DELETE FROM manifest_history
+WHERE EXISTS (
+    SELECT 1 FROM manifest_history n
+    WHERE n.device_id = manifest_history.device_id
+      AND n.version > manifest_history.version
+      AND n.published_at < $1
+)
+`
+
+// Deletes the history entries superseded by a version published before the cutoff. The
+// current version of a device is never superseded and hence kept.
+func (q *Queries) PruneManifestHistory(ctx context.Context, supersededBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneManifestHistory, supersededBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const tryLockBlobCollection = `-- name: TryLockBlobCollection :one
+SELECT pg_try_advisory_xact_lock(hashtext('wfm_blob_collection'))::boolean AS locked
+`
+
+// Only one replica collects blobs at a time.
+func (q *Queries) TryLockBlobCollection(ctx context.Context) (bool, error) {
+	row := q.db.QueryRowContext(ctx, tryLockBlobCollection)
+	var locked bool
+	err := row.Scan(&locked)
+	return locked, err
+}
+
+const unmarkReferencedBundleBlobs = `-- name: UnmarkReferencedBundleBlobs :execrows
+UPDATE bundle_blobs SET unreferenced_since = NULL
+WHERE unreferenced_since IS NOT NULL AND digest IN (
+    SELECT bundle_digest FROM application_deployment_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM gateway_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM manifest_history WHERE bundle_digest IS NOT NULL
+)
+`
+
+func (q *Queries) UnmarkReferencedBundleBlobs(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unmarkReferencedBundleBlobs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unmarkReferencedDeploymentBlobs = `-- name: UnmarkReferencedDeploymentBlobs :execrows
+UPDATE deployment_blobs SET unreferenced_since = NULL
+WHERE unreferenced_since IS NOT NULL AND digest IN (
+    SELECT descriptor_digest FROM application_deployments
+    UNION SELECT digest FROM application_deployment_components
+    UNION SELECT descriptor_digest FROM fleet_deployments
+    UNION SELECT descriptor_digest FROM manifest_history_deployments
+    UNION SELECT digest FROM manifest_history_components
+)
+`
+
+func (q *Queries) UnmarkReferencedDeploymentBlobs(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unmarkReferencedDeploymentBlobs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateDevice = `-- name: UpdateDevice :exec
+UPDATE devices
+SET display_name = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+`
+
+type UpdateDeviceParams struct {
+	DisplayName string
+	ID          string
+}
+
+func (q *Queries) UpdateDevice(ctx context.Context, arg UpdateDeviceParams) error {
+	_, err := q.db.ExecContext(ctx, updateDevice, arg.DisplayName, arg.ID)
+	return err
+}
+
+const updateFleetDeployment = `-- name: UpdateFleetDeployment :exec
+UPDATE fleet_deployments
+SET descriptor_digest = $1, device_group = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3
+`
+
+type UpdateFleetDeploymentParams struct {
+	DescriptorDigest string
+	DeviceGroup      string
+	ID               string
+}
+
+func (q *Queries) UpdateFleetDeployment(ctx context.Context, arg UpdateFleetDeploymentParams) error {
+	_, err := q.db.ExecContext(ctx, updateFleetDeployment, arg.DescriptorDigest, arg.DeviceGroup, arg.ID)
+	return err
+}
+
+const upsertDecommissionedDevice = `-- name: UpsertDecommissionedDevice :exec
+INSERT INTO decommissioned_devices (id, manifest_version)
+VALUES ($1, $2)
+ON CONFLICT (id) DO UPDATE SET manifest_version = excluded.manifest_version
+`
+
+type UpsertDecommissionedDeviceParams struct {
+	ID              string
+	ManifestVersion int64
+}
+
+func (q *Queries) UpsertDecommissionedDevice(ctx context.Context, arg UpsertDecommissionedDeviceParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDecommissionedDevice, arg.ID, arg.ManifestVersion)
+	return err
+}
+
+const upsertDeployment = `-- name: UpsertDeployment :exec
+INSERT INTO application_deployments (
+    id, descriptor_digest, device_id, fleet_deployment_id
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (device_id, id) 
+DO UPDATE SET
+    descriptor_digest = excluded.descriptor_digest,
+    fleet_deployment_id = excluded.fleet_deployment_id
+`
+
+type UpsertDeploymentParams struct {
+	ID                string
+	DescriptorDigest  string
+	DeviceID          string
+	FleetDeploymentID string
+}
+
+func (q *Queries) UpsertDeployment(ctx context.Context, arg UpsertDeploymentParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDeployment,
+		arg.ID,
+		arg.DescriptorDigest,
+		arg.DeviceID,
+		arg.FleetDeploymentID,
+	)
+	return err
+}
+
+const upsertDeviceCapabilities = `-- name: UpsertDeviceCapabilities :exec
+INSERT INTO device_capabilities (
+    device_id, api_version, vendor, model_number, serial_number, cpu_cores, memory, storage, reported_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP
+)
+ON CONFLICT (device_id)
+DO UPDATE SET
+    api_version = excluded.api_version,
+    vendor = excluded.vendor,
+    model_number = excluded.model_number,
+    serial_number = excluded.serial_number,
+    cpu_cores = excluded.cpu_cores,
+    memory = excluded.memory,
+    storage = excluded.storage,
+    reported_at = excluded.reported_at
+`
+
+type UpsertDeviceCapabilitiesParams struct {
+	DeviceID     string
+	ApiVersion   string
+	Vendor       string
+	ModelNumber  string
+	SerialNumber string
+	CpuCores     float64
+	Memory       string
+	Storage      string
+}
+
+func (q *Queries) UpsertDeviceCapabilities(ctx context.Context, arg UpsertDeviceCapabilitiesParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDeviceCapabilities,
+		arg.DeviceID,
+		arg.ApiVersion,
+		arg.Vendor,
+		arg.ModelNumber,
+		arg.SerialNumber,
+		arg.CpuCores,
+		arg.Memory,
+		arg.Storage,
+	)
+	return err
+}
+
+const upsertDeviceGateway = `-- name: UpsertDeviceGateway :exec
+INSERT INTO device_gateways (device_id, gateway_id)
+VALUES ($1, $2)
+ON CONFLICT (device_id)
+DO UPDATE SET gateway_id = excluded.gateway_id
+`
+
+type UpsertDeviceGatewayParams struct {
+	DeviceID  string
+	GatewayID string
+}
+
+func (q *Queries) UpsertDeviceGateway(ctx context.Context, arg UpsertDeviceGatewayParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDeviceGateway, arg.DeviceID, arg.GatewayID)
+	return err
+}
+
+const upsertGatewayManifest = `-- name: UpsertGatewayManifest :exec
+INSERT INTO gateway_manifests (gateway_id, version, bundle_digest)
+VALUES ($1, $2, $3)
+ON CONFLICT (gateway_id)
+DO UPDATE SET
+    version = excluded.version,
+    bundle_digest = excluded.bundle_digest
+`
+
+type UpsertGatewayManifestParams struct {
+	GatewayID    string
+	Version      int64
+	BundleDigest sql.NullString
+}
+
+func (q *Queries) UpsertGatewayManifest(ctx context.Context, arg UpsertGatewayManifestParams) error {
+	_, err := q.db.ExecContext(ctx, upsertGatewayManifest, arg.GatewayID, arg.Version, arg.BundleDigest)
+	return err
+}
+
+const upsertManifest = `-- name: UpsertManifest :exec
+INSERT INTO application_deployment_manifests (
+    version, bundle_digest, device_id
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (device_id) 
+DO UPDATE SET
+    version = excluded.version,
+    bundle_digest = excluded.bundle_digest,
+    device_id = excluded.device_id
+`
+
+type UpsertManifestParams struct {
+	Version      int64
+	BundleDigest sql.NullString
+	DeviceID     string
+}
+
+func (q *Queries) UpsertManifest(ctx context.Context, arg UpsertManifestParams) error {
+	_, err := q.db.ExecContext(ctx, upsertManifest, arg.Version, arg.BundleDigest, arg.DeviceID)
+	return err
+}
@@ -0,0 +1,3 @@
+package postgresdb
+
+//go:generate go tool github.com/sqlc-dev/sqlc/cmd/sqlc generate
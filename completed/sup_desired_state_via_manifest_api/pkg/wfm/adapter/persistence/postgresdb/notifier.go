@@ -0,0 +1,108 @@
+package postgresdb
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/port"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// manifestChannel is the LISTEN/NOTIFY channel carrying the IDs of devices whose manifest
+	// may have changed (see NotifyManifestChanges).
+	manifestChannel = "wfm_manifests"
+	notifyTimeout   = 5 * time.Second
+)
+
+// ManifestNotifier is a port.ManifestNotifier shared by every replica using the database.
+// Notifications are delivered to the local notifier right away and published to the other
+// replicas through PostgreSQL NOTIFY; Listen forwards the notifications of the other replicas.
+// A replica may thus be notified twice of its own changes, which waiters tolerate.
+type ManifestNotifier struct {
+	ds    *DataStore
+	local port.ManifestNotifier
+}
+
+func NewManifestNotifier(ds *DataStore, local port.ManifestNotifier) *ManifestNotifier {
+	return &ManifestNotifier{
+		ds:    ds,
+		local: local,
+	}
+}
+
+func (mn *ManifestNotifier) Notify(deviceIds ...string) {
+	mn.local.Notify(deviceIds...)
+	if len(deviceIds) == 0 {
+		return
+	}
+	// Notify runs after the change was committed; a stalled database must not hold up the
+	// request that made it
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := mn.ds.NotifyManifestChanges(ctx, deviceIds); err != nil {
+		logrus.WithError(err).WithField("deviceIds", deviceIds).Warn("Failed to publish manifest notifications")
+	}
+}
+
+func (mn *ManifestNotifier) Changed(deviceId string) <-chan struct{} {
+	return mn.local.Changed(deviceId)
+}
+
+func (mn *ManifestNotifier) AnyChanged() <-chan struct{} {
+	return mn.local.AnyChanged()
+}
+
+// Listen forwards the notifications published by every replica to the local notifier, until
+// the context is cancelled. Lost connections are re-established.
+func (mn *ManifestNotifier) Listen(ctx context.Context) {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+	for {
+		err := mn.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logrus.WithError(err).WithField("retryIn", backoff).Warn("Manifest notification listener disconnected")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+func (mn *ManifestNotifier) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, mn.ds.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err = conn.Exec(ctx, "LISTEN "+manifestChannel); err != nil {
+		return err
+	}
+	// Changes made while disconnected were not received
+	mn.local.Notify(mn.allDeviceIds(ctx)...)
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		mn.local.Notify(notification.Payload)
+	}
+}
+
+func (mn *ManifestNotifier) allDeviceIds(ctx context.Context) []string {
+	devices, err := mn.ds.ListAllDevices(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list devices to notify")
+		return nil
+	}
+	deviceIds := make([]string, len(devices))
+	for i, device := range devices {
+		deviceIds[i] = device.ID
+	}
+	return deviceIds
+}
@@ -0,0 +1,344 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"skeleton/pkg/wfm/adapter/persistence/postgresdb/db"
+	sqlite "skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+)
+
+// The repositories run the queries with the parameter and row types generated for SQLite.
+// The types generated for PostgreSQL have the same fields, so the queries taking or
+// returning them merely convert; the others are promoted from db.Queries unchanged.
+
+func (t *transaction) CreateDevice(ctx context.Context, arg sqlite.CreateDeviceParams) error {
+	return t.Queries.CreateDevice(ctx, db.CreateDeviceParams(arg))
+}
+
+func (t *transaction) CreateFleetDeployment(ctx context.Context, arg sqlite.CreateFleetDeploymentParams) error {
+	return t.Queries.CreateFleetDeployment(ctx, db.CreateFleetDeploymentParams(arg))
+}
+
+func (t *transaction) DeleteDeployment(ctx context.Context, arg sqlite.DeleteDeploymentParams) error {
+	return t.Queries.DeleteDeployment(ctx, db.DeleteDeploymentParams(arg))
+}
+
+func (t *transaction) DeleteDeploymentComponents(ctx context.Context, arg sqlite.DeleteDeploymentComponentsParams) error {
+	return t.Queries.DeleteDeploymentComponents(ctx, db.DeleteDeploymentComponentsParams(arg))
+}
+
+func (t *transaction) GetCollectableBundleBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (sqlite.GetCollectableBundleBlobStatsRow, error) {
+	row, err := t.Queries.GetCollectableBundleBlobStats(ctx, unreferencedBefore)
+	return sqlite.GetCollectableBundleBlobStatsRow(row), err
+}
+
+func (t *transaction) GetCollectableDeploymentBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (sqlite.GetCollectableDeploymentBlobStatsRow, error) {
+	row, err := t.Queries.GetCollectableDeploymentBlobStats(ctx, unreferencedBefore)
+	return sqlite.GetCollectableDeploymentBlobStatsRow(row), err
+}
+
+func (t *transaction) GetDeploymentByIdAndDigest(ctx context.Context, arg sqlite.GetDeploymentByIdAndDigestParams) (sqlite.GetDeploymentByIdAndDigestRow, error) {
+	row, err := t.Queries.GetDeploymentByIdAndDigest(ctx, db.GetDeploymentByIdAndDigestParams(arg))
+	return sqlite.GetDeploymentByIdAndDigestRow(row), err
+}
+
+func (t *transaction) GetDeploymentComponentStatusesByStatusIds(ctx context.Context, statusIds []int64) ([]sqlite.DeploymentComponentStatus, error) {
+	rows, err := t.Queries.GetDeploymentComponentStatusesByStatusIds(ctx, statusIds)
+	return convertRows(rows, err, func(row db.DeploymentComponentStatus) sqlite.DeploymentComponentStatus {
+		return sqlite.DeploymentComponentStatus(row)
+	})
+}
+
+func (t *transaction) GetDeploymentComponentsByDeviceId(ctx context.Context, deviceID string) ([]sqlite.GetDeploymentComponentsByDeviceIdRow, error) {
+	rows, err := t.Queries.GetDeploymentComponentsByDeviceId(ctx, deviceID)
+	return convertRows(rows, err, func(row db.GetDeploymentComponentsByDeviceIdRow) sqlite.GetDeploymentComponentsByDeviceIdRow {
+		return sqlite.GetDeploymentComponentsByDeviceIdRow(row)
+	})
+}
+
+func (t *transaction) GetDeploymentsByDeviceId(ctx context.Context, deviceID string) ([]sqlite.GetDeploymentsByDeviceIdRow, error) {
+	rows, err := t.Queries.GetDeploymentsByDeviceId(ctx, deviceID)
+	return convertRows(rows, err, func(row db.GetDeploymentsByDeviceIdRow) sqlite.GetDeploymentsByDeviceIdRow {
+		return sqlite.GetDeploymentsByDeviceIdRow(row)
+	})
+}
+
+func (t *transaction) GetDevice(ctx context.Context, id string) (sqlite.Device, error) {
+	row, err := t.Queries.GetDevice(ctx, id)
+	return sqlite.Device(row), err
+}
+
+func (t *transaction) GetDeviceBundleBlob(ctx context.Context, arg sqlite.GetDeviceBundleBlobParams) (sqlite.GetDeviceBundleBlobRow, error) {
+	row, err := t.Queries.GetDeviceBundleBlob(ctx, db.GetDeviceBundleBlobParams(arg))
+	return sqlite.GetDeviceBundleBlobRow(row), err
+}
+
+func (t *transaction) GetDeviceCapabilities(ctx context.Context, deviceID string) (sqlite.DeviceCapability, error) {
+	row, err := t.Queries.GetDeviceCapabilities(ctx, deviceID)
+	return sqlite.DeviceCapability(row), err
+}
+
+func (t *transaction) GetDeviceCertificateByFingerprint(ctx context.Context, fingerprint string) (sqlite.DeviceCertificate, error) {
+	row, err := t.Queries.GetDeviceCertificateByFingerprint(ctx, fingerprint)
+	return sqlite.DeviceCertificate(row), err
+}
+
+func (t *transaction) GetDeviceComponentBlob(ctx context.Context, arg sqlite.GetDeviceComponentBlobParams) (sqlite.GetDeviceComponentBlobRow, error) {
+	row, err := t.Queries.GetDeviceComponentBlob(ctx, db.GetDeviceComponentBlobParams(arg))
+	return sqlite.GetDeviceComponentBlobRow(row), err
+}
+
+func (t *transaction) GetDeviceDeploymentBlob(ctx context.Context, arg sqlite.GetDeviceDeploymentBlobParams) (sqlite.GetDeviceDeploymentBlobRow, error) {
+	row, err := t.Queries.GetDeviceDeploymentBlob(ctx, db.GetDeviceDeploymentBlobParams(arg))
+	return sqlite.GetDeviceDeploymentBlobRow(row), err
+}
+
+func (t *transaction) GetDeviceGatewaysByDeviceIds(ctx context.Context, deviceIds []string) ([]sqlite.DeviceGateway, error) {
+	rows, err := t.Queries.GetDeviceGatewaysByDeviceIds(ctx, deviceIds)
+	return convertRows(rows, err, func(row db.DeviceGateway) sqlite.DeviceGateway { return sqlite.DeviceGateway(row) })
+}
+
+func (t *transaction) GetDeviceLabels(ctx context.Context, deviceID string) ([]sqlite.DeviceLabel, error) {
+	rows, err := t.Queries.GetDeviceLabels(ctx, deviceID)
+	return convertRows(rows, err, func(row db.DeviceLabel) sqlite.DeviceLabel { return sqlite.DeviceLabel(row) })
+}
+
+func (t *transaction) GetDeviceLabelsByDeviceIds(ctx context.Context, deviceIds []string) ([]sqlite.DeviceLabel, error) {
+	rows, err := t.Queries.GetDeviceLabelsByDeviceIds(ctx, deviceIds)
+	return convertRows(rows, err, func(row db.DeviceLabel) sqlite.DeviceLabel { return sqlite.DeviceLabel(row) })
+}
+
+func (t *transaction) GetDevicesByIds(ctx context.Context, deviceIds []string) ([]sqlite.Device, error) {
+	rows, err := t.Queries.GetDevicesByIds(ctx, deviceIds)
+	return convertRows(rows, err, func(row db.Device) sqlite.Device { return sqlite.Device(row) })
+}
+
+func (t *transaction) GetFleetDeployment(ctx context.Context, id string) (sqlite.GetFleetDeploymentRow, error) {
+	row, err := t.Queries.GetFleetDeployment(ctx, id)
+	return sqlite.GetFleetDeploymentRow(row), err
+}
+
+func (t *transaction) GetFleetDeploymentSelectorLabels(ctx context.Context, fleetDeploymentID string) ([]sqlite.FleetDeploymentSelectorLabel, error) {
+	rows, err := t.Queries.GetFleetDeploymentSelectorLabels(ctx, fleetDeploymentID)
+	return convertRows(rows, err, func(row db.FleetDeploymentSelectorLabel) sqlite.FleetDeploymentSelectorLabel {
+		return sqlite.FleetDeploymentSelectorLabel(row)
+	})
+}
+
+func (t *transaction) GetGatewayManifest(ctx context.Context, gatewayID string) (sqlite.GetGatewayManifestRow, error) {
+	row, err := t.Queries.GetGatewayManifest(ctx, gatewayID)
+	return sqlite.GetGatewayManifestRow(row), err
+}
+
+func (t *transaction) GetManifestByDeviceId(ctx context.Context, deviceID string) (sqlite.GetManifestByDeviceIdRow, error) {
+	row, err := t.Queries.GetManifestByDeviceId(ctx, deviceID)
+	return sqlite.GetManifestByDeviceIdRow(row), err
+}
+
+func (t *transaction) GetManifestEventDeploymentsByEventIds(ctx context.Context, eventIds []int64) ([]sqlite.ManifestEventDeployment, error) {
+	rows, err := t.Queries.GetManifestEventDeploymentsByEventIds(ctx, eventIds)
+	return convertRows(rows, err, func(row db.ManifestEventDeployment) sqlite.ManifestEventDeployment {
+		return sqlite.ManifestEventDeployment(row)
+	})
+}
+
+func (t *transaction) GetManifestHistory(ctx context.Context, arg sqlite.GetManifestHistoryParams) (sqlite.GetManifestHistoryRow, error) {
+	row, err := t.Queries.GetManifestHistory(ctx, db.GetManifestHistoryParams(arg))
+	return sqlite.GetManifestHistoryRow(row), err
+}
+
+func (t *transaction) GetManifestHistoryComponentBlobs(ctx context.Context, arg sqlite.GetManifestHistoryComponentBlobsParams) ([]sqlite.GetManifestHistoryComponentBlobsRow, error) {
+	rows, err := t.Queries.GetManifestHistoryComponentBlobs(ctx, db.GetManifestHistoryComponentBlobsParams(arg))
+	return convertRows(rows, err, func(row db.GetManifestHistoryComponentBlobsRow) sqlite.GetManifestHistoryComponentBlobsRow {
+		return sqlite.GetManifestHistoryComponentBlobsRow(row)
+	})
+}
+
+func (t *transaction) GetManifestHistoryComponents(ctx context.Context, arg sqlite.GetManifestHistoryComponentsParams) ([]sqlite.GetManifestHistoryComponentsRow, error) {
+	rows, err := t.Queries.GetManifestHistoryComponents(ctx, db.GetManifestHistoryComponentsParams(arg))
+	return convertRows(rows, err, func(row db.GetManifestHistoryComponentsRow) sqlite.GetManifestHistoryComponentsRow {
+		return sqlite.GetManifestHistoryComponentsRow(row)
+	})
+}
+
+func (t *transaction) GetManifestHistoryDeploymentBlobs(ctx context.Context, arg sqlite.GetManifestHistoryDeploymentBlobsParams) ([]sqlite.GetManifestHistoryDeploymentBlobsRow, error) {
+	rows, err := t.Queries.GetManifestHistoryDeploymentBlobs(ctx, db.GetManifestHistoryDeploymentBlobsParams(arg))
+	return convertRows(rows, err, func(row db.GetManifestHistoryDeploymentBlobsRow) sqlite.GetManifestHistoryDeploymentBlobsRow {
+		return sqlite.GetManifestHistoryDeploymentBlobsRow(row)
+	})
+}
+
+func (t *transaction) GetManifestHistoryDeployments(ctx context.Context, arg sqlite.GetManifestHistoryDeploymentsParams) ([]sqlite.GetManifestHistoryDeploymentsRow, error) {
+	rows, err := t.Queries.GetManifestHistoryDeployments(ctx, db.GetManifestHistoryDeploymentsParams(arg))
+	return convertRows(rows, err, func(row db.GetManifestHistoryDeploymentsRow) sqlite.GetManifestHistoryDeploymentsRow {
+		return sqlite.GetManifestHistoryDeploymentsRow(row)
+	})
+}
+
+func (t *transaction) InsertBundleBlob(ctx context.Context, arg sqlite.InsertBundleBlobParams) error {
+	return t.Queries.InsertBundleBlob(ctx, db.InsertBundleBlobParams(arg))
+}
+
+func (t *transaction) InsertDeploymentBlob(ctx context.Context, arg sqlite.InsertDeploymentBlobParams) error {
+	return t.Queries.InsertDeploymentBlob(ctx, db.InsertDeploymentBlobParams(arg))
+}
+
+func (t *transaction) InsertDeploymentComponent(ctx context.Context, arg sqlite.InsertDeploymentComponentParams) error {
+	return t.Queries.InsertDeploymentComponent(ctx, db.InsertDeploymentComponentParams(arg))
+}
+
+func (t *transaction) InsertDeploymentComponentStatus(ctx context.Context, arg sqlite.InsertDeploymentComponentStatusParams) error {
+	return t.Queries.InsertDeploymentComponentStatus(ctx, db.InsertDeploymentComponentStatusParams(arg))
+}
+
+func (t *transaction) InsertDeploymentStatus(ctx context.Context, arg sqlite.InsertDeploymentStatusParams) (int64, error) {
+	return t.Queries.InsertDeploymentStatus(ctx, db.InsertDeploymentStatusParams(arg))
+}
+
+func (t *transaction) InsertDeviceBundleBlob(ctx context.Context, arg sqlite.InsertDeviceBundleBlobParams) error {
+	return t.Queries.InsertDeviceBundleBlob(ctx, db.InsertDeviceBundleBlobParams(arg))
+}
+
+func (t *transaction) InsertDeviceCapabilityRole(ctx context.Context, arg sqlite.InsertDeviceCapabilityRoleParams) error {
+	return t.Queries.InsertDeviceCapabilityRole(ctx, db.InsertDeviceCapabilityRoleParams(arg))
+}
+
+func (t *transaction) InsertDeviceCertificate(ctx context.Context, arg sqlite.InsertDeviceCertificateParams) error {
+	return t.Queries.InsertDeviceCertificate(ctx, db.InsertDeviceCertificateParams(arg))
+}
+
+func (t *transaction) InsertDeviceComponentBlob(ctx context.Context, arg sqlite.InsertDeviceComponentBlobParams) error {
+	return t.Queries.InsertDeviceComponentBlob(ctx, db.InsertDeviceComponentBlobParams(arg))
+}
+
+func (t *transaction) InsertDeviceDeploymentBlob(ctx context.Context, arg sqlite.InsertDeviceDeploymentBlobParams) error {
+	return t.Queries.InsertDeviceDeploymentBlob(ctx, db.InsertDeviceDeploymentBlobParams(arg))
+}
+
+func (t *transaction) InsertDeviceLabel(ctx context.Context, arg sqlite.InsertDeviceLabelParams) error {
+	return t.Queries.InsertDeviceLabel(ctx, db.InsertDeviceLabelParams(arg))
+}
+
+func (t *transaction) InsertFleetDeploymentSelectorLabel(ctx context.Context, arg sqlite.InsertFleetDeploymentSelectorLabelParams) error {
+	return t.Queries.InsertFleetDeploymentSelectorLabel(ctx, db.InsertFleetDeploymentSelectorLabelParams(arg))
+}
+
+func (t *transaction) InsertManifestEvent(ctx context.Context, arg sqlite.InsertManifestEventParams) (int64, error) {
+	return t.Queries.InsertManifestEvent(ctx, db.InsertManifestEventParams(arg))
+}
+
+func (t *transaction) InsertManifestEventDeployment(ctx context.Context, arg sqlite.InsertManifestEventDeploymentParams) error {
+	return t.Queries.InsertManifestEventDeployment(ctx, db.InsertManifestEventDeploymentParams(arg))
+}
+
+func (t *transaction) InsertManifestHistory(ctx context.Context, arg sqlite.InsertManifestHistoryParams) error {
+	return t.Queries.InsertManifestHistory(ctx, db.InsertManifestHistoryParams(arg))
+}
+
+func (t *transaction) InsertManifestHistoryComponent(ctx context.Context, arg sqlite.InsertManifestHistoryComponentParams) error {
+	return t.Queries.InsertManifestHistoryComponent(ctx, db.InsertManifestHistoryComponentParams(arg))
+}
+
+func (t *transaction) InsertManifestHistoryDeployment(ctx context.Context, arg sqlite.InsertManifestHistoryDeploymentParams) error {
+	return t.Queries.InsertManifestHistoryDeployment(ctx, db.InsertManifestHistoryDeploymentParams(arg))
+}
+
+func (t *transaction) ListAllDevices(ctx context.Context) ([]sqlite.Device, error) {
+	rows, err := t.Queries.ListAllDevices(ctx)
+	return convertRows(rows, err, func(row db.Device) sqlite.Device { return sqlite.Device(row) })
+}
+
+func (t *transaction) ListDeploymentStatuses(ctx context.Context, arg sqlite.ListDeploymentStatusesParams) ([]sqlite.DeploymentStatus, error) {
+	rows, err := t.Queries.ListDeploymentStatuses(ctx, db.ListDeploymentStatusesParams(arg))
+	return convertRows(rows, err, func(row db.DeploymentStatus) sqlite.DeploymentStatus { return sqlite.DeploymentStatus(row) })
+}
+
+func (t *transaction) ListDeviceIdsByLabel(ctx context.Context, arg sqlite.ListDeviceIdsByLabelParams) ([]string, error) {
+	return t.Queries.ListDeviceIdsByLabel(ctx, db.ListDeviceIdsByLabelParams(arg))
+}
+
+func (t *transaction) ListDevices(ctx context.Context, arg sqlite.ListDevicesParams) ([]sqlite.Device, error) {
+	rows, err := t.Queries.ListDevices(ctx, db.ListDevicesParams(arg))
+	return convertRows(rows, err, func(row db.Device) sqlite.Device { return sqlite.Device(row) })
+}
+
+func (t *transaction) ListFleetDeploymentDevices(ctx context.Context) ([]sqlite.ListFleetDeploymentDevicesRow, error) {
+	rows, err := t.Queries.ListFleetDeploymentDevices(ctx)
+	return convertRows(rows, err, func(row db.ListFleetDeploymentDevicesRow) sqlite.ListFleetDeploymentDevicesRow {
+		return sqlite.ListFleetDeploymentDevicesRow(row)
+	})
+}
+
+func (t *transaction) ListFleetDeploymentSelectorLabels(ctx context.Context) ([]sqlite.FleetDeploymentSelectorLabel, error) {
+	rows, err := t.Queries.ListFleetDeploymentSelectorLabels(ctx)
+	return convertRows(rows, err, func(row db.FleetDeploymentSelectorLabel) sqlite.FleetDeploymentSelectorLabel {
+		return sqlite.FleetDeploymentSelectorLabel(row)
+	})
+}
+
+func (t *transaction) ListFleetDeployments(ctx context.Context) ([]sqlite.ListFleetDeploymentsRow, error) {
+	rows, err := t.Queries.ListFleetDeployments(ctx)
+	return convertRows(rows, err, func(row db.ListFleetDeploymentsRow) sqlite.ListFleetDeploymentsRow {
+		return sqlite.ListFleetDeploymentsRow(row)
+	})
+}
+
+func (t *transaction) ListGatewayChildCapabilities(ctx context.Context, gatewayID string) ([]sqlite.DeviceCapability, error) {
+	rows, err := t.Queries.ListGatewayChildCapabilities(ctx, gatewayID)
+	return convertRows(rows, err, func(row db.DeviceCapability) sqlite.DeviceCapability { return sqlite.DeviceCapability(row) })
+}
+
+func (t *transaction) ListManifestEvents(ctx context.Context, arg sqlite.ListManifestEventsParams) ([]sqlite.ManifestEvent, error) {
+	rows, err := t.Queries.ListManifestEvents(ctx, db.ListManifestEventsParams(arg))
+	return convertRows(rows, err, func(row db.ManifestEvent) sqlite.ManifestEvent { return sqlite.ManifestEvent(row) })
+}
+
+func (t *transaction) ListManifestHistory(ctx context.Context, arg sqlite.ListManifestHistoryParams) ([]sqlite.ListManifestHistoryRow, error) {
+	rows, err := t.Queries.ListManifestHistory(ctx, db.ListManifestHistoryParams(arg))
+	return convertRows(rows, err, func(row db.ListManifestHistoryRow) sqlite.ListManifestHistoryRow {
+		return sqlite.ListManifestHistoryRow(row)
+	})
+}
+
+func (t *transaction) UpdateDevice(ctx context.Context, arg sqlite.UpdateDeviceParams) error {
+	return t.Queries.UpdateDevice(ctx, db.UpdateDeviceParams(arg))
+}
+
+func (t *transaction) UpdateFleetDeployment(ctx context.Context, arg sqlite.UpdateFleetDeploymentParams) error {
+	return t.Queries.UpdateFleetDeployment(ctx, db.UpdateFleetDeploymentParams(arg))
+}
+
+func (t *transaction) UpsertDeployment(ctx context.Context, arg sqlite.UpsertDeploymentParams) error {
+	return t.Queries.UpsertDeployment(ctx, db.UpsertDeploymentParams(arg))
+}
+
+func (t *transaction) UpsertDecommissionedDevice(ctx context.Context, arg sqlite.UpsertDecommissionedDeviceParams) error {
+	return t.Queries.UpsertDecommissionedDevice(ctx, db.UpsertDecommissionedDeviceParams(arg))
+}
+
+func (t *transaction) UpsertDeviceCapabilities(ctx context.Context, arg sqlite.UpsertDeviceCapabilitiesParams) error {
+	return t.Queries.UpsertDeviceCapabilities(ctx, db.UpsertDeviceCapabilitiesParams(arg))
+}
+
+func (t *transaction) UpsertDeviceGateway(ctx context.Context, arg sqlite.UpsertDeviceGatewayParams) error {
+	return t.Queries.UpsertDeviceGateway(ctx, db.UpsertDeviceGatewayParams(arg))
+}
+
+func (t *transaction) UpsertGatewayManifest(ctx context.Context, arg sqlite.UpsertGatewayManifestParams) error {
+	return t.Queries.UpsertGatewayManifest(ctx, db.UpsertGatewayManifestParams(arg))
+}
+
+func (t *transaction) UpsertManifest(ctx context.Context, arg sqlite.UpsertManifestParams) error {
+	return t.Queries.UpsertManifest(ctx, db.UpsertManifestParams(arg))
+}
+
+func convertRows[From, To any](rows []From, err error, convert func(From) To) ([]To, error) {
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]To, len(rows))
+	for i, row := range rows {
+		converted[i] = convert(row)
+	}
+	return converted, nil
+}
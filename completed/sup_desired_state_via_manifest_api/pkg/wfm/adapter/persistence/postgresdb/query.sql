@@ -0,0 +1,534 @@
+-- name: GetDeviceId :one
+SELECT id FROM devices
+WHERE id = $1;
+
+-- name: LockDevice :one
+-- Locks the device row until the end of the transaction, which serializes the writers of
+-- the manifests of the device across replicas.
+SELECT id FROM devices
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetDevice :one
+SELECT id, display_name, created_at, updated_at FROM devices
+WHERE id = $1;
+
+-- name: ListDevices :many
+SELECT id, display_name, created_at, updated_at FROM devices
+WHERE id > sqlc.arg(id)
+ORDER BY id
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: CreateDevice :exec
+INSERT INTO devices (
+    id, display_name, created_at, updated_at
+) VALUES (
+    $1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+);
+
+-- name: UpdateDevice :exec
+UPDATE devices
+SET display_name = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2;
+
+-- name: DeleteDevice :exec
+DELETE FROM devices
+WHERE id = $1;
+
+-- name: UpsertDecommissionedDevice :exec
+INSERT INTO decommissioned_devices (id, manifest_version)
+VALUES ($1, $2)
+ON CONFLICT (id) DO UPDATE SET manifest_version = excluded.manifest_version;
+
+-- name: GetDecommissionedManifestVersion :one
+SELECT manifest_version FROM decommissioned_devices
+WHERE id = $1;
+
+-- name: DeleteDecommissionedDevice :exec
+DELETE FROM decommissioned_devices
+WHERE id = $1;
+
+-- name: GetDeviceLabels :many
+SELECT device_id, key, value FROM device_labels
+WHERE device_id = $1
+ORDER BY key;
+
+-- name: GetDeviceLabelsByDeviceIds :many
+SELECT device_id, key, value FROM device_labels
+WHERE device_id = ANY(sqlc.arg(device_ids)::text[])
+ORDER BY device_id, key;
+
+-- name: GetDeviceGateway :one
+SELECT gateway_id FROM device_gateways
+WHERE device_id = $1;
+
+-- name: GetDeviceGatewaysByDeviceIds :many
+SELECT device_id, gateway_id FROM device_gateways
+WHERE device_id = ANY(sqlc.arg(device_ids)::text[]);
+
+-- name: ListGatewayChildren :many
+SELECT device_id FROM device_gateways
+WHERE gateway_id = $1
+ORDER BY device_id;
+
+-- name: UpsertDeviceGateway :exec
+INSERT INTO device_gateways (device_id, gateway_id)
+VALUES ($1, $2)
+ON CONFLICT (device_id)
+DO UPDATE SET gateway_id = excluded.gateway_id;
+
+-- name: DeleteDeviceGateway :exec
+DELETE FROM device_gateways
+WHERE device_id = $1;
+
+-- name: InsertDeviceLabel :exec
+INSERT INTO device_labels (device_id, key, value)
+VALUES ($1, $2, $3);
+
+-- name: DeleteDeviceLabels :exec
+DELETE FROM device_labels
+WHERE device_id = $1;
+
+-- name: GetDeviceCertificateByFingerprint :one
+SELECT fingerprint, device_id, certificate, subject, not_after, created_at FROM device_certificates
+WHERE fingerprint = $1;
+
+-- name: InsertDeviceCertificate :exec
+INSERT INTO device_certificates (
+    fingerprint, device_id, certificate, subject, not_after
+) VALUES (
+    $1, $2, $3, $4, $5
+);
+
+-- name: GetDeviceCapabilities :one
+SELECT device_id, api_version, vendor, model_number, serial_number, cpu_cores, memory, storage, reported_at FROM device_capabilities
+WHERE device_id = $1;
+
+-- name: ListGatewayChildCapabilities :many
+SELECT c.device_id, c.api_version, c.vendor, c.model_number, c.serial_number, c.cpu_cores, c.memory, c.storage, c.reported_at
+FROM device_capabilities c
+JOIN device_gateways g ON g.device_id = c.device_id
+WHERE g.gateway_id = $1
+ORDER BY c.device_id;
+
+-- name: UpsertDeviceCapabilities :exec
+INSERT INTO device_capabilities (
+    device_id, api_version, vendor, model_number, serial_number, cpu_cores, memory, storage, reported_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP
+)
+ON CONFLICT (device_id)
+DO UPDATE SET
+    api_version = excluded.api_version,
+    vendor = excluded.vendor,
+    model_number = excluded.model_number,
+    serial_number = excluded.serial_number,
+    cpu_cores = excluded.cpu_cores,
+    memory = excluded.memory,
+    storage = excluded.storage,
+    reported_at = excluded.reported_at;
+
+-- name: GetDeviceCapabilityRoles :many
+SELECT role FROM device_capability_roles
+WHERE device_id = $1
+ORDER BY role;
+
+-- name: InsertDeviceCapabilityRole :exec
+INSERT INTO device_capability_roles (device_id, role)
+VALUES ($1, $2);
+
+-- name: DeleteDeviceCapabilityRoles :exec
+DELETE FROM device_capability_roles
+WHERE device_id = $1;
+
+-- name: ListAllDevices :many
+SELECT id, display_name, created_at, updated_at FROM devices
+ORDER BY id;
+
+-- name: ListDeviceIdsByLabel :many
+SELECT device_id FROM device_labels
+WHERE key = $1 AND value = $2
+ORDER BY device_id;
+
+-- name: GetDevicesByIds :many
+SELECT id, display_name, created_at, updated_at FROM devices
+WHERE id = ANY(sqlc.arg(device_ids)::text[])
+ORDER BY id;
+
+-- name: GetDeploymentsByDeviceId :many
+SELECT d.id, b.descriptor, b.size_bytes, d.descriptor_digest, d.device_id, d.fleet_deployment_id
+FROM application_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = $1
+ORDER BY d.id;
+
+-- name: GetDeploymentByIdAndDigest :one
+SELECT d.id, b.descriptor, d.descriptor_digest, d.device_id
+FROM application_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = $1 AND d.id = $2 AND d.descriptor_digest = $3;
+
+-- name: UpsertDeployment :exec
+INSERT INTO application_deployments (
+    id, descriptor_digest, device_id, fleet_deployment_id
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (device_id, id) 
+DO UPDATE SET
+    descriptor_digest = excluded.descriptor_digest,
+    fleet_deployment_id = excluded.fleet_deployment_id;
+
+-- name: DeleteDeployment :exec
+DELETE FROM application_deployments
+WHERE device_id = $1 AND id = $2;
+
+-- name: DeleteDeploymentsByDeviceId :exec
+DELETE FROM application_deployments
+WHERE device_id = $1;
+
+-- name: ListFleetDeployments :many
+SELECT f.id, f.descriptor_digest, b.descriptor, f.device_group, f.created_at, f.updated_at
+FROM fleet_deployments f
+JOIN deployment_blobs b ON b.digest = f.descriptor_digest
+ORDER BY f.id;
+
+-- name: GetFleetDeployment :one
+SELECT f.id, f.descriptor_digest, b.descriptor, f.device_group, f.created_at, f.updated_at
+FROM fleet_deployments f
+JOIN deployment_blobs b ON b.digest = f.descriptor_digest
+WHERE f.id = $1;
+
+-- name: CreateFleetDeployment :exec
+INSERT INTO fleet_deployments (
+    id, descriptor_digest, device_group, created_at, updated_at
+) VALUES (
+    $1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+);
+
+-- name: UpdateFleetDeployment :exec
+UPDATE fleet_deployments
+SET descriptor_digest = $1, device_group = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3;
+
+-- name: DeleteFleetDeployment :exec
+DELETE FROM fleet_deployments
+WHERE id = $1;
+
+-- name: ListFleetDeploymentSelectorLabels :many
+SELECT fleet_deployment_id, key, value FROM fleet_deployment_selector_labels
+ORDER BY fleet_deployment_id, key;
+
+-- name: GetFleetDeploymentSelectorLabels :many
+SELECT fleet_deployment_id, key, value FROM fleet_deployment_selector_labels
+WHERE fleet_deployment_id = $1
+ORDER BY key;
+
+-- name: InsertFleetDeploymentSelectorLabel :exec
+INSERT INTO fleet_deployment_selector_labels (fleet_deployment_id, key, value)
+VALUES ($1, $2, $3);
+
+-- name: DeleteFleetDeploymentSelectorLabels :exec
+DELETE FROM fleet_deployment_selector_labels
+WHERE fleet_deployment_id = $1;
+
+-- name: ListFleetDeploymentDevices :many
+SELECT fleet_deployment_id, device_id FROM application_deployments
+WHERE fleet_deployment_id != ''
+ORDER BY fleet_deployment_id, device_id;
+
+-- name: GetFleetDeploymentDevices :many
+SELECT device_id FROM application_deployments
+WHERE fleet_deployment_id = $1
+ORDER BY device_id;
+
+-- name: GetDeploymentComponentsByDeviceId :many
+SELECT c.deployment_id, c.name, c.digest, b.descriptor AS artifact, b.size_bytes
+FROM application_deployment_components c
+JOIN deployment_blobs b ON b.digest = c.digest
+WHERE c.device_id = $1
+ORDER BY c.deployment_id, c.position;
+
+-- name: InsertDeploymentComponent :exec
+INSERT INTO application_deployment_components (
+    device_id, deployment_id, name, position, digest
+) VALUES (
+    $1, $2, $3, $4, $5
+);
+
+-- name: DeleteDeploymentComponents :exec
+DELETE FROM application_deployment_components
+WHERE device_id = $1 AND deployment_id = $2;
+
+-- name: InsertDeploymentStatus :one
+INSERT INTO deployment_statuses (
+    device_id, deployment_id, api_version, digest, state, error_code, error_message
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id;
+
+-- name: InsertDeploymentComponentStatus :exec
+INSERT INTO deployment_component_statuses (
+    status_id, name, state, error_code, error_message
+) VALUES (
+    $1, $2, $3, $4, $5
+);
+
+-- name: ListDeploymentStatuses :many
+SELECT id, device_id, deployment_id, api_version, digest, state, error_code, error_message, reported_at FROM deployment_statuses
+WHERE device_id = sqlc.arg(device_id) AND deployment_id = sqlc.arg(deployment_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: GetDeploymentComponentStatusesByStatusIds :many
+SELECT status_id, name, state, error_code, error_message FROM deployment_component_statuses
+WHERE status_id = ANY(sqlc.arg(status_ids)::bigint[])
+ORDER BY status_id, name;
+
+-- name: InsertManifestHistory :exec
+INSERT INTO manifest_history (device_id, version, bundle_digest)
+VALUES ($1, $2, $3);
+
+-- name: InsertManifestHistoryDeployment :exec
+INSERT INTO manifest_history_deployments (
+    device_id, version, target, deployment_id, descriptor_digest, fleet_deployment_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: InsertManifestHistoryComponent :exec
+INSERT INTO manifest_history_components (
+    device_id, version, target, deployment_id, name, position, digest
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: ListManifestHistory :many
+SELECT h.device_id, h.version, h.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes, h.published_at
+FROM manifest_history h
+LEFT JOIN bundle_blobs b ON b.digest = h.bundle_digest
+WHERE h.device_id = sqlc.arg(device_id)
+    AND h.version < sqlc.arg(before_version)
+    AND h.published_at <= sqlc.arg(published_until)
+ORDER BY h.version DESC
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: GetManifestHistory :one
+SELECT h.device_id, h.version, h.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes, h.published_at
+FROM manifest_history h
+LEFT JOIN bundle_blobs b ON b.digest = h.bundle_digest
+WHERE h.device_id = $1 AND h.version = $2;
+
+-- name: GetManifestHistoryDeployments :many
+SELECT d.version, d.target, d.deployment_id, d.descriptor_digest, b.size_bytes, d.fleet_deployment_id
+FROM manifest_history_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = sqlc.arg(device_id) AND d.version = ANY(sqlc.arg(versions)::bigint[])
+ORDER BY d.version, d.target <> d.device_id, d.target, d.deployment_id;
+
+-- name: GetManifestHistoryComponents :many
+SELECT c.version, c.target, c.deployment_id, c.name, c.digest, b.size_bytes
+FROM manifest_history_components c
+JOIN deployment_blobs b ON b.digest = c.digest
+WHERE c.device_id = sqlc.arg(device_id) AND c.version = ANY(sqlc.arg(versions)::bigint[])
+ORDER BY c.version, c.target, c.deployment_id, c.position;
+
+-- name: GetManifestHistoryDeploymentBlobs :many
+SELECT d.target, d.deployment_id, d.descriptor_digest, b.descriptor, d.fleet_deployment_id
+FROM manifest_history_deployments d
+JOIN deployment_blobs b ON b.digest = d.descriptor_digest
+WHERE d.device_id = $1 AND d.version = $2
+ORDER BY d.target <> d.device_id, d.target, d.deployment_id;
+
+-- name: GetManifestHistoryComponentBlobs :many
+SELECT c.target, c.deployment_id, c.name, c.digest, b.descriptor AS artifact
+FROM manifest_history_components c
+JOIN deployment_blobs b ON b.digest = c.digest
+WHERE c.device_id = $1 AND c.version = $2
+ORDER BY c.target, c.deployment_id, c.position;
+
+-- name: LockManifestEvents :exec
+-- Serializes the publishing transactions, so that event IDs are committed in ascending
+-- order and the event stream, which reads past the last ID, cannot skip an event.
+SELECT pg_advisory_xact_lock(hashtext('wfm_manifest_events'));
+
+-- name: NotifyManifestChanges :exec
+-- Publishes the devices whose manifest may have changed to the replicas listening on the
+-- wfm_manifests channel, one notification per device.
+SELECT pg_notify('wfm_manifests', device_id)
+FROM unnest(sqlc.arg(device_ids)::text[]) AS device_id;
+
+-- name: InsertManifestEvent :one
+INSERT INTO manifest_events (
+    device_id, manifest_version, manifest_etag
+) VALUES (
+    $1, $2, $3
+)
+RETURNING id;
+
+-- name: InsertManifestEventDeployment :exec
+INSERT INTO manifest_event_deployments (event_id, deployment_id)
+VALUES ($1, $2);
+
+-- name: ListManifestEvents :many
+SELECT id, device_id, manifest_version, manifest_etag, published_at FROM manifest_events
+WHERE id > sqlc.arg(id)
+ORDER BY id
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: GetLatestManifestEventId :one
+SELECT CAST(COALESCE(MAX(id), 0) AS BIGINT) AS id FROM manifest_events;
+
+-- name: GetManifestEventDeploymentsByEventIds :many
+SELECT event_id, deployment_id FROM manifest_event_deployments
+WHERE event_id = ANY(sqlc.arg(event_ids)::bigint[])
+ORDER BY event_id, deployment_id;
+
+-- name: GetManifestByDeviceId :one
+SELECT m.device_id, m.version, m.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes
+FROM application_deployment_manifests m
+LEFT JOIN bundle_blobs b ON b.digest = m.bundle_digest
+WHERE m.device_id = $1;
+
+-- name: GetGatewayManifest :one
+SELECT m.gateway_id, m.version, m.bundle_digest, COALESCE(b.size_bytes, 0) AS bundle_size_bytes
+FROM gateway_manifests m
+LEFT JOIN bundle_blobs b ON b.digest = m.bundle_digest
+WHERE m.gateway_id = $1;
+
+-- name: UpsertGatewayManifest :exec
+INSERT INTO gateway_manifests (gateway_id, version, bundle_digest)
+VALUES ($1, $2, $3)
+ON CONFLICT (gateway_id)
+DO UPDATE SET
+    version = excluded.version,
+    bundle_digest = excluded.bundle_digest;
+
+-- name: UpsertManifest :exec
+INSERT INTO application_deployment_manifests (
+    version, bundle_digest, device_id
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (device_id) 
+DO UPDATE SET
+    version = excluded.version,
+    bundle_digest = excluded.bundle_digest,
+    device_id = excluded.device_id;
+
+-- name: DeleteManifestByDeviceId :exec
+DELETE FROM application_deployment_manifests
+WHERE device_id = $1;
+
+-- name: InsertDeploymentBlob :exec
+INSERT INTO deployment_blobs (digest, descriptor, size_bytes)
+VALUES ($1, $2, $3)
+ON CONFLICT(digest) DO NOTHING;
+
+-- name: InsertDeviceDeploymentBlob :exec
+INSERT INTO device_deployment_blobs (device_id, deployment_id, digest)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: GetDeviceDeploymentBlob :one
+SELECT b.digest, b.descriptor, b.size_bytes
+FROM device_deployment_blobs a
+JOIN deployment_blobs b ON b.digest = a.digest
+WHERE a.device_id = $1 AND a.deployment_id = $2 AND a.digest = $3;
+
+-- name: InsertDeviceComponentBlob :exec
+INSERT INTO device_component_blobs (device_id, deployment_id, name, digest)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: GetDeviceComponentBlob :one
+SELECT b.digest, b.descriptor, b.size_bytes
+FROM device_component_blobs a
+JOIN deployment_blobs b ON b.digest = a.digest
+WHERE a.device_id = $1 AND a.deployment_id = $2 AND a.name = $3 AND a.digest = $4;
+
+-- name: InsertBundleBlob :exec
+INSERT INTO bundle_blobs (digest, archive, size_bytes)
+VALUES ($1, $2, $3)
+ON CONFLICT(digest) DO NOTHING;
+
+-- name: InsertDeviceBundleBlob :exec
+INSERT INTO device_bundle_blobs (device_id, digest)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetDeviceBundleBlob :one
+SELECT b.digest, b.archive, b.size_bytes
+FROM device_bundle_blobs a
+JOIN bundle_blobs b ON b.digest = a.digest
+WHERE a.device_id = $1 AND a.digest = $2;
+
+-- name: TryLockBlobCollection :one
+-- Only one replica collects blobs at a time.
+SELECT pg_try_advisory_xact_lock(hashtext('wfm_blob_collection'))::boolean AS locked;
+
+-- name: PruneManifestHistory :execrows
+-- Deletes the history entries superseded by a version published before the cutoff. The
+-- current version of a device is never superseded and hence kept.
+DELETE FROM manifest_history
+WHERE EXISTS (
+    SELECT 1 FROM manifest_history n
+    WHERE n.device_id = manifest_history.device_id
+      AND n.version > manifest_history.version
+      AND n.published_at < sqlc.arg(superseded_before)
+);
+
+-- name: MarkUnreferencedDeploymentBlobs :execrows
+UPDATE deployment_blobs SET unreferenced_since = CURRENT_TIMESTAMP
+WHERE unreferenced_since IS NULL AND digest NOT IN (
+    SELECT descriptor_digest FROM application_deployments
+    UNION SELECT digest FROM application_deployment_components
+    UNION SELECT descriptor_digest FROM fleet_deployments
+    UNION SELECT descriptor_digest FROM manifest_history_deployments
+    UNION SELECT digest FROM manifest_history_components
+);
+
+-- name: UnmarkReferencedDeploymentBlobs :execrows
+UPDATE deployment_blobs SET unreferenced_since = NULL
+WHERE unreferenced_since IS NOT NULL AND digest IN (
+    SELECT descriptor_digest FROM application_deployments
+    UNION SELECT digest FROM application_deployment_components
+    UNION SELECT descriptor_digest FROM fleet_deployments
+    UNION SELECT descriptor_digest FROM manifest_history_deployments
+    UNION SELECT digest FROM manifest_history_components
+);
+
+-- name: GetCollectableDeploymentBlobStats :one
+SELECT COUNT(*) AS blobs, CAST(COALESCE(SUM(size_bytes), 0) AS BIGINT) AS size_bytes
+FROM deployment_blobs
+WHERE unreferenced_since < sqlc.arg(unreferenced_before);
+
+-- name: DeleteCollectableDeploymentBlobs :execrows
+DELETE FROM deployment_blobs
+WHERE unreferenced_since < sqlc.arg(unreferenced_before);
+
+-- name: MarkUnreferencedBundleBlobs :execrows
+UPDATE bundle_blobs SET unreferenced_since = CURRENT_TIMESTAMP
+WHERE unreferenced_since IS NULL AND digest NOT IN (
+    SELECT bundle_digest FROM application_deployment_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM gateway_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM manifest_history WHERE bundle_digest IS NOT NULL
+);
+
+-- name: UnmarkReferencedBundleBlobs :execrows
+UPDATE bundle_blobs SET unreferenced_since = NULL
+WHERE unreferenced_since IS NOT NULL AND digest IN (
+    SELECT bundle_digest FROM application_deployment_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM gateway_manifests WHERE bundle_digest IS NOT NULL
+    UNION SELECT bundle_digest FROM manifest_history WHERE bundle_digest IS NOT NULL
+);
+
+-- name: GetCollectableBundleBlobStats :one
+SELECT COUNT(*) AS blobs, CAST(COALESCE(SUM(size_bytes), 0) AS BIGINT) AS size_bytes
+FROM bundle_blobs
+WHERE unreferenced_since < sqlc.arg(unreferenced_before);
+
+-- name: DeleteCollectableBundleBlobs :execrows
+DELETE FROM bundle_blobs
+WHERE unreferenced_since < sqlc.arg(unreferenced_before);
@@ -0,0 +1,317 @@
+CREATE TABLE IF NOT EXISTS devices (
+    id TEXT PRIMARY KEY,
+    display_name TEXT DEFAULT '' NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS device_labels (
+    device_id TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (device_id, key),
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE
+);
+
+-- Fleet deployments select their devices by label
+CREATE INDEX IF NOT EXISTS device_labels_key_value
+    ON device_labels (key, value);
+
+-- Gateway->child relationships: a child device is served by its gateway (opaque gateway
+-- model). Gateways cannot be children themselves.
+CREATE TABLE IF NOT EXISTS device_gateways (
+    device_id TEXT PRIMARY KEY,
+    gateway_id TEXT NOT NULL,
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (gateway_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS device_gateways_gateway
+    ON device_gateways (gateway_id, device_id);
+
+-- Highest manifest version served to a decommissioned device. A device registered again
+-- under the same ID continues after it, since its clients reject lower versions as a
+-- rollback.
+CREATE TABLE IF NOT EXISTS decommissioned_devices (
+    id TEXT PRIMARY KEY,
+    manifest_version BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS device_certificates (
+    fingerprint TEXT PRIMARY KEY,
+    device_id TEXT NOT NULL,
+    certificate BYTEA NOT NULL,
+    subject TEXT NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS device_capabilities (
+    device_id TEXT PRIMARY KEY,
+    api_version TEXT NOT NULL,
+    vendor TEXT NOT NULL,
+    model_number TEXT NOT NULL,
+    serial_number TEXT NOT NULL,
+    cpu_cores DOUBLE PRECISION NOT NULL,
+    memory TEXT NOT NULL,
+    storage TEXT NOT NULL,
+    reported_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS device_capability_roles (
+    device_id TEXT NOT NULL,
+    role TEXT NOT NULL,
+    PRIMARY KEY (device_id, role),
+    FOREIGN KEY (device_id)
+        REFERENCES device_capabilities (device_id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS deployment_blobs (
+    digest TEXT PRIMARY KEY,
+    descriptor BYTEA NOT NULL,
+    size_bytes BIGINT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    -- set by the blob collector while nothing references the blob
+    unreferenced_since TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS bundle_blobs (
+    digest TEXT PRIMARY KEY,
+    archive BYTEA NOT NULL,
+    size_bytes BIGINT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    -- set by the blob collector while nothing references the blob
+    unreferenced_since TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS application_deployments (
+    id TEXT NOT NULL,
+    descriptor_digest TEXT NOT NULL,
+    device_id TEXT NOT NULL,
+    fleet_deployment_id TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (device_id, id),
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id),
+    FOREIGN KEY (descriptor_digest)
+        REFERENCES deployment_blobs (digest)
+);
+
+CREATE INDEX IF NOT EXISTS application_deployments_fleet_deployment
+    ON application_deployments (fleet_deployment_id, device_id);
+
+-- Rendered components of a deployment; their documents are stored as deployment blobs
+CREATE TABLE IF NOT EXISTS application_deployment_components (
+    device_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    position BIGINT NOT NULL,
+    digest TEXT NOT NULL,
+    PRIMARY KEY (device_id, deployment_id, name),
+    FOREIGN KEY (device_id, deployment_id)
+        REFERENCES application_deployments (device_id, id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (digest)
+        REFERENCES deployment_blobs (digest)
+);
+
+-- Deployments rolled out to every device matching a selector. Each targeted device carries
+-- an application deployment with the same ID, linked through fleet_deployment_id.
+CREATE TABLE IF NOT EXISTS fleet_deployments (
+    id TEXT PRIMARY KEY,
+    descriptor_digest TEXT NOT NULL,
+    device_group TEXT DEFAULT '' NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (descriptor_digest)
+        REFERENCES deployment_blobs (digest)
+);
+
+CREATE TABLE IF NOT EXISTS fleet_deployment_selector_labels (
+    fleet_deployment_id TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (fleet_deployment_id, key),
+    FOREIGN KEY (fleet_deployment_id)
+        REFERENCES fleet_deployments (id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS application_deployment_manifests (
+    device_id TEXT PRIMARY KEY,
+    version BIGINT DEFAULT 1 NOT NULL,
+    bundle_digest TEXT,
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id),
+    FOREIGN KEY (bundle_digest)
+        REFERENCES bundle_blobs (digest)
+);
+
+-- Aggregated manifest of a gateway, covering the deployments of the gateway and its children.
+-- Once created it is kept, so that the version a gateway sees never decreases.
+CREATE TABLE IF NOT EXISTS gateway_manifests (
+    gateway_id TEXT PRIMARY KEY,
+    version BIGINT NOT NULL,
+    bundle_digest TEXT,
+    FOREIGN KEY (gateway_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (bundle_digest)
+        REFERENCES bundle_blobs (digest)
+);
+
+-- Immutable history of the manifest versions published to a device. The history of a gateway
+-- records its aggregated manifests once they exist.
+CREATE TABLE IF NOT EXISTS manifest_history (
+    device_id TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    bundle_digest TEXT,
+    published_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (device_id, version),
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (bundle_digest)
+        REFERENCES bundle_blobs (digest)
+);
+
+-- target is the device an entry of an aggregated manifest is for, empty otherwise. A fleet
+-- deployment appears once per target.
+CREATE TABLE IF NOT EXISTS manifest_history_deployments (
+    device_id TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    target TEXT DEFAULT '' NOT NULL,
+    deployment_id TEXT NOT NULL,
+    descriptor_digest TEXT NOT NULL,
+    fleet_deployment_id TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (device_id, version, target, deployment_id),
+    FOREIGN KEY (device_id, version)
+        REFERENCES manifest_history (device_id, version)
+        ON DELETE CASCADE,
+    FOREIGN KEY (descriptor_digest)
+        REFERENCES deployment_blobs (digest)
+);
+
+CREATE TABLE IF NOT EXISTS manifest_history_components (
+    device_id TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    target TEXT DEFAULT '' NOT NULL,
+    deployment_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    position BIGINT NOT NULL,
+    digest TEXT NOT NULL,
+    PRIMARY KEY (device_id, version, target, deployment_id, name),
+    FOREIGN KEY (device_id, version, target, deployment_id)
+        REFERENCES manifest_history_deployments (device_id, version, target, deployment_id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (digest)
+        REFERENCES deployment_blobs (digest)
+);
+
+-- Log of published device manifests, read by the manifest event stream. Events are kept when
+-- the device is deleted.
+CREATE TABLE IF NOT EXISTS manifest_events (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    device_id TEXT NOT NULL,
+    manifest_version BIGINT NOT NULL,
+    manifest_etag TEXT NOT NULL,
+    published_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Deployments added, changed or removed by a manifest event.
+CREATE TABLE IF NOT EXISTS manifest_event_deployments (
+    event_id BIGINT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    PRIMARY KEY (event_id, deployment_id),
+    FOREIGN KEY (event_id)
+        REFERENCES manifest_events (id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS deployment_statuses (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    device_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    api_version TEXT NOT NULL,
+    digest TEXT DEFAULT '' NOT NULL,
+    state TEXT NOT NULL,
+    error_code TEXT DEFAULT '' NOT NULL,
+    error_message TEXT DEFAULT '' NOT NULL,
+    reported_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS deployment_statuses_device_deployment
+    ON deployment_statuses (device_id, deployment_id, id);
+
+CREATE TABLE IF NOT EXISTS deployment_component_statuses (
+    status_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    state TEXT NOT NULL,
+    error_code TEXT DEFAULT '' NOT NULL,
+    error_message TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (status_id, name),
+    FOREIGN KEY (status_id)
+        REFERENCES deployment_statuses (id)
+        ON DELETE CASCADE
+);
+
+-- Blobs assigned to a device: the descriptors of its deployments, their rendered components
+-- and its bundles, including those of superseded manifest versions and, for gateways, those
+-- of their aggregated manifests. A device can only fetch the blobs it was assigned, each kind
+-- from its own table, so that no endpoint serves the blobs of another. The assignments are
+-- kept until the device or the blob is deleted.
+CREATE TABLE IF NOT EXISTS device_deployment_blobs (
+    device_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    digest TEXT NOT NULL,
+    PRIMARY KEY (device_id, deployment_id, digest),
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (digest)
+        REFERENCES deployment_blobs (digest)
+        ON DELETE CASCADE
+);
+
+-- Rendered components assigned to a device, by the name of the component, so that a
+-- component is only served under its own name.
+CREATE TABLE IF NOT EXISTS device_component_blobs (
+    device_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    digest TEXT NOT NULL,
+    PRIMARY KEY (device_id, deployment_id, name, digest),
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (digest)
+        REFERENCES deployment_blobs (digest)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS device_bundle_blobs (
+    device_id TEXT NOT NULL,
+    digest TEXT NOT NULL,
+    PRIMARY KEY (device_id, digest),
+    FOREIGN KEY (device_id)
+        REFERENCES devices (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (digest)
+        REFERENCES bundle_blobs (digest)
+        ON DELETE CASCADE
+);
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type BlobCollectorConfig struct {
+	// Time between two collections
+	Interval time.Duration
+	// Manifest history superseded for longer than the retention is pruned, together with the
+	// blobs only it referenced; 0 keeps the history forever
+	HistoryRetention time.Duration
+	// Time a blob must remain unreferenced before it is deleted, so that clients which fetched
+	// a manifest just before it was superseded can still download its descriptors and bundle
+	GracePeriod time.Duration
+}
+
+// BlobCollectorStats reports what the blob collector reclaimed.
+type BlobCollectorStats struct {
+	PrunedHistoryEntries   int64
+	DeploymentBlobs        int64
+	DeploymentBlobBytes    int64
+	BundleBlobs            int64
+	BundleBlobBytes        int64
+	UnreferencedBlobsFound int64 // blobs that became unreferenced and entered the grace period
+}
+
+func (s *BlobCollectorStats) add(other BlobCollectorStats) {
+	s.PrunedHistoryEntries += other.PrunedHistoryEntries
+	s.DeploymentBlobs += other.DeploymentBlobs
+	s.DeploymentBlobBytes += other.DeploymentBlobBytes
+	s.BundleBlobs += other.BundleBlobs
+	s.BundleBlobBytes += other.BundleBlobBytes
+	s.UnreferencedBlobsFound += other.UnreferencedBlobsFound
+}
+
+// BlobCollectorTotals are the running totals of the collections since startup.
+type BlobCollectorTotals struct {
+	BlobCollectorStats
+	Runs     int64 // completed collections
+	Skipped  int64 // collections skipped while another replica ran one
+	Failures int64
+}
+
+// BlobCollector deletes the deployment and bundle blobs that neither a current manifest, a
+// fleet deployment nor a retained manifest history entry references. Blobs are only inserted,
+// so without it every change leaves unreferenced blobs behind.
+type BlobCollector struct {
+	ds     DataStore
+	config BlobCollectorConfig
+
+	mu     sync.Mutex
+	totals BlobCollectorTotals
+}
+
+func NewBlobCollector(ds DataStore, config BlobCollectorConfig) *BlobCollector {
+	return &BlobCollector{
+		ds:     ds,
+		config: config,
+	}
+}
+
+// Totals returns what the collections reclaimed since startup.
+func (bc *BlobCollector) Totals() BlobCollectorTotals {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.totals
+}
+
+// Run collects blobs right away and then at every interval, until the context is cancelled.
+func (bc *BlobCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(bc.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := bc.Collect(ctx); err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Error("Blob collection failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect runs a single collection in one transaction and reports what it reclaimed.
+// Concurrent collections of other replicas are skipped.
+func (bc *BlobCollector) Collect(ctx context.Context) (_ BlobCollectorStats, err error) {
+	started := time.Now()
+	stats := BlobCollectorStats{}
+	defer func() {
+		if err != nil {
+			bc.mu.Lock()
+			bc.totals.Failures++
+			bc.mu.Unlock()
+		}
+	}()
+
+	qtx, err := bc.ds.BeginTransaction(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	// Replicas may share the database; skip the collection while another replica runs one
+	locked, err := qtx.TryLockBlobCollection(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to lock blob collection: %w", err)
+	}
+	if !locked {
+		logrus.Debug("Blob collection running on another replica; skipping")
+		if err = qtx.Commit(); err != nil {
+			return stats, fmt.Errorf("commit failed: %w", err)
+		}
+		bc.mu.Lock()
+		bc.totals.Skipped++
+		bc.mu.Unlock()
+		return stats, nil
+	}
+
+	if bc.config.HistoryRetention > 0 {
+		// Keep what clients may still fetch, even if the retention is shorter
+		retention := max(bc.config.HistoryRetention, bc.config.GracePeriod)
+		if stats.PrunedHistoryEntries, err = qtx.PruneManifestHistory(ctx, started.Add(-retention)); err != nil {
+			return stats, fmt.Errorf("failed to prune manifest history: %w", err)
+		}
+	}
+
+	// Blobs enter the grace period when they become unreferenced and leave it when they are
+	// referenced again (e.g. by a rollback)
+	if _, err = qtx.UnmarkReferencedDeploymentBlobs(ctx); err != nil {
+		return stats, fmt.Errorf("failed to unmark referenced deployment blobs: %w", err)
+	}
+	if _, err = qtx.UnmarkReferencedBundleBlobs(ctx); err != nil {
+		return stats, fmt.Errorf("failed to unmark referenced bundle blobs: %w", err)
+	}
+	unreferencedBefore := sql.NullTime{Time: started.Add(-bc.config.GracePeriod), Valid: true}
+	deploymentBlobs, err := qtx.GetCollectableDeploymentBlobStats(ctx, unreferencedBefore)
+	if err != nil {
+		return stats, fmt.Errorf("failed to size collectable deployment blobs: %w", err)
+	}
+	if stats.DeploymentBlobs, err = qtx.DeleteCollectableDeploymentBlobs(ctx, unreferencedBefore); err != nil {
+		return stats, fmt.Errorf("failed to delete deployment blobs: %w", err)
+	}
+	stats.DeploymentBlobBytes = deploymentBlobs.SizeBytes
+	bundleBlobs, err := qtx.GetCollectableBundleBlobStats(ctx, unreferencedBefore)
+	if err != nil {
+		return stats, fmt.Errorf("failed to size collectable bundle blobs: %w", err)
+	}
+	if stats.BundleBlobs, err = qtx.DeleteCollectableBundleBlobs(ctx, unreferencedBefore); err != nil {
+		return stats, fmt.Errorf("failed to delete bundle blobs: %w", err)
+	}
+	stats.BundleBlobBytes = bundleBlobs.SizeBytes
+
+	marked, err := qtx.MarkUnreferencedDeploymentBlobs(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to mark unreferenced deployment blobs: %w", err)
+	}
+	stats.UnreferencedBlobsFound += marked
+	if marked, err = qtx.MarkUnreferencedBundleBlobs(ctx); err != nil {
+		return stats, fmt.Errorf("failed to mark unreferenced bundle blobs: %w", err)
+	}
+	stats.UnreferencedBlobsFound += marked
+
+	if err = qtx.Commit(); err != nil {
+		return stats, fmt.Errorf("commit failed: %w", err)
+	}
+
+	bc.mu.Lock()
+	bc.totals.Runs++
+	bc.totals.add(stats)
+	totals := bc.totals
+	bc.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"duration":               time.Since(started),
+		"prunedHistoryEntries":   stats.PrunedHistoryEntries,
+		"deploymentBlobs":        stats.DeploymentBlobs,
+		"deploymentBlobBytes":    stats.DeploymentBlobBytes,
+		"bundleBlobs":            stats.BundleBlobs,
+		"bundleBlobBytes":        stats.BundleBlobBytes,
+		"unreferencedBlobsFound": stats.UnreferencedBlobsFound,
+		"runs":                   totals.Runs,
+		"totalReclaimedBlobs":    totals.DeploymentBlobs + totals.BundleBlobs,
+		"totalReclaimedBytes":    totals.DeploymentBlobBytes + totals.BundleBlobBytes,
+	}).Info("Blob collection finished")
+	return stats, nil
+}
@@ -0,0 +1,173 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"skeleton/pkg/wfm/adapter/persistence/repository"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/service"
+	"testing"
+	"time"
+)
+
+const deviceId = "plc-1"
+
+func openDataStore(t *testing.T) *sqlitedb.DataStore {
+	t.Helper()
+	ctx := context.Background()
+	ds, err := sqlitedb.New(ctx, filepath.Join(t.TempDir(), "wfm.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = ds.Close() })
+	if err = ds.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	devices := repository.NewDeviceRepository(ds, service.NewManifestNotifier(), manifestETag, service.AggregateManifest)
+	if err = devices.CreateDevice(ctx, &domain.Device{Id: deviceId}, nil); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	return ds
+}
+
+func manifestETag(_ string, manifest *domain.ApplicationDeploymentManifest) (string, error) {
+	return fmt.Sprintf(`"%d"`, manifest.Version), nil
+}
+
+// publish replaces the deployments of the device with one deployment of descriptor and
+// returns the digest of the descriptor.
+func publish(t *testing.T, deployments *repository.DeploymentRepository, descriptor string) string {
+	t.Helper()
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(descriptor)))
+	if err := deployments.UpsertDeployments(context.Background(), deviceId, func(manifest *domain.ApplicationDeploymentManifest) error {
+		manifest.Deployments = []domain.ApplicationDeployment{{
+			Id:               "deployment",
+			Descriptor:       []byte(descriptor),
+			DescriptorDigest: digest,
+		}}
+		manifest.Version++
+		return nil
+	}); err != nil {
+		t.Fatalf("upsert deployments: %v", err)
+	}
+	return digest
+}
+
+func collect(t *testing.T, ds repository.DataStore, config repository.BlobCollectorConfig) repository.BlobCollectorStats {
+	t.Helper()
+	config.Interval = time.Hour
+	stats, err := repository.NewBlobCollector(ds, config).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	return stats
+}
+
+// Descriptors of superseded manifest versions are kept as long as their history is retained.
+func TestCollectKeepsRetainedHistory(t *testing.T) {
+	ds := openDataStore(t)
+	deployments := repository.NewDeploymentRepository(ds, service.NewManifestNotifier(), manifestETag, service.AggregateManifest)
+	superseded := publish(t, deployments, "deployment: 1")
+	publish(t, deployments, "deployment: 2")
+
+	// A history retention of 0 keeps the history forever
+	for range 2 {
+		stats := collect(t, ds, repository.BlobCollectorConfig{})
+		if stats != (repository.BlobCollectorStats{}) {
+			t.Errorf("collection reclaimed blobs of the retained history: %+v", stats)
+		}
+	}
+	if _, err := deployments.GetDeployment(context.Background(), deviceId, "deployment", superseded); err != nil {
+		t.Errorf("get superseded deployment: %v", err)
+	}
+}
+
+// Blobs only the pruned history referenced are deleted once the grace period is over.
+func TestCollectPrunesHistoryAfterGracePeriod(t *testing.T) {
+	ds := openDataStore(t)
+	ctx := context.Background()
+	deployments := repository.NewDeploymentRepository(ds, service.NewManifestNotifier(), manifestETag, service.AggregateManifest)
+	superseded := publish(t, deployments, "deployment: 1")
+	current := publish(t, deployments, "deployment: 2")
+
+	// The history is retained for at least the grace period
+	stats := collect(t, ds, repository.BlobCollectorConfig{HistoryRetention: time.Nanosecond, GracePeriod: time.Hour})
+	if stats != (repository.BlobCollectorStats{}) {
+		t.Errorf("collection reclaimed blobs within the grace period: %+v", stats)
+	}
+
+	// The superseded version is pruned and its descriptor enters the grace period
+	stats = collect(t, ds, repository.BlobCollectorConfig{HistoryRetention: time.Nanosecond})
+	if want := (repository.BlobCollectorStats{PrunedHistoryEntries: 1, UnreferencedBlobsFound: 1}); stats != want {
+		t.Errorf("collection after the retention = %+v, want %+v", stats, want)
+	}
+	if _, err := deployments.GetDeployment(ctx, deviceId, "deployment", superseded); err != nil {
+		t.Errorf("get deployment within the grace period: %v", err)
+	}
+
+	stats = collect(t, ds, repository.BlobCollectorConfig{HistoryRetention: time.Nanosecond})
+	if want := (repository.BlobCollectorStats{DeploymentBlobs: 1, DeploymentBlobBytes: int64(len("deployment: 1"))}); stats != want {
+		t.Errorf("collection after the grace period = %+v, want %+v", stats, want)
+	}
+	if _, err := deployments.GetDeployment(ctx, deviceId, "deployment", superseded); !errors.Is(err, domain.ErrDeploymentNotFound) {
+		t.Errorf("get collected deployment: %v, want %v", err, domain.ErrDeploymentNotFound)
+	}
+	if _, err := deployments.GetDeployment(ctx, deviceId, "deployment", current); err != nil {
+		t.Errorf("get current deployment: %v", err)
+	}
+}
+
+// Blobs nothing references are deleted once the grace period is over, and counted in the
+// totals of the collector.
+func TestCollectDeletesOrphanedBlobs(t *testing.T) {
+	ds := openDataStore(t)
+	ctx := context.Background()
+	descriptor := []byte("deployment: orphaned")
+	qtx, err := ds.BeginTransaction(ctx)
+	if err != nil {
+		t.Fatalf("begin transaction: %v", err)
+	}
+	if err = qtx.InsertDeploymentBlob(ctx, db.InsertDeploymentBlobParams{
+		Digest:     fmt.Sprintf("sha256:%x", sha256.Sum256(descriptor)),
+		Descriptor: descriptor,
+		SizeBytes:  int64(len(descriptor)),
+	}); err != nil {
+		t.Fatalf("insert deployment blob: %v", err)
+	}
+	if err = qtx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	collector := repository.NewBlobCollector(ds, repository.BlobCollectorConfig{Interval: time.Hour})
+	for i, want := range []repository.BlobCollectorStats{
+		{UnreferencedBlobsFound: 1},
+		{DeploymentBlobs: 1, DeploymentBlobBytes: int64(len(descriptor))},
+		{},
+	} {
+		stats, err := collector.Collect(ctx)
+		if err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+		if stats != want {
+			t.Errorf("collection %d = %+v, want %+v", i+1, stats, want)
+		}
+	}
+
+	want := repository.BlobCollectorTotals{
+		BlobCollectorStats: repository.BlobCollectorStats{
+			UnreferencedBlobsFound: 1,
+			DeploymentBlobs:        1,
+			DeploymentBlobBytes:    int64(len(descriptor)),
+		},
+		Runs: 3,
+	}
+	if totals := collector.Totals(); totals != want {
+		t.Errorf("totals = %+v, want %+v", totals, want)
+	}
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type CapabilitiesRepository struct {
+	ds DataStore
+}
+
+func NewCapabilitiesRepository(ds DataStore) *CapabilitiesRepository {
+	return &CapabilitiesRepository{
+		ds: ds,
+	}
+}
+
+func (cr *CapabilitiesRepository) UpsertCapabilities(ctx context.Context, capabilities domain.DeviceCapabilities) (err error) {
+	qtx, err := cr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if _, err = qtx.GetDeviceId(ctx, capabilities.DeviceId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrDeviceNotFound
+		}
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device: %w", err))
+	}
+
+	if err = qtx.UpsertDeviceCapabilities(ctx, db.UpsertDeviceCapabilitiesParams{
+		DeviceID:     capabilities.DeviceId,
+		ApiVersion:   capabilities.ApiVersion,
+		Vendor:       capabilities.Vendor,
+		ModelNumber:  capabilities.ModelNumber,
+		SerialNumber: capabilities.SerialNumber,
+		CpuCores:     capabilities.CpuCores,
+		Memory:       capabilities.Memory,
+		Storage:      capabilities.Storage,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist device capabilities: %w", err))
+	}
+	if err = qtx.DeleteDeviceCapabilityRoles(ctx, capabilities.DeviceId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete device roles: %w", err))
+	}
+	for _, role := range capabilities.Roles {
+		if err = qtx.InsertDeviceCapabilityRole(ctx, db.InsertDeviceCapabilityRoleParams{
+			DeviceID: capabilities.DeviceId,
+			Role:     role,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist device role: %w", err))
+		}
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return nil
+}
+
+func (cr *CapabilitiesRepository) GetCapabilities(ctx context.Context, deviceId string) (_ *domain.DeviceCapabilities, err error) {
+	qtx, err := cr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	dbCapabilities, err := qtx.GetDeviceCapabilities(ctx, deviceId)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device capabilities: %w", err))
+		}
+		// distinguish an unknown device from one that has not reported yet
+		if _, err = qtx.GetDeviceId(ctx, deviceId); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, domain.ErrDeviceNotFound
+			}
+			return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device: %w", err))
+		}
+		return nil, domain.ErrCapabilitiesNotFound
+	}
+	roles, err := qtx.GetDeviceCapabilityRoles(ctx, deviceId)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device roles: %w", err))
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return &domain.DeviceCapabilities{
+		DeviceId:     dbCapabilities.DeviceID,
+		ApiVersion:   dbCapabilities.ApiVersion,
+		Vendor:       dbCapabilities.Vendor,
+		ModelNumber:  dbCapabilities.ModelNumber,
+		SerialNumber: dbCapabilities.SerialNumber,
+		Roles:        roles,
+		CpuCores:     dbCapabilities.CpuCores,
+		Memory:       dbCapabilities.Memory,
+		Storage:      dbCapabilities.Storage,
+		ReportedAt:   dbCapabilities.ReportedAt,
+	}, nil
+}
+
+// ListGatewayChildCapabilities returns the capabilities reported by the children of a gateway.
+// Roles are not loaded.
+func (cr *CapabilitiesRepository) ListGatewayChildCapabilities(ctx context.Context, gatewayId string) (_ []domain.DeviceCapabilities, err error) {
+	qtx, err := cr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	dbCapabilities, err := qtx.ListGatewayChildCapabilities(ctx, gatewayId)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list child capabilities: %w", err))
+	}
+	capabilities := make([]domain.DeviceCapabilities, len(dbCapabilities))
+	for i, c := range dbCapabilities {
+		capabilities[i] = domain.DeviceCapabilities{
+			DeviceId:     c.DeviceID,
+			ApiVersion:   c.ApiVersion,
+			Vendor:       c.Vendor,
+			ModelNumber:  c.ModelNumber,
+			SerialNumber: c.SerialNumber,
+			CpuCores:     c.CpuCores,
+			Memory:       c.Memory,
+			Storage:      c.Storage,
+			ReportedAt:   c.ReportedAt,
+		}
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return capabilities, nil
+}
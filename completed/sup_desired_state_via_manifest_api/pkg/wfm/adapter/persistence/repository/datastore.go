@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+)
+
+// DataStore is the SQL database the repositories persist to. The sqlitedb and postgresdb
+// adapters implement it; the repositories are shared by both.
+type DataStore interface {
+	// BeginTransaction starts a transaction running the queries of the database.
+	BeginTransaction(ctx context.Context) (Transaction, error)
+}
+
+// Transaction runs the repository queries within a database transaction. Each adapter
+// generates the queries with sqlc from its own query file; their parameters and rows are
+// the types generated for SQLite, which the other adapters convert theirs to.
+type Transaction interface {
+	db.Querier
+
+	// LockDevice locks the device until the transaction ends, so that concurrent writers
+	// of its manifest, possibly on other replicas, apply their changes one after the other.
+	// It returns sql.ErrNoRows when the device does not exist.
+	LockDevice(ctx context.Context, deviceId string) error
+	// LockManifestEvents serializes the transactions appending manifest events, so that
+	// events become visible in the order of their IDs.
+	LockManifestEvents(ctx context.Context) error
+	// TryLockBlobCollection reports whether the transaction may collect blobs, i.e. no other
+	// collection is running.
+	TryLockBlobCollection(ctx context.Context) (bool, error)
+
+	Commit() error
+	Rollback() error
+}
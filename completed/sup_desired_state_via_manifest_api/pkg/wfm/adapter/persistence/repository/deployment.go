@@ -0,0 +1,681 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"slices"
+)
+
+type DeploymentRepository struct {
+	ds                DataStore
+	notifier          port.ManifestNotifier
+	manifestETag      port.ManifestETagFunc
+	aggregateManifest port.ManifestAggregateFunc
+}
+
+func NewDeploymentRepository(ds DataStore, notifier port.ManifestNotifier, manifestETag port.ManifestETagFunc, aggregateManifest port.ManifestAggregateFunc) *DeploymentRepository {
+	return &DeploymentRepository{
+		ds:                ds,
+		notifier:          notifier,
+		manifestETag:      manifestETag,
+		aggregateManifest: aggregateManifest,
+	}
+}
+
+func (dr *DeploymentRepository) UpsertDeployments(ctx context.Context, deviceId string, updateFn func(manifest *domain.ApplicationDeploymentManifest) error) (err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return err
+	}
+
+	if err = lockManifests(ctx, qtx, deviceId); err != nil {
+		return err
+	}
+	changes := aggregateChanges{}
+	if err = upsertManifest(ctx, qtx, deviceId, updateFn, dr.manifestETag, changes); err != nil {
+		return err
+	}
+	if err = changes.rebuild(ctx, qtx, dr.aggregateManifest, dr.manifestETag); err != nil {
+		return err
+	}
+	watchers, err := manifestWatchers(ctx, qtx, deviceId)
+	if err != nil {
+		return err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	dr.notifier.Notify(watchers...)
+	return nil
+}
+
+func (dr *DeploymentRepository) GetDeploymentManifest(ctx context.Context, deviceId string) (_ *domain.ApplicationDeploymentManifest, err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return nil, err
+	}
+
+	// A gateway is served its aggregated manifest once the writes built it
+	manifest, aggregated, err := loadGatewayManifest(ctx, qtx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	if !aggregated {
+		if manifest, err = loadManifestWithDeployments(ctx, deviceId, qtx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return manifest, nil
+}
+
+func (dr *DeploymentRepository) GetDeployment(ctx context.Context, deviceId, deploymentId, digest string) (_ *domain.ApplicationDeployment, err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return nil, err
+	}
+
+	// Only descriptors the device was assigned are served, whether current or superseded
+	blob, err := qtx.GetDeviceDeploymentBlob(ctx, db.GetDeviceDeploymentBlobParams{
+		DeviceID:     deviceId,
+		DeploymentID: deploymentId,
+		Digest:       digest,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrDeploymentNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve deployment blob: %w", err))
+	}
+
+	deployment := &domain.ApplicationDeployment{
+		Id:               deploymentId,
+		Descriptor:       blob.Descriptor,
+		DescriptorDigest: blob.Digest,
+		DescriptorSize:   uint64(blob.SizeBytes),
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return deployment, nil
+}
+
+func (dr *DeploymentRepository) GetBundle(ctx context.Context, deviceId, digest string) (_ []byte, err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return nil, err
+	}
+
+	// Only bundles the device was assigned are served, whether current or superseded
+	row, err := qtx.GetDeviceBundleBlob(ctx, db.GetDeviceBundleBlobParams{
+		DeviceID: deviceId,
+		Digest:   digest,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrBundleNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve bundle blob: %w", err))
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return row.Archive, nil
+}
+
+func (dr *DeploymentRepository) GetRenderedComponent(ctx context.Context, deviceId, deploymentId, name, digest string) (_ *domain.RenderedComponent, err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return nil, err
+	}
+
+	// Only components the device was assigned are served, under the name they were assigned with
+	blob, err := qtx.GetDeviceComponentBlob(ctx, db.GetDeviceComponentBlobParams{
+		DeviceID:     deviceId,
+		DeploymentID: deploymentId,
+		Name:         name,
+		Digest:       digest,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRenderedComponentNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve component blob: %w", err))
+	}
+
+	component := &domain.RenderedComponent{
+		Name:     name,
+		Artifact: blob.Descriptor,
+		Digest:   blob.Digest,
+		Size:     uint64(blob.SizeBytes),
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return component, nil
+}
+
+// upsertManifest lets the caller mutate the manifest of a device and persists the result
+// within the transaction of qtx. Devices without a manifest start with version 1. The device
+// stays locked until the transaction ends, so that concurrent writers apply their changes one
+// after the other instead of publishing the same version. The deployments a new version
+// changed are recorded in changes, whose aggregated manifests the caller rebuilds before
+// commit; a gateway with an aggregated manifest publishes that instead of its own.
+func upsertManifest(ctx context.Context, qtx Transaction, deviceId string, updateFn func(manifest *domain.ApplicationDeploymentManifest) error, etagFn port.ManifestETagFunc, changes aggregateChanges) error {
+	if err := lockDevice(ctx, qtx, deviceId); err != nil {
+		return err
+	}
+	manifest, err := loadManifestWithDeployments(ctx, deviceId, qtx)
+	if err != nil {
+		if errors.Is(err, domain.ErrManifestNotFound) {
+			manifest = &domain.ApplicationDeploymentManifest{Version: 1}
+		} else {
+			return err
+		}
+	}
+	originalVersion := manifest.Version
+	originalDeploymentIDs := make(map[string]struct{}, len(manifest.Deployments))
+	originalFingerprints := make(map[string]string, len(manifest.Deployments))
+	for _, deployment := range manifest.Deployments {
+		originalDeploymentIDs[deployment.Id] = struct{}{}
+		originalFingerprints[deployment.Id] = deploymentFingerprint(deployment)
+	}
+
+	// Let the caller apply mutations
+	if err = updateFn(manifest); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: update callback failed: %w", err))
+	}
+	currentDeploymentIDs := make(map[string]struct{}, len(manifest.Deployments))
+	for _, deployment := range manifest.Deployments {
+		currentDeploymentIDs[deployment.Id] = struct{}{}
+	}
+	for id := range originalDeploymentIDs {
+		if _, ok := currentDeploymentIDs[id]; !ok {
+			if err = qtx.DeleteDeployment(ctx, db.DeleteDeploymentParams{
+				DeviceID: deviceId,
+				ID:       id,
+			}); err != nil {
+				return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete deployment: %w", err))
+			}
+		}
+	}
+
+	if len(manifest.BundleArchive) > 0 && manifest.BundleDigest != "" {
+		if err = qtx.InsertBundleBlob(ctx, db.InsertBundleBlobParams{
+			Digest:    manifest.BundleDigest,
+			Archive:   manifest.BundleArchive,
+			SizeBytes: int64(len(manifest.BundleArchive)),
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist bundle blob: %w", err))
+		}
+	}
+	var bundleDigest sql.NullString
+	if manifest.BundleDigest != "" {
+		bundleDigest = sql.NullString{String: manifest.BundleDigest, Valid: true}
+	}
+	if err = qtx.UpsertManifest(ctx, db.UpsertManifestParams{
+		Version:      int64(manifest.Version),
+		BundleDigest: bundleDigest,
+		DeviceID:     deviceId,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to upsert manifest: %w", err))
+	}
+	for _, deployment := range manifest.Deployments {
+		if err = qtx.InsertDeploymentBlob(ctx, db.InsertDeploymentBlobParams{
+			Digest:     deployment.DescriptorDigest,
+			Descriptor: deployment.Descriptor,
+			SizeBytes:  int64(len(deployment.Descriptor)),
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist deployment blob: %w", err))
+		}
+		if err = qtx.UpsertDeployment(ctx, db.UpsertDeploymentParams{
+			ID:                deployment.Id,
+			DescriptorDigest:  deployment.DescriptorDigest,
+			DeviceID:          deviceId,
+			FleetDeploymentID: deployment.FleetDeploymentId,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to upsert deployment: %w", err))
+		}
+		if err = qtx.DeleteDeploymentComponents(ctx, db.DeleteDeploymentComponentsParams{
+			DeviceID:     deviceId,
+			DeploymentID: deployment.Id,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete deployment components: %w", err))
+		}
+		for i, component := range deployment.Components {
+			if err = qtx.InsertDeploymentBlob(ctx, db.InsertDeploymentBlobParams{
+				Digest:     component.Digest,
+				Descriptor: component.Artifact,
+				SizeBytes:  int64(len(component.Artifact)),
+			}); err != nil {
+				return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist component blob: %w", err))
+			}
+			if err = qtx.InsertDeploymentComponent(ctx, db.InsertDeploymentComponentParams{
+				DeviceID:     deviceId,
+				DeploymentID: deployment.Id,
+				Name:         component.Name,
+				Position:     int64(i),
+				Digest:       component.Digest,
+			}); err != nil {
+				return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert deployment component: %w", err))
+			}
+		}
+	}
+
+	if err = assignBlobs(ctx, qtx, deviceId, manifest); err != nil {
+		return err
+	}
+
+	if manifest.Version == originalVersion {
+		return nil
+	}
+	// A new version was published: record which deployments it added, changed or removed
+	var changed []string
+	for _, deployment := range manifest.Deployments {
+		if fingerprint, ok := originalFingerprints[deployment.Id]; !ok || fingerprint != deploymentFingerprint(deployment) {
+			changed = append(changed, deployment.Id)
+		}
+		delete(originalFingerprints, deployment.Id)
+	}
+	for id := range originalFingerprints {
+		changed = append(changed, id)
+	}
+	if err = changes.add(ctx, qtx, deviceId, changed); err != nil {
+		return err
+	}
+	if _, err = qtx.GetGatewayManifest(ctx, deviceId); err == nil {
+		return nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve gateway manifest: %w", err))
+	}
+	// Publish the manifest as persisted, that is as the manifest endpoint serves it
+	published, err := loadManifestWithDeployments(ctx, deviceId, qtx)
+	if err != nil {
+		return err
+	}
+	return publishManifest(ctx, qtx, deviceId, published, changed, etagFn)
+}
+
+// publishManifest records a new manifest version of the device in the manifest history and
+// appends it to the manifest event log.
+func publishManifest(ctx context.Context, qtx Transaction, deviceId string, published *domain.ApplicationDeploymentManifest, deploymentIds []string, etagFn port.ManifestETagFunc) error {
+	if err := insertManifestHistory(ctx, qtx, deviceId, published); err != nil {
+		return err
+	}
+	etag, err := etagFn(deviceId, published)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to compute manifest ETag: %w", err))
+	}
+	if err = qtx.LockManifestEvents(ctx); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lock manifest events: %w", err))
+	}
+	eventId, err := qtx.InsertManifestEvent(ctx, db.InsertManifestEventParams{
+		DeviceID:        deviceId,
+		ManifestVersion: int64(published.Version),
+		ManifestEtag:    etag,
+	})
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert manifest event: %w", err))
+	}
+	for _, deploymentId := range deploymentIds {
+		if err = qtx.InsertManifestEventDeployment(ctx, db.InsertManifestEventDeploymentParams{
+			EventID:      eventId,
+			DeploymentID: deploymentId,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert manifest event deployment: %w", err))
+		}
+	}
+	return nil
+}
+
+// deploymentFingerprint identifies the content of a deployment: its descriptor and, when
+// rendered, its components.
+func deploymentFingerprint(deployment domain.ApplicationDeployment) string {
+	fingerprint := deployment.DescriptorDigest
+	for _, component := range deployment.Components {
+		fingerprint += "," + component.Name + "=" + component.Digest
+	}
+	return fingerprint
+}
+
+func loadManifestWithDeployments(ctx context.Context, deviceId string, qtx Transaction) (*domain.ApplicationDeploymentManifest, error) {
+	dbManifest, err := qtx.GetManifestByDeviceId(ctx, deviceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrManifestNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest: %w", err))
+	}
+	dbDeployments, err := qtx.GetDeploymentsByDeviceId(ctx, deviceId)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve deployments: %w", err))
+	}
+	dbComponents, err := qtx.GetDeploymentComponentsByDeviceId(ctx, deviceId)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve deployment components: %w", err))
+	}
+	components := make(map[string][]domain.RenderedComponent)
+	for _, dbComponent := range dbComponents {
+		components[dbComponent.DeploymentID] = append(components[dbComponent.DeploymentID], domain.RenderedComponent{
+			Name:     dbComponent.Name,
+			Artifact: dbComponent.Artifact,
+			Digest:   dbComponent.Digest,
+			Size:     uint64(dbComponent.SizeBytes),
+		})
+	}
+	deployments := make([]domain.ApplicationDeployment, len(dbDeployments))
+	for i, dbDeployment := range dbDeployments {
+		deployments[i] = domain.ApplicationDeployment{
+			Id:                dbDeployment.ID,
+			Descriptor:        dbDeployment.Descriptor,
+			DescriptorDigest:  dbDeployment.DescriptorDigest,
+			DescriptorSize:    uint64(dbDeployment.SizeBytes),
+			Components:        components[dbDeployment.ID],
+			FleetDeploymentId: dbDeployment.FleetDeploymentID,
+		}
+	}
+	bundleDigest := ""
+	if dbManifest.BundleDigest.Valid {
+		bundleDigest = dbManifest.BundleDigest.String
+	}
+	manifest := &domain.ApplicationDeploymentManifest{
+		Version:      uint64(dbManifest.Version),
+		BundleDigest: bundleDigest,
+		BundleSize:   uint64(dbManifest.BundleSizeBytes),
+		Deployments:  deployments,
+	}
+	return manifest, nil
+}
+
+// aggregateChanges collects the deployments that changed within a transaction by the gateway
+// aggregating them, so that every aggregated manifest is rebuilt once before commit.
+type aggregateChanges map[string][]string
+
+// add records the deployments that changed in the manifest of the device for its gateway, or
+// for the device itself, which may be a gateway.
+func (c aggregateChanges) add(ctx context.Context, qtx Transaction, deviceId string, deploymentIds []string) error {
+	gatewayId, err := qtx.GetDeviceGateway(ctx, deviceId)
+	if errors.Is(err, sql.ErrNoRows) {
+		gatewayId = deviceId
+	} else if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device gateway: %w", err))
+	}
+	c[gatewayId] = append(c[gatewayId], deploymentIds...)
+	return nil
+}
+
+// rebuild rebuilds the aggregated manifests of the recorded gateways in ID order.
+func (c aggregateChanges) rebuild(ctx context.Context, qtx Transaction, aggregateFn port.ManifestAggregateFunc, etagFn port.ManifestETagFunc) error {
+	for _, gatewayId := range slices.Sorted(maps.Keys(c)) {
+		// A fleet deployment may change for the gateway and several children at once
+		deploymentIds := slices.Compact(slices.Sorted(slices.Values(c[gatewayId])))
+		if err := upsertGatewayManifest(ctx, qtx, gatewayId, deploymentIds, aggregateFn, etagFn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upsertGatewayManifest lets aggregateFn rebuild the bundle and version of the aggregated
+// manifest of a gateway and persists them when they changed. A new version is published like
+// any other manifest version, listing deploymentIds as changed. Devices that neither serve
+// children nor have an aggregate are left alone.
+func upsertGatewayManifest(ctx context.Context, qtx Transaction, gatewayId string, deploymentIds []string, aggregateFn port.ManifestAggregateFunc, etagFn port.ManifestETagFunc) error {
+	if err := lockDevice(ctx, qtx, gatewayId); err != nil {
+		return err
+	}
+	aggregate, exists, err := loadGatewayManifest(ctx, qtx, gatewayId)
+	if err != nil || aggregate == nil {
+		return err
+	}
+
+	previousVersion := aggregate.Version
+	if err = aggregateFn(aggregate); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: aggregate callback failed: %w", err))
+	}
+	if exists && aggregate.Version == previousVersion {
+		return nil
+	}
+
+	if len(aggregate.BundleArchive) > 0 && aggregate.BundleDigest != "" {
+		if err = qtx.InsertBundleBlob(ctx, db.InsertBundleBlobParams{
+			Digest:    aggregate.BundleDigest,
+			Archive:   aggregate.BundleArchive,
+			SizeBytes: int64(len(aggregate.BundleArchive)),
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist bundle blob: %w", err))
+		}
+	}
+	var bundleDigest sql.NullString
+	if aggregate.BundleDigest != "" {
+		bundleDigest = sql.NullString{String: aggregate.BundleDigest, Valid: true}
+	}
+	if err = qtx.UpsertGatewayManifest(ctx, db.UpsertGatewayManifestParams{
+		GatewayID:    gatewayId,
+		Version:      int64(aggregate.Version),
+		BundleDigest: bundleDigest,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to upsert gateway manifest: %w", err))
+	}
+	// The gateway fetches the blobs of its children through the aggregate
+	if err = assignBlobs(ctx, qtx, gatewayId, aggregate); err != nil {
+		return err
+	}
+
+	// A first aggregate without deployments keeps the version of the gateway's own empty
+	// manifest, which was published already
+	if aggregate.Version == previousVersion {
+		return nil
+	}
+	if !exists {
+		// Every deployment of the first aggregate gained its target
+		deploymentIds = nil
+		for _, deployment := range aggregate.Deployments {
+			deploymentIds = append(deploymentIds, deployment.Id)
+		}
+		deploymentIds = slices.Compact(slices.Sorted(slices.Values(deploymentIds)))
+	}
+	return publishManifest(ctx, qtx, gatewayId, aggregate, deploymentIds, etagFn)
+}
+
+// loadGatewayManifest aggregates the deployments of a gateway and its children, each
+// annotated with its target device, under the version and bundle of the stored aggregate and
+// reports whether it was stored. A gateway whose aggregate does not exist yet continues from
+// the version of its own manifest, so that the version it sees never decreases. Devices that
+// neither serve children nor have an aggregate yield nil.
+func loadGatewayManifest(ctx context.Context, qtx Transaction, gatewayId string) (*domain.ApplicationDeploymentManifest, bool, error) {
+	children, err := qtx.ListGatewayChildren(ctx, gatewayId)
+	if err != nil {
+		return nil, false, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list gateway children: %w", err))
+	}
+	stored, err := qtx.GetGatewayManifest(ctx, gatewayId)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve gateway manifest: %w", err))
+	}
+	if !exists && len(children) == 0 {
+		return nil, false, nil
+	}
+
+	aggregate := &domain.ApplicationDeploymentManifest{Version: 1}
+	if exists {
+		aggregate.Version = uint64(stored.Version)
+		aggregate.BundleDigest = stored.BundleDigest.String
+		aggregate.BundleSize = uint64(stored.BundleSizeBytes)
+	}
+	for _, target := range append([]string{gatewayId}, children...) {
+		manifest, err := loadManifestWithDeployments(ctx, target, qtx)
+		if errors.Is(err, domain.ErrManifestNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if target == gatewayId && !exists {
+			aggregate.Version = manifest.Version
+		}
+		for _, deployment := range manifest.Deployments {
+			deployment.Target = target
+			aggregate.Deployments = append(aggregate.Deployments, deployment)
+		}
+	}
+	return aggregate, exists, nil
+}
+
+// assignBlobs records that the blobs of the manifest were assigned to the device, which
+// entitles it to fetch them even after the manifest was superseded.
+func assignBlobs(ctx context.Context, qtx Transaction, deviceId string, manifest *domain.ApplicationDeploymentManifest) error {
+	if manifest.BundleDigest != "" {
+		if err := qtx.InsertDeviceBundleBlob(ctx, db.InsertDeviceBundleBlobParams{
+			DeviceID: deviceId,
+			Digest:   manifest.BundleDigest,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to assign bundle blob: %w", err))
+		}
+	}
+	for _, deployment := range manifest.Deployments {
+		if err := qtx.InsertDeviceDeploymentBlob(ctx, db.InsertDeviceDeploymentBlobParams{
+			DeviceID:     deviceId,
+			DeploymentID: deployment.Id,
+			Digest:       deployment.DescriptorDigest,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to assign deployment blob: %w", err))
+		}
+		for _, component := range deployment.Components {
+			if err := qtx.InsertDeviceComponentBlob(ctx, db.InsertDeviceComponentBlobParams{
+				DeviceID:     deviceId,
+				DeploymentID: deployment.Id,
+				Name:         component.Name,
+				Digest:       component.Digest,
+			}); err != nil {
+				return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to assign component blob: %w", err))
+			}
+		}
+	}
+	return nil
+}
+
+// manifestWatchers returns the devices whose manifests change along with those of
+// deviceIds: the devices themselves and the gateways aggregating them. Repositories notify
+// them once the change is committed.
+func manifestWatchers(ctx context.Context, qtx Transaction, deviceIds ...string) ([]string, error) {
+	watchers := make([]string, 0, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		watchers = append(watchers, deviceId)
+		gatewayId, err := qtx.GetDeviceGateway(ctx, deviceId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device gateway: %w", err))
+		}
+		watchers = append(watchers, gatewayId)
+	}
+	return watchers, nil
+}
+
+// lockManifests locks the devices whose manifests are about to change together with the
+// gateways aggregating them. Devices without a gateway, which may be gateways themselves, are
+// locked ahead of the children, each group in ID order, so that writers rebuilding the same
+// aggregated manifest never wait on each other in a cycle.
+func lockManifests(ctx context.Context, qtx Transaction, deviceIds ...string) error {
+	var gateways, children []string
+	for _, deviceId := range deviceIds {
+		gatewayId, err := qtx.GetDeviceGateway(ctx, deviceId)
+		if errors.Is(err, sql.ErrNoRows) {
+			gateways = append(gateways, deviceId)
+			continue
+		} else if err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device gateway: %w", err))
+		}
+		gateways = append(gateways, gatewayId)
+		children = append(children, deviceId)
+	}
+	slices.Sort(gateways)
+	slices.Sort(children)
+	for _, deviceId := range append(slices.Compact(gateways), slices.Compact(children)...) {
+		if err := lockDevice(ctx, qtx, deviceId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureDeviceExists(ctx context.Context, qtx Transaction, deviceId string) error {
+	if _, err := qtx.GetDeviceId(ctx, deviceId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrDeviceNotFound
+		}
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device: %w", err))
+	}
+	return nil
+}
+
+// lockDevice locks the device until the end of the transaction.
+func lockDevice(ctx context.Context, qtx Transaction, deviceId string) error {
+	if err := qtx.LockDevice(ctx, deviceId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrDeviceNotFound
+		}
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lock device: %w", err))
+	}
+	return nil
+}
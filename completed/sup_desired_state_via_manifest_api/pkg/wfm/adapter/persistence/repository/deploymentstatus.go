@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type DeploymentStatusRepository struct {
+	ds DataStore
+}
+
+func NewDeploymentStatusRepository(ds DataStore) *DeploymentStatusRepository {
+	return &DeploymentStatusRepository{
+		ds: ds,
+	}
+}
+
+func (dsr *DeploymentStatusRepository) InsertDeploymentStatus(ctx context.Context, status domain.DeploymentStatus) (err error) {
+	qtx, err := dsr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if _, err = qtx.GetDeviceId(ctx, status.DeviceId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrDeviceNotFound
+		}
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device: %w", err))
+	}
+
+	errorCode, errorMessage := fromDomainStatusError(status.Error)
+	statusId, err := qtx.InsertDeploymentStatus(ctx, db.InsertDeploymentStatusParams{
+		DeviceID:     status.DeviceId,
+		DeploymentID: status.DeploymentId,
+		ApiVersion:   status.ApiVersion,
+		Digest:       status.Digest,
+		State:        status.State,
+		ErrorCode:    errorCode,
+		ErrorMessage: errorMessage,
+	})
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist deployment status: %w", err))
+	}
+	for _, component := range status.Components {
+		errorCode, errorMessage := fromDomainStatusError(component.Error)
+		if err = qtx.InsertDeploymentComponentStatus(ctx, db.InsertDeploymentComponentStatusParams{
+			StatusID:     statusId,
+			Name:         component.Name,
+			State:        component.State,
+			ErrorCode:    errorCode,
+			ErrorMessage: errorMessage,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist component status: %w", err))
+		}
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return nil
+}
+
+func (dsr *DeploymentStatusRepository) ListDeploymentStatuses(ctx context.Context, deviceId, deploymentId string, limit int) (_ []domain.DeploymentStatus, err error) {
+	qtx, err := dsr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if _, err = qtx.GetDeviceId(ctx, deviceId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrDeviceNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device: %w", err))
+	}
+
+	dbStatuses, err := qtx.ListDeploymentStatuses(ctx, db.ListDeploymentStatusesParams{
+		DeviceID:     deviceId,
+		DeploymentID: deploymentId,
+		Limit:        int64(limit),
+	})
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list deployment statuses: %w", err))
+	}
+	statusIds := make([]int64, len(dbStatuses))
+	for i, dbStatus := range dbStatuses {
+		statusIds[i] = dbStatus.ID
+	}
+	dbComponents, err := qtx.GetDeploymentComponentStatusesByStatusIds(ctx, statusIds)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list component statuses: %w", err))
+	}
+	components := make(map[int64][]domain.ComponentStatus, len(dbStatuses))
+	for _, dbComponent := range dbComponents {
+		components[dbComponent.StatusID] = append(components[dbComponent.StatusID], domain.ComponentStatus{
+			Name:  dbComponent.Name,
+			State: dbComponent.State,
+			Error: toDomainStatusError(dbComponent.ErrorCode, dbComponent.ErrorMessage),
+		})
+	}
+
+	statuses := make([]domain.DeploymentStatus, len(dbStatuses))
+	for i, dbStatus := range dbStatuses {
+		statuses[i] = domain.DeploymentStatus{
+			DeviceId:     dbStatus.DeviceID,
+			DeploymentId: dbStatus.DeploymentID,
+			ApiVersion:   dbStatus.ApiVersion,
+			Digest:       dbStatus.Digest,
+			State:        dbStatus.State,
+			Error:        toDomainStatusError(dbStatus.ErrorCode, dbStatus.ErrorMessage),
+			Components:   components[dbStatus.ID],
+			ReportedAt:   dbStatus.ReportedAt,
+		}
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return statuses, nil
+}
+
+func fromDomainStatusError(statusError *domain.StatusError) (code, message string) {
+	if statusError == nil {
+		return "", ""
+	}
+	return statusError.Code, statusError.Message
+}
+
+func toDomainStatusError(code, message string) *domain.StatusError {
+	if code == "" && message == "" {
+		return nil
+	}
+	return &domain.StatusError{Code: code, Message: message}
+}
@@ -0,0 +1,487 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"slices"
+	"sort"
+)
+
+type DeviceRepository struct {
+	ds                DataStore
+	notifier          port.ManifestNotifier
+	manifestETag      port.ManifestETagFunc
+	aggregateManifest port.ManifestAggregateFunc
+}
+
+func NewDeviceRepository(ds DataStore, notifier port.ManifestNotifier, manifestETag port.ManifestETagFunc, aggregateManifest port.ManifestAggregateFunc) *DeviceRepository {
+	return &DeviceRepository{
+		ds:                ds,
+		notifier:          notifier,
+		manifestETag:      manifestETag,
+		aggregateManifest: aggregateManifest,
+	}
+}
+
+func (dr *DeviceRepository) CreateDevice(ctx context.Context, device *domain.Device, syncFn port.FleetSyncFunc) (err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if _, err = qtx.GetDeviceId(ctx, device.Id); err == nil {
+		return domain.ErrDeviceAlreadyExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device: %w", err))
+	}
+
+	if err = qtx.CreateDevice(ctx, db.CreateDeviceParams{
+		ID:          device.Id,
+		DisplayName: device.DisplayName,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to create device: %w", err))
+	}
+	if err = replaceDeviceLabels(ctx, qtx, device.Id, device.Labels); err != nil {
+		return err
+	}
+	if err = setDeviceGateway(ctx, qtx, device.Id, device.GatewayId); err != nil {
+		return err
+	}
+	if err = restoreManifestVersion(ctx, qtx, device.Id, dr.manifestETag); err != nil {
+		return err
+	}
+	created, err := loadDevice(ctx, qtx, device.Id)
+	if err != nil {
+		return err
+	}
+	*device = *created
+	changes := aggregateChanges{}
+	var watchers []string
+	if syncFn != nil {
+		if watchers, err = syncDevices(ctx, qtx, []domain.Device{*created}, syncFn, dr.manifestETag, changes); err != nil {
+			return err
+		}
+	}
+	// A new child changes the aggregated manifest of its gateway
+	if err = changes.add(ctx, qtx, created.Id, nil); err != nil {
+		return err
+	}
+	if err = changes.rebuild(ctx, qtx, dr.aggregateManifest, dr.manifestETag); err != nil {
+		return err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	if device.GatewayId != "" {
+		watchers = append(watchers, device.GatewayId)
+	}
+	if len(watchers) > 0 {
+		dr.notifier.Notify(watchers...)
+	}
+	return nil
+}
+
+func (dr *DeviceRepository) ListDevices(ctx context.Context, after string, limit int) (_ []domain.Device, err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	dbDevices, err := qtx.ListDevices(ctx, db.ListDevicesParams{
+		ID:    after,
+		Limit: int64(limit),
+	})
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list devices: %w", err))
+	}
+	deviceIds := make([]string, len(dbDevices))
+	for i, dbDevice := range dbDevices {
+		deviceIds[i] = dbDevice.ID
+	}
+	dbLabels, err := qtx.GetDeviceLabelsByDeviceIds(ctx, deviceIds)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device labels: %w", err))
+	}
+	labels := make(map[string]map[string]string, len(dbDevices))
+	for _, label := range dbLabels {
+		if labels[label.DeviceID] == nil {
+			labels[label.DeviceID] = map[string]string{}
+		}
+		labels[label.DeviceID][label.Key] = label.Value
+	}
+	dbGateways, err := qtx.GetDeviceGatewaysByDeviceIds(ctx, deviceIds)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device gateways: %w", err))
+	}
+	gateways := make(map[string]string, len(dbGateways))
+	for _, gateway := range dbGateways {
+		gateways[gateway.DeviceID] = gateway.GatewayID
+	}
+
+	devices := make([]domain.Device, len(dbDevices))
+	for i, dbDevice := range dbDevices {
+		devices[i] = toDomainDevice(dbDevice, labels[dbDevice.ID])
+		devices[i].GatewayId = gateways[dbDevice.ID]
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return devices, nil
+}
+
+func (dr *DeviceRepository) GetDevice(ctx context.Context, deviceId string) (_ *domain.Device, err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	device, err := loadDevice(ctx, qtx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return device, nil
+}
+
+func (dr *DeviceRepository) UpdateDevice(ctx context.Context, deviceId string, updateFn func(device *domain.Device) error, syncFn port.FleetSyncFunc) (_ *domain.Device, err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	device, err := loadDevice(ctx, qtx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	previousGatewayId := device.GatewayId
+
+	// Let the caller apply mutations
+	if err = updateFn(device); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: update callback failed: %w", err))
+	}
+
+	if err = qtx.UpdateDevice(ctx, db.UpdateDeviceParams{
+		DisplayName: device.DisplayName,
+		ID:          deviceId,
+	}); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to update device: %w", err))
+	}
+	if err = replaceDeviceLabels(ctx, qtx, deviceId, device.Labels); err != nil {
+		return nil, err
+	}
+	changes := aggregateChanges{}
+	if device.GatewayId != previousGatewayId {
+		// The deployments of the device move from one aggregated manifest to the other
+		if err = lockGatewayChange(ctx, qtx, deviceId, previousGatewayId, device.GatewayId); err != nil {
+			return nil, err
+		}
+		deploymentIds, err := manifestDeploymentIds(ctx, qtx, deviceId)
+		if err != nil {
+			return nil, err
+		}
+		for _, gatewayId := range nonEmpty(previousGatewayId, device.GatewayId) {
+			changes[gatewayId] = append(changes[gatewayId], deploymentIds...)
+		}
+	}
+	if err = setDeviceGateway(ctx, qtx, deviceId, device.GatewayId); err != nil {
+		return nil, err
+	}
+	updated, err := loadDevice(ctx, qtx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	var watchers []string
+	if syncFn != nil {
+		if watchers, err = syncDevices(ctx, qtx, []domain.Device{*updated}, syncFn, dr.manifestETag, changes); err != nil {
+			return nil, err
+		}
+	}
+	if err = changes.rebuild(ctx, qtx, dr.aggregateManifest, dr.manifestETag); err != nil {
+		return nil, err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	if updated.GatewayId != previousGatewayId {
+		watchers = append(watchers, nonEmpty(previousGatewayId, updated.GatewayId)...)
+	}
+	if len(watchers) > 0 {
+		dr.notifier.Notify(watchers...)
+	}
+	return updated, nil
+}
+
+// DeleteDevice removes a device together with its deployments and manifest. Those tables
+// reference the device without ON DELETE CASCADE, so they are cleared explicitly first.
+// Blobs are content-addressable and may be shared with other devices; they are kept.
+func (dr *DeviceRepository) DeleteDevice(ctx context.Context, deviceId string) (err error) {
+	qtx, err := dr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return err
+	}
+	if err = lockManifests(ctx, qtx, deviceId); err != nil {
+		return err
+	}
+	watchers, err := manifestWatchers(ctx, qtx, deviceId)
+	if err != nil {
+		return err
+	}
+	// A deleted child takes its deployments out of the aggregated manifest of its gateway
+	changes := aggregateChanges{}
+	if gatewayId, err := qtx.GetDeviceGateway(ctx, deviceId); err == nil {
+		if changes[gatewayId], err = manifestDeploymentIds(ctx, qtx, deviceId); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device gateway: %w", err))
+	}
+	if err = recordDecommissionedVersion(ctx, qtx, deviceId); err != nil {
+		return err
+	}
+	if err = qtx.DeleteDeploymentsByDeviceId(ctx, deviceId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete deployments: %w", err))
+	}
+	if err = qtx.DeleteManifestByDeviceId(ctx, deviceId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete manifest: %w", err))
+	}
+	if err = qtx.DeleteDevice(ctx, deviceId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete device: %w", err))
+	}
+	if err = changes.rebuild(ctx, qtx, dr.aggregateManifest, dr.manifestETag); err != nil {
+		return err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	dr.notifier.Notify(watchers...)
+	return nil
+}
+
+// recordDecommissionedVersion keeps the highest manifest version served to the device: that
+// of its own manifest or, for a gateway, of its aggregated manifest.
+func recordDecommissionedVersion(ctx context.Context, qtx Transaction, deviceId string) error {
+	var version int64
+	if manifest, err := qtx.GetManifestByDeviceId(ctx, deviceId); err == nil {
+		version = manifest.Version
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest: %w", err))
+	}
+	if manifest, err := qtx.GetGatewayManifest(ctx, deviceId); err == nil {
+		version = max(version, manifest.Version)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve gateway manifest: %w", err))
+	}
+	if version == 0 {
+		return nil
+	}
+	if err := qtx.UpsertDecommissionedDevice(ctx, db.UpsertDecommissionedDeviceParams{
+		ID:              deviceId,
+		ManifestVersion: version,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to record decommissioned device: %w", err))
+	}
+	return nil
+}
+
+// restoreManifestVersion continues the manifest versions of a decommissioned device registered
+// again under the same ID: it publishes an empty manifest following the last version served
+// before, so that the clients of the device do not reject the manifest as a rollback.
+func restoreManifestVersion(ctx context.Context, qtx Transaction, deviceId string, etagFn port.ManifestETagFunc) error {
+	version, err := qtx.GetDecommissionedManifestVersion(ctx, deviceId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve decommissioned device: %w", err))
+	}
+	manifest := &domain.ApplicationDeploymentManifest{Version: uint64(version) + 1}
+	if err = qtx.UpsertManifest(ctx, db.UpsertManifestParams{
+		Version:  int64(manifest.Version),
+		DeviceID: deviceId,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to upsert manifest: %w", err))
+	}
+	if err = publishManifest(ctx, qtx, deviceId, manifest, nil, etagFn); err != nil {
+		return err
+	}
+	if err = qtx.DeleteDecommissionedDevice(ctx, deviceId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete decommissioned device: %w", err))
+	}
+	return nil
+}
+
+func loadDevice(ctx context.Context, qtx Transaction, deviceId string) (*domain.Device, error) {
+	dbDevice, err := qtx.GetDevice(ctx, deviceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrDeviceNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device: %w", err))
+	}
+	dbLabels, err := qtx.GetDeviceLabels(ctx, deviceId)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device labels: %w", err))
+	}
+	labels := make(map[string]string, len(dbLabels))
+	for _, label := range dbLabels {
+		labels[label.Key] = label.Value
+	}
+	device := toDomainDevice(dbDevice, labels)
+	if device.GatewayId, err = qtx.GetDeviceGateway(ctx, deviceId); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device gateway: %w", err))
+	}
+	return &device, nil
+}
+
+// lockGatewayChange locks a device moving between gateways in the order of lockManifests:
+// the gateways it leaves and joins, and the device itself while it has no gateway, ahead of
+// the device as a child. A gateway that does not exist is reported by setDeviceGateway.
+func lockGatewayChange(ctx context.Context, qtx Transaction, deviceId, previousGatewayId, gatewayId string) error {
+	gatewayIds := nonEmpty(previousGatewayId, gatewayId)
+	if previousGatewayId == "" {
+		gatewayIds = append(gatewayIds, deviceId)
+	}
+	slices.Sort(gatewayIds)
+	for _, id := range append(slices.Compact(gatewayIds), deviceId) {
+		if err := lockDevice(ctx, qtx, id); err != nil && !errors.Is(err, domain.ErrDeviceNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifestDeploymentIds returns the IDs of the deployments in the manifest of the device.
+func manifestDeploymentIds(ctx context.Context, qtx Transaction, deviceId string) ([]string, error) {
+	manifest, err := loadManifestWithDeployments(ctx, deviceId, qtx)
+	if errors.Is(err, domain.ErrManifestNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	deploymentIds := make([]string, len(manifest.Deployments))
+	for i, deployment := range manifest.Deployments {
+		deploymentIds[i] = deployment.Id
+	}
+	return deploymentIds, nil
+}
+
+// setDeviceGateway attaches the device to the gateway, or detaches it when gatewayId is
+// empty. Relationships are one level deep: a gateway cannot be a child and a child cannot
+// serve other devices.
+func setDeviceGateway(ctx context.Context, qtx Transaction, deviceId, gatewayId string) error {
+	if gatewayId == "" {
+		if err := qtx.DeleteDeviceGateway(ctx, deviceId); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to detach device from gateway: %w", err))
+		}
+		return nil
+	}
+	if err := ensureDeviceExists(ctx, qtx, gatewayId); err != nil {
+		if errors.Is(err, domain.ErrDeviceNotFound) {
+			return errors.Join(domain.ErrInvalidDevice, fmt.Errorf("db: gateway %q not found", gatewayId))
+		}
+		return err
+	}
+	if _, err := qtx.GetDeviceGateway(ctx, gatewayId); err == nil {
+		return errors.Join(domain.ErrInvalidDevice, fmt.Errorf("db: gateway %q is itself served by a gateway", gatewayId))
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device gateway: %w", err))
+	}
+	children, err := qtx.ListGatewayChildren(ctx, deviceId)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list gateway children: %w", err))
+	}
+	if len(children) > 0 {
+		return errors.Join(domain.ErrInvalidDevice, fmt.Errorf("db: device %q is a gateway and cannot be served by another", deviceId))
+	}
+	if err := qtx.UpsertDeviceGateway(ctx, db.UpsertDeviceGatewayParams{
+		DeviceID:  deviceId,
+		GatewayID: gatewayId,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to attach device to gateway: %w", err))
+	}
+	return nil
+}
+
+func replaceDeviceLabels(ctx context.Context, qtx Transaction, deviceId string, labels map[string]string) error {
+	if err := qtx.DeleteDeviceLabels(ctx, deviceId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete device labels: %w", err))
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := qtx.InsertDeviceLabel(ctx, db.InsertDeviceLabelParams{
+			DeviceID: deviceId,
+			Key:      key,
+			Value:    labels[key],
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert device label: %w", err))
+		}
+	}
+	return nil
+}
+
+func toDomainDevice(dbDevice db.Device, labels map[string]string) domain.Device {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return domain.Device{
+		Id:          dbDevice.ID,
+		DisplayName: dbDevice.DisplayName,
+		Labels:      labels,
+		CreatedAt:   dbDevice.CreatedAt,
+		UpdatedAt:   dbDevice.UpdatedAt,
+	}
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,425 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"slices"
+	"sort"
+)
+
+type FleetDeploymentRepository struct {
+	ds                DataStore
+	notifier          port.ManifestNotifier
+	manifestETag      port.ManifestETagFunc
+	aggregateManifest port.ManifestAggregateFunc
+}
+
+func NewFleetDeploymentRepository(ds DataStore, notifier port.ManifestNotifier, manifestETag port.ManifestETagFunc, aggregateManifest port.ManifestAggregateFunc) *FleetDeploymentRepository {
+	return &FleetDeploymentRepository{
+		ds:                ds,
+		notifier:          notifier,
+		manifestETag:      manifestETag,
+		aggregateManifest: aggregateManifest,
+	}
+}
+
+func (fr *FleetDeploymentRepository) CreateFleetDeployment(ctx context.Context, fleet *domain.FleetDeployment, syncFn port.FleetSyncFunc) (err error) {
+	qtx, err := fr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = qtx.InsertDeploymentBlob(ctx, db.InsertDeploymentBlobParams{
+		Digest:     fleet.DescriptorDigest,
+		Descriptor: fleet.Descriptor,
+		SizeBytes:  int64(len(fleet.Descriptor)),
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist deployment blob: %w", err))
+	}
+	if err = qtx.CreateFleetDeployment(ctx, db.CreateFleetDeploymentParams{
+		ID:               fleet.Id,
+		DescriptorDigest: fleet.DescriptorDigest,
+		DeviceGroup:      fleet.Selector.Group,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to create fleet deployment: %w", err))
+	}
+	if err = replaceSelectorLabels(ctx, qtx, fleet.Id, fleet.Selector.MatchLabels); err != nil {
+		return err
+	}
+
+	targets, err := selectDevices(ctx, qtx, fleet.Selector)
+	if err != nil {
+		return err
+	}
+	changes := aggregateChanges{}
+	watchers, err := syncDevices(ctx, qtx, targets, syncFn, fr.manifestETag, changes)
+	if err != nil {
+		return err
+	}
+	if err = changes.rebuild(ctx, qtx, fr.aggregateManifest, fr.manifestETag); err != nil {
+		return err
+	}
+	created, err := loadFleetDeployment(ctx, qtx, fleet.Id)
+	if err != nil {
+		return err
+	}
+	*fleet = *created
+
+	if err = qtx.Commit(); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	fr.notifier.Notify(watchers...)
+	return nil
+}
+
+func (fr *FleetDeploymentRepository) UpdateFleetDeployment(ctx context.Context, fleetId string, updateFn func(fleet *domain.FleetDeployment) error, syncFn port.FleetSyncFunc) (_ *domain.FleetDeployment, err error) {
+	qtx, err := fr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	fleet, err := loadFleetDeployment(ctx, qtx, fleetId)
+	if err != nil {
+		return nil, err
+	}
+	carriers := fleet.Devices
+
+	// Let the caller apply mutations
+	if err = updateFn(fleet); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: update callback failed: %w", err))
+	}
+
+	if err = qtx.InsertDeploymentBlob(ctx, db.InsertDeploymentBlobParams{
+		Digest:     fleet.DescriptorDigest,
+		Descriptor: fleet.Descriptor,
+		SizeBytes:  int64(len(fleet.Descriptor)),
+	}); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist deployment blob: %w", err))
+	}
+	if err = qtx.UpdateFleetDeployment(ctx, db.UpdateFleetDeploymentParams{
+		DescriptorDigest: fleet.DescriptorDigest,
+		DeviceGroup:      fleet.Selector.Group,
+		ID:               fleetId,
+	}); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to update fleet deployment: %w", err))
+	}
+	if err = replaceSelectorLabels(ctx, qtx, fleetId, fleet.Selector.MatchLabels); err != nil {
+		return nil, err
+	}
+
+	// Sync the devices carrying the deployment, so that those no longer selected drop it,
+	// and the devices selected now
+	targets, err := selectDevices(ctx, qtx, fleet.Selector, carriers...)
+	if err != nil {
+		return nil, err
+	}
+	changes := aggregateChanges{}
+	watchers, err := syncDevices(ctx, qtx, targets, syncFn, fr.manifestETag, changes)
+	if err != nil {
+		return nil, err
+	}
+	if err = changes.rebuild(ctx, qtx, fr.aggregateManifest, fr.manifestETag); err != nil {
+		return nil, err
+	}
+	updated, err := loadFleetDeployment(ctx, qtx, fleetId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	fr.notifier.Notify(watchers...)
+	return updated, nil
+}
+
+func (fr *FleetDeploymentRepository) DeleteFleetDeployment(ctx context.Context, fleetId string, syncFn port.FleetSyncFunc) (err error) {
+	qtx, err := fr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	fleet, err := loadFleetDeployment(ctx, qtx, fleetId)
+	if err != nil {
+		return err
+	}
+	if err = qtx.DeleteFleetDeployment(ctx, fleetId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete fleet deployment: %w", err))
+	}
+
+	// Without the fleet deployment, syncing the devices carrying it removes it from them
+	targets, err := loadDevices(ctx, qtx, fleet.Devices)
+	if err != nil {
+		return err
+	}
+	changes := aggregateChanges{}
+	watchers, err := syncDevices(ctx, qtx, targets, syncFn, fr.manifestETag, changes)
+	if err != nil {
+		return err
+	}
+	if err = changes.rebuild(ctx, qtx, fr.aggregateManifest, fr.manifestETag); err != nil {
+		return err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	fr.notifier.Notify(watchers...)
+	return nil
+}
+
+func (fr *FleetDeploymentRepository) ListFleetDeployments(ctx context.Context) (_ []domain.FleetDeployment, err error) {
+	qtx, err := fr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	fleets, err := listFleetDeployments(ctx, qtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return fleets, nil
+}
+
+func (fr *FleetDeploymentRepository) GetFleetDeployment(ctx context.Context, fleetId string) (_ *domain.FleetDeployment, err error) {
+	qtx, err := fr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	fleet, err := loadFleetDeployment(ctx, qtx, fleetId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return fleet, nil
+}
+
+// syncDevices hands the manifest of every device to syncFn together with the current fleet
+// deployments and persists the result, recording the changed deployments in changes. It
+// returns the devices to notify after commit.
+func syncDevices(ctx context.Context, qtx Transaction, devices []domain.Device, syncFn port.FleetSyncFunc, etagFn port.ManifestETagFunc, changes aggregateChanges) ([]string, error) {
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	fleets, err := listFleetDeployments(ctx, qtx)
+	if err != nil {
+		return nil, err
+	}
+	deviceIds := make([]string, len(devices))
+	for i, device := range devices {
+		deviceIds[i] = device.Id
+	}
+	if err = lockManifests(ctx, qtx, deviceIds...); err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if err = upsertManifest(ctx, qtx, device.Id, func(manifest *domain.ApplicationDeploymentManifest) error {
+			return syncFn(device, fleets, manifest)
+		}, etagFn, changes); err != nil {
+			return nil, err
+		}
+	}
+	return manifestWatchers(ctx, qtx, deviceIds...)
+}
+
+// selectDevices loads the devices the selector matches, along with the devices of deviceIds.
+// The devices are looked up by label, one label at a time.
+func selectDevices(ctx context.Context, qtx Transaction, selector domain.DeviceSelector, deviceIds ...string) ([]domain.Device, error) {
+	labels := make([]db.ListDeviceIdsByLabelParams, 0, len(selector.MatchLabels)+1)
+	if selector.Group != "" {
+		labels = append(labels, db.ListDeviceIdsByLabelParams{Key: domain.DeviceGroupLabel, Value: selector.Group})
+	}
+	for key, value := range selector.MatchLabels {
+		labels = append(labels, db.ListDeviceIdsByLabelParams{Key: key, Value: value})
+	}
+
+	// An empty selector matches no device
+	var selected []string
+	for i, label := range labels {
+		labelled, err := qtx.ListDeviceIdsByLabel(ctx, label)
+		if err != nil {
+			return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to select devices by label: %w", err))
+		}
+		if i == 0 {
+			selected = labelled
+			continue
+		}
+		carrying := make(map[string]struct{}, len(labelled))
+		for _, deviceId := range labelled {
+			carrying[deviceId] = struct{}{}
+		}
+		selected = slices.DeleteFunc(selected, func(deviceId string) bool {
+			_, ok := carrying[deviceId]
+			return !ok
+		})
+	}
+
+	selected = append(selected, deviceIds...)
+	slices.Sort(selected)
+	return loadDevices(ctx, qtx, slices.Compact(selected))
+}
+
+// loadDevices loads the devices of deviceIds with their labels, ordered by ID. Devices that
+// do not exist are skipped.
+func loadDevices(ctx context.Context, qtx Transaction, deviceIds []string) ([]domain.Device, error) {
+	if len(deviceIds) == 0 {
+		return nil, nil
+	}
+	dbDevices, err := qtx.GetDevicesByIds(ctx, deviceIds)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve devices: %w", err))
+	}
+	dbLabels, err := qtx.GetDeviceLabelsByDeviceIds(ctx, deviceIds)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device labels: %w", err))
+	}
+	labels := make(map[string]map[string]string, len(dbDevices))
+	for _, label := range dbLabels {
+		if labels[label.DeviceID] == nil {
+			labels[label.DeviceID] = map[string]string{}
+		}
+		labels[label.DeviceID][label.Key] = label.Value
+	}
+
+	devices := make([]domain.Device, len(dbDevices))
+	for i, dbDevice := range dbDevices {
+		devices[i] = toDomainDevice(dbDevice, labels[dbDevice.ID])
+	}
+	return devices, nil
+}
+
+func listFleetDeployments(ctx context.Context, qtx Transaction) ([]domain.FleetDeployment, error) {
+	dbFleets, err := qtx.ListFleetDeployments(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list fleet deployments: %w", err))
+	}
+	dbLabels, err := qtx.ListFleetDeploymentSelectorLabels(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve selector labels: %w", err))
+	}
+	labels := make(map[string]map[string]string, len(dbFleets))
+	for _, label := range dbLabels {
+		if labels[label.FleetDeploymentID] == nil {
+			labels[label.FleetDeploymentID] = map[string]string{}
+		}
+		labels[label.FleetDeploymentID][label.Key] = label.Value
+	}
+	dbDevices, err := qtx.ListFleetDeploymentDevices(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve fleet deployment devices: %w", err))
+	}
+	devices := make(map[string][]string, len(dbFleets))
+	for _, dbDevice := range dbDevices {
+		devices[dbDevice.FleetDeploymentID] = append(devices[dbDevice.FleetDeploymentID], dbDevice.DeviceID)
+	}
+
+	fleets := make([]domain.FleetDeployment, len(dbFleets))
+	for i, dbFleet := range dbFleets {
+		fleets[i] = toDomainFleetDeployment(db.GetFleetDeploymentRow(dbFleet), labels[dbFleet.ID], devices[dbFleet.ID])
+	}
+	return fleets, nil
+}
+
+func loadFleetDeployment(ctx context.Context, qtx Transaction, fleetId string) (*domain.FleetDeployment, error) {
+	dbFleet, err := qtx.GetFleetDeployment(ctx, fleetId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrFleetDeploymentNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve fleet deployment: %w", err))
+	}
+	dbLabels, err := qtx.GetFleetDeploymentSelectorLabels(ctx, fleetId)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve selector labels: %w", err))
+	}
+	labels := make(map[string]string, len(dbLabels))
+	for _, label := range dbLabels {
+		labels[label.Key] = label.Value
+	}
+	devices, err := qtx.GetFleetDeploymentDevices(ctx, fleetId)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve fleet deployment devices: %w", err))
+	}
+	fleet := toDomainFleetDeployment(dbFleet, labels, devices)
+	return &fleet, nil
+}
+
+func replaceSelectorLabels(ctx context.Context, qtx Transaction, fleetId string, labels map[string]string) error {
+	if err := qtx.DeleteFleetDeploymentSelectorLabels(ctx, fleetId); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to delete selector labels: %w", err))
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := qtx.InsertFleetDeploymentSelectorLabel(ctx, db.InsertFleetDeploymentSelectorLabelParams{
+			FleetDeploymentID: fleetId,
+			Key:               key,
+			Value:             labels[key],
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert selector label: %w", err))
+		}
+	}
+	return nil
+}
+
+func toDomainFleetDeployment(dbFleet db.GetFleetDeploymentRow, labels map[string]string, devices []string) domain.FleetDeployment {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if devices == nil {
+		devices = []string{}
+	}
+	return domain.FleetDeployment{
+		Id: dbFleet.ID,
+		Selector: domain.DeviceSelector{
+			MatchLabels: labels,
+			Group:       dbFleet.DeviceGroup,
+		},
+		Descriptor:       dbFleet.Descriptor,
+		DescriptorDigest: dbFleet.DescriptorDigest,
+		Devices:          devices,
+		CreatedAt:        dbFleet.CreatedAt,
+		UpdatedAt:        dbFleet.UpdatedAt,
+	}
+}
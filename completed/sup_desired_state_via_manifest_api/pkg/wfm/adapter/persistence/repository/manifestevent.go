@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+// ManifestEventRepository reads the manifest event log. Events are appended by upsertManifest
+// in the transaction that publishes the manifest.
+type ManifestEventRepository struct {
+	ds DataStore
+}
+
+func NewManifestEventRepository(ds DataStore) *ManifestEventRepository {
+	return &ManifestEventRepository{
+		ds: ds,
+	}
+}
+
+func (er *ManifestEventRepository) ListManifestEvents(ctx context.Context, after int64, limit int) (_ []domain.ManifestEvent, err error) {
+	qtx, err := er.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	dbEvents, err := qtx.ListManifestEvents(ctx, db.ListManifestEventsParams{
+		ID:    after,
+		Limit: int64(limit),
+	})
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list manifest events: %w", err))
+	}
+	eventIds := make([]int64, len(dbEvents))
+	deviceIds := make([]string, 0, len(dbEvents))
+	seen := make(map[string]struct{}, len(dbEvents))
+	for i, dbEvent := range dbEvents {
+		eventIds[i] = dbEvent.ID
+		if _, ok := seen[dbEvent.DeviceID]; !ok {
+			seen[dbEvent.DeviceID] = struct{}{}
+			deviceIds = append(deviceIds, dbEvent.DeviceID)
+		}
+	}
+	dbDeployments, err := qtx.GetManifestEventDeploymentsByEventIds(ctx, eventIds)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list manifest event deployments: %w", err))
+	}
+	deployments := make(map[int64][]string, len(dbEvents))
+	for _, dbDeployment := range dbDeployments {
+		deployments[dbDeployment.EventID] = append(deployments[dbDeployment.EventID], dbDeployment.DeploymentID)
+	}
+	dbLabels, err := qtx.GetDeviceLabelsByDeviceIds(ctx, deviceIds)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve device labels: %w", err))
+	}
+	labels := make(map[string]map[string]string, len(deviceIds))
+	for _, dbLabel := range dbLabels {
+		if labels[dbLabel.DeviceID] == nil {
+			labels[dbLabel.DeviceID] = map[string]string{}
+		}
+		labels[dbLabel.DeviceID][dbLabel.Key] = dbLabel.Value
+	}
+
+	events := make([]domain.ManifestEvent, len(dbEvents))
+	for i, dbEvent := range dbEvents {
+		events[i] = domain.ManifestEvent{
+			Id:              dbEvent.ID,
+			DeviceId:        dbEvent.DeviceID,
+			ManifestVersion: uint64(dbEvent.ManifestVersion),
+			ManifestETag:    dbEvent.ManifestEtag,
+			DeploymentIds:   deployments[dbEvent.ID],
+			PublishedAt:     dbEvent.PublishedAt,
+			DeviceLabels:    labels[dbEvent.DeviceID],
+		}
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return events, nil
+}
+
+func (er *ManifestEventRepository) GetLatestManifestEventId(ctx context.Context) (_ int64, err error) {
+	qtx, err := er.ds.BeginTransaction(ctx)
+	if err != nil {
+		return 0, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	id, err := qtx.GetLatestManifestEventId(ctx)
+	if err != nil {
+		return 0, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve latest manifest event: %w", err))
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return 0, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return id, nil
+}
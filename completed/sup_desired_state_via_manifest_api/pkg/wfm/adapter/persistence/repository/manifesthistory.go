@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+	"time"
+)
+
+// ManifestHistoryRepository reads the manifest history. Versions are recorded by
+// upsertManifest and upsertGatewayManifest in the transaction that publishes them.
+type ManifestHistoryRepository struct {
+	ds DataStore
+}
+
+func NewManifestHistoryRepository(ds DataStore) *ManifestHistoryRepository {
+	return &ManifestHistoryRepository{
+		ds: ds,
+	}
+}
+
+func (hr *ManifestHistoryRepository) ListPublishedManifests(ctx context.Context, deviceId string, query domain.ManifestHistoryQuery) (_ []domain.PublishedManifest, err error) {
+	qtx, err := hr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return nil, err
+	}
+	params := db.ListManifestHistoryParams{
+		DeviceID:       deviceId,
+		BeforeVersion:  math.MaxInt64,
+		PublishedUntil: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC),
+		Limit:          int64(query.Limit),
+	}
+	if query.BeforeVersion > 0 {
+		params.BeforeVersion = int64(query.BeforeVersion)
+	}
+	if !query.At.IsZero() {
+		params.PublishedUntil = query.At
+	}
+	dbManifests, err := qtx.ListManifestHistory(ctx, params)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to list manifest history: %w", err))
+	}
+	manifests := make([]domain.PublishedManifest, len(dbManifests))
+	for i, dbManifest := range dbManifests {
+		manifests[i] = toDomainPublishedManifest(dbManifest.DeviceID, dbManifest.Version, dbManifest.BundleDigest, dbManifest.BundleSizeBytes, dbManifest.PublishedAt)
+	}
+	if err = loadPublishedDeployments(ctx, qtx, deviceId, manifests); err != nil {
+		return nil, err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return manifests, nil
+}
+
+func (hr *ManifestHistoryRepository) GetPublishedManifest(ctx context.Context, deviceId string, version uint64) (_ *domain.PublishedManifest, err error) {
+	qtx, err := hr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return nil, err
+	}
+	manifest, err := loadPublishedManifest(ctx, qtx, deviceId, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return manifest, nil
+}
+
+// GetPublishedDeployments returns the deployments of a published manifest version with their
+// descriptors and rendered component artifacts, as needed to publish them again. The
+// deployments of an aggregated manifest carry their target device.
+func (hr *ManifestHistoryRepository) GetPublishedDeployments(ctx context.Context, deviceId string, version uint64) (_ []domain.ApplicationDeployment, err error) {
+	qtx, err := hr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	if err = ensureDeviceExists(ctx, qtx, deviceId); err != nil {
+		return nil, err
+	}
+	if _, err = qtx.GetManifestHistory(ctx, db.GetManifestHistoryParams{
+		DeviceID: deviceId,
+		Version:  int64(version),
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrPublishedManifestNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest history: %w", err))
+	}
+	dbComponents, err := qtx.GetManifestHistoryComponentBlobs(ctx, db.GetManifestHistoryComponentBlobsParams{
+		DeviceID: deviceId,
+		Version:  int64(version),
+	})
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest history components: %w", err))
+	}
+	type deploymentKey struct {
+		target       string
+		deploymentId string
+	}
+	components := make(map[deploymentKey][]domain.RenderedComponent)
+	for _, dbComponent := range dbComponents {
+		key := deploymentKey{dbComponent.Target, dbComponent.DeploymentID}
+		components[key] = append(components[key], domain.RenderedComponent{
+			Name:     dbComponent.Name,
+			Artifact: dbComponent.Artifact,
+			Digest:   dbComponent.Digest,
+			Size:     uint64(len(dbComponent.Artifact)),
+		})
+	}
+	dbDeployments, err := qtx.GetManifestHistoryDeploymentBlobs(ctx, db.GetManifestHistoryDeploymentBlobsParams{
+		DeviceID: deviceId,
+		Version:  int64(version),
+	})
+	if err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest history deployments: %w", err))
+	}
+	deployments := make([]domain.ApplicationDeployment, len(dbDeployments))
+	for i, dbDeployment := range dbDeployments {
+		deployments[i] = domain.ApplicationDeployment{
+			Id:                dbDeployment.DeploymentID,
+			Descriptor:        dbDeployment.Descriptor,
+			DescriptorDigest:  dbDeployment.DescriptorDigest,
+			DescriptorSize:    uint64(len(dbDeployment.Descriptor)),
+			Components:        components[deploymentKey{dbDeployment.Target, dbDeployment.DeploymentID}],
+			FleetDeploymentId: dbDeployment.FleetDeploymentID,
+			Target:            dbDeployment.Target,
+		}
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return deployments, nil
+}
+
+func loadPublishedManifest(ctx context.Context, qtx Transaction, deviceId string, version uint64) (*domain.PublishedManifest, error) {
+	dbManifest, err := qtx.GetManifestHistory(ctx, db.GetManifestHistoryParams{
+		DeviceID: deviceId,
+		Version:  int64(version),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrPublishedManifestNotFound
+		}
+		return nil, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest history: %w", err))
+	}
+	manifests := []domain.PublishedManifest{
+		toDomainPublishedManifest(dbManifest.DeviceID, dbManifest.Version, dbManifest.BundleDigest, dbManifest.BundleSizeBytes, dbManifest.PublishedAt),
+	}
+	if err = loadPublishedDeployments(ctx, qtx, deviceId, manifests); err != nil {
+		return nil, err
+	}
+	return &manifests[0], nil
+}
+
+// loadPublishedDeployments fills in the deployments and rendered components of the
+// manifests. Descriptors and component artifacts are left out.
+func loadPublishedDeployments(ctx context.Context, qtx Transaction, deviceId string, manifests []domain.PublishedManifest) error {
+	if len(manifests) == 0 {
+		return nil
+	}
+	versions := make([]int64, len(manifests))
+	byVersion := make(map[int64]*domain.PublishedManifest, len(manifests))
+	for i := range manifests {
+		versions[i] = int64(manifests[i].Manifest.Version)
+		byVersion[versions[i]] = &manifests[i]
+	}
+	dbComponents, err := qtx.GetManifestHistoryComponents(ctx, db.GetManifestHistoryComponentsParams{
+		DeviceID: deviceId,
+		Versions: versions,
+	})
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest history components: %w", err))
+	}
+	type deploymentKey struct {
+		version      int64
+		target       string
+		deploymentId string
+	}
+	components := make(map[deploymentKey][]domain.RenderedComponent)
+	for _, dbComponent := range dbComponents {
+		key := deploymentKey{dbComponent.Version, dbComponent.Target, dbComponent.DeploymentID}
+		components[key] = append(components[key], domain.RenderedComponent{
+			Name:   dbComponent.Name,
+			Digest: dbComponent.Digest,
+			Size:   uint64(dbComponent.SizeBytes),
+		})
+	}
+	dbDeployments, err := qtx.GetManifestHistoryDeployments(ctx, db.GetManifestHistoryDeploymentsParams{
+		DeviceID: deviceId,
+		Versions: versions,
+	})
+	if err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to retrieve manifest history deployments: %w", err))
+	}
+	for _, dbDeployment := range dbDeployments {
+		manifest := byVersion[dbDeployment.Version]
+		manifest.Manifest.Deployments = append(manifest.Manifest.Deployments, domain.ApplicationDeployment{
+			Id:                dbDeployment.DeploymentID,
+			DescriptorDigest:  dbDeployment.DescriptorDigest,
+			DescriptorSize:    uint64(dbDeployment.SizeBytes),
+			Components:        components[deploymentKey{dbDeployment.Version, dbDeployment.Target, dbDeployment.DeploymentID}],
+			FleetDeploymentId: dbDeployment.FleetDeploymentID,
+			Target:            dbDeployment.Target,
+		})
+	}
+	return nil
+}
+
+// insertManifestHistory records a published manifest version.
+func insertManifestHistory(ctx context.Context, qtx Transaction, deviceId string, manifest *domain.ApplicationDeploymentManifest) error {
+	var bundleDigest sql.NullString
+	if manifest.BundleDigest != "" {
+		bundleDigest = sql.NullString{String: manifest.BundleDigest, Valid: true}
+	}
+	if err := qtx.InsertManifestHistory(ctx, db.InsertManifestHistoryParams{
+		DeviceID:     deviceId,
+		Version:      int64(manifest.Version),
+		BundleDigest: bundleDigest,
+	}); err != nil {
+		return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert manifest history: %w", err))
+	}
+	for _, deployment := range manifest.Deployments {
+		if err := qtx.InsertManifestHistoryDeployment(ctx, db.InsertManifestHistoryDeploymentParams{
+			DeviceID:          deviceId,
+			Version:           int64(manifest.Version),
+			Target:            deployment.Target,
+			DeploymentID:      deployment.Id,
+			DescriptorDigest:  deployment.DescriptorDigest,
+			FleetDeploymentID: deployment.FleetDeploymentId,
+		}); err != nil {
+			return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert manifest history deployment: %w", err))
+		}
+		for i, component := range deployment.Components {
+			if err := qtx.InsertManifestHistoryComponent(ctx, db.InsertManifestHistoryComponentParams{
+				DeviceID:     deviceId,
+				Version:      int64(manifest.Version),
+				Target:       deployment.Target,
+				DeploymentID: deployment.Id,
+				Name:         component.Name,
+				Position:     int64(i),
+				Digest:       component.Digest,
+			}); err != nil {
+				return errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to insert manifest history component: %w", err))
+			}
+		}
+	}
+	return nil
+}
+
+func toDomainPublishedManifest(deviceId string, version int64, bundleDigest sql.NullString, bundleSize int64, publishedAt time.Time) domain.PublishedManifest {
+	return domain.PublishedManifest{
+		DeviceId: deviceId,
+		Manifest: domain.ApplicationDeploymentManifest{
+			Version:      uint64(version),
+			BundleDigest: bundleDigest.String,
+			BundleSize:   uint64(bundleSize),
+		},
+		PublishedAt: publishedAt,
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type OnboardingRepository struct {
+	ds DataStore
+}
+
+func NewOnboardingRepository(ds DataStore) *OnboardingRepository {
+	return &OnboardingRepository{
+		ds: ds,
+	}
+}
+
+func (obr *OnboardingRepository) OnboardDevice(ctx context.Context, certificate domain.DeviceCertificate, device domain.Device) (_ string, _ bool, err error) {
+	qtx, err := obr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return "", false, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	// a known certificate keeps its device; onboarding is idempotent
+	existing, err := qtx.GetDeviceCertificateByFingerprint(ctx, certificate.Fingerprint)
+	if err == nil {
+		if err = qtx.Commit(); err != nil {
+			return "", false, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+		}
+		return existing.DeviceID, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", false, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device certificate: %w", err))
+	}
+
+	if err = qtx.CreateDevice(ctx, db.CreateDeviceParams{
+		ID:          device.Id,
+		DisplayName: device.DisplayName,
+	}); err != nil {
+		return "", false, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to create device: %w", err))
+	}
+	if err = qtx.InsertDeviceCertificate(ctx, db.InsertDeviceCertificateParams{
+		Fingerprint: certificate.Fingerprint,
+		DeviceID:    device.Id,
+		Certificate: certificate.Certificate,
+		Subject:     certificate.Subject,
+		NotAfter:    certificate.NotAfter,
+	}); err != nil {
+		return "", false, errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to persist device certificate: %w", err))
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return "", false, errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return device.Id, true, nil
+}
+
+func (obr *OnboardingRepository) GetDeviceIdByCertificateFingerprint(ctx context.Context, fingerprint string) (_ string, err error) {
+	qtx, err := obr.ds.BeginTransaction(ctx)
+	if err != nil {
+		return "", errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to start transaction: %w", err))
+	}
+	defer func() {
+		if err != nil {
+			_ = qtx.Rollback()
+		}
+	}()
+
+	certificate, err := qtx.GetDeviceCertificateByFingerprint(ctx, fingerprint)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrDeviceNotFound
+		}
+		return "", errors.Join(domain.ErrInternal, fmt.Errorf("db: failed to lookup device certificate: %w", err))
+	}
+
+	if err = qtx.Commit(); err != nil {
+		return "", errors.Join(domain.ErrInternal, fmt.Errorf("db: commit failed: %w", err))
+	}
+	return certificate.DeviceID, nil
+}
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	_ "embed"
 	"fmt"
+	"skeleton/pkg/wfm/adapter/persistence/repository"
 	"skeleton/pkg/wfm/adapter/persistence/sqlitedb/db"
+	"time"
 
 	"modernc.org/sqlite"
 )
@@ -20,8 +22,34 @@ var dbSchema string
 
 const configureConnectionSQL = `
 	PRAGMA foreign_keys = ON; -- enable foreign key support
+	PRAGMA busy_timeout = 5000; -- wait for concurrent writers (e.g. status reports) instead of failing with SQLITE_BUSY
 `
 
+// seedSQL registers the PoC device in a new database. Existing databases are not seeded, so
+// that the device stays decommissioned once removed.
+const seedSQL = `
+	INSERT INTO devices (id) VALUES ('c92cb339-c99c-4eca-9dd4-f8484dd16cfb');
+`
+
+// columnMigrations add columns introduced after a table was first released. The schema
+// only uses CREATE TABLE IF NOT EXISTS, which leaves tables of existing databases untouched.
+var columnMigrations = []struct {
+	Table      string
+	Column     string
+	Definition string
+	Backfill   string
+}{
+	{"deployment_blobs", "size_bytes", "INTEGER DEFAULT 0 NOT NULL", "UPDATE deployment_blobs SET size_bytes = length(descriptor)"},
+	{"bundle_blobs", "size_bytes", "INTEGER DEFAULT 0 NOT NULL", "UPDATE bundle_blobs SET size_bytes = length(archive)"},
+	{"devices", "display_name", "TEXT DEFAULT '' NOT NULL", ""},
+	// SQLite cannot add columns with a non-constant default, hence the backfill
+	{"devices", "created_at", "TIMESTAMP", "UPDATE devices SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL"},
+	{"devices", "updated_at", "TIMESTAMP", "UPDATE devices SET updated_at = CURRENT_TIMESTAMP WHERE updated_at IS NULL"},
+	{"application_deployments", "fleet_deployment_id", "TEXT DEFAULT '' NOT NULL", ""},
+	{"deployment_blobs", "unreferenced_since", "TIMESTAMP", ""},
+	{"bundle_blobs", "unreferenced_since", "TIMESTAMP", ""},
+}
+
 func New(ctx context.Context, dbPath string) (*DataStore, error) {
 	// register a hook to configure database connections (e.g. enable foreign key support)
 	sqlite.RegisterConnectionHook(func(conn sqlite.ExecQuerierContext, _ string) error {
@@ -46,16 +74,114 @@ func New(ctx context.Context, dbPath string) (*DataStore, error) {
 
 func (ds *DataStore) Migrate(ctx context.Context) error {
 	// Run database migrations
-	if _, err := ds.database.ExecContext(ctx, dbSchema); err != nil {
+	var exists bool
+	if err := ds.database.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'devices'",
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+	schema := dbSchema
+	if !exists {
+		schema += seedSQL
+	}
+	if _, err := ds.database.ExecContext(ctx, schema); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
+	for _, m := range columnMigrations {
+		var exists bool
+		if err := ds.database.QueryRowContext(ctx,
+			"SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?", m.Table, m.Column,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", m.Table, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := ds.database.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.Table, m.Column, m.Definition)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.Table, m.Column, err)
+		}
+		if m.Backfill != "" {
+			if _, err := ds.database.ExecContext(ctx, m.Backfill); err != nil {
+				return fmt.Errorf("failed to backfill column %s.%s: %w", m.Table, m.Column, err)
+			}
+		}
+	}
 	return nil
 }
 
-func (ds *DataStore) BeginTransaction(ctx context.Context) (*sql.Tx, error) {
-	return ds.database.BeginTx(ctx, nil)
+func (ds *DataStore) BeginTransaction(ctx context.Context) (repository.Transaction, error) {
+	tx, err := ds.database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{
+		Queries: ds.Queries.WithTx(tx),
+		tx:      tx,
+	}, nil
 }
 
 func (ds *DataStore) Close() error {
 	return ds.database.Close()
 }
+
+// transaction runs the queries within a database transaction. SQLite takes a database lock
+// for the first write of a transaction and fails concurrent writers with SQLITE_BUSY rather
+// than letting them overwrite each other, so the explicit locks are not needed.
+type transaction struct {
+	*db.Queries
+	tx *sql.Tx
+}
+
+func (t *transaction) LockDevice(ctx context.Context, deviceId string) error {
+	_, err := t.GetDeviceId(ctx, deviceId)
+	return err
+}
+
+func (t *transaction) LockManifestEvents(context.Context) error {
+	return nil
+}
+
+func (t *transaction) TryLockBlobCollection(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (t *transaction) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *transaction) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// Timestamps are stored in UTC and compared as text, hence the queries filtering by time
+// compare against UTC.
+
+func (t *transaction) ListManifestHistory(ctx context.Context, arg db.ListManifestHistoryParams) ([]db.ListManifestHistoryRow, error) {
+	arg.PublishedUntil = arg.PublishedUntil.UTC()
+	return t.Queries.ListManifestHistory(ctx, arg)
+}
+
+func (t *transaction) PruneManifestHistory(ctx context.Context, supersededBefore time.Time) (int64, error) {
+	return t.Queries.PruneManifestHistory(ctx, supersededBefore.UTC())
+}
+
+func (t *transaction) GetCollectableDeploymentBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (db.GetCollectableDeploymentBlobStatsRow, error) {
+	return t.Queries.GetCollectableDeploymentBlobStats(ctx, utc(unreferencedBefore))
+}
+
+func (t *transaction) DeleteCollectableDeploymentBlobs(ctx context.Context, unreferencedBefore sql.NullTime) (int64, error) {
+	return t.Queries.DeleteCollectableDeploymentBlobs(ctx, utc(unreferencedBefore))
+}
+
+func (t *transaction) GetCollectableBundleBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (db.GetCollectableBundleBlobStatsRow, error) {
+	return t.Queries.GetCollectableBundleBlobStats(ctx, utc(unreferencedBefore))
+}
+
+func (t *transaction) DeleteCollectableBundleBlobs(ctx context.Context, unreferencedBefore sql.NullTime) (int64, error) {
+	return t.Queries.DeleteCollectableBundleBlobs(ctx, utc(unreferencedBefore))
+}
+
+func utc(t sql.NullTime) sql.NullTime {
+	t.Time = t.Time.UTC()
+	return t
+}
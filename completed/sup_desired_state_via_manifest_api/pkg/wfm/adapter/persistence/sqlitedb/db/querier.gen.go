@@ -0,0 +1,106 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Querier interface {
+	CreateDevice(ctx context.Context, arg CreateDeviceParams) error
+	CreateFleetDeployment(ctx context.Context, arg CreateFleetDeploymentParams) error
+	DeleteCollectableBundleBlobs(ctx context.Context, unreferencedBefore sql.NullTime) (int64, error)
+	DeleteCollectableDeploymentBlobs(ctx context.Context, unreferencedBefore sql.NullTime) (int64, error)
+	DeleteDecommissionedDevice(ctx context.Context, id string) error
+	DeleteDeployment(ctx context.Context, arg DeleteDeploymentParams) error
+	DeleteDeploymentComponents(ctx context.Context, arg DeleteDeploymentComponentsParams) error
+	DeleteDeploymentsByDeviceId(ctx context.Context, deviceID string) error
+	DeleteDevice(ctx context.Context, id string) error
+	DeleteDeviceCapabilityRoles(ctx context.Context, deviceID string) error
+	DeleteDeviceGateway(ctx context.Context, deviceID string) error
+	DeleteDeviceLabels(ctx context.Context, deviceID string) error
+	DeleteFleetDeployment(ctx context.Context, id string) error
+	DeleteFleetDeploymentSelectorLabels(ctx context.Context, fleetDeploymentID string) error
+	DeleteManifestByDeviceId(ctx context.Context, deviceID string) error
+	GetCollectableBundleBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (GetCollectableBundleBlobStatsRow, error)
+	GetCollectableDeploymentBlobStats(ctx context.Context, unreferencedBefore sql.NullTime) (GetCollectableDeploymentBlobStatsRow, error)
+	GetDecommissionedManifestVersion(ctx context.Context, id string) (int64, error)
+	GetDeploymentByIdAndDigest(ctx context.Context, arg GetDeploymentByIdAndDigestParams) (GetDeploymentByIdAndDigestRow, error)
+	GetDeploymentComponentStatusesByStatusIds(ctx context.Context, statusIds []int64) ([]DeploymentComponentStatus, error)
+	GetDeploymentComponentsByDeviceId(ctx context.Context, deviceID string) ([]GetDeploymentComponentsByDeviceIdRow, error)
+	GetDeploymentsByDeviceId(ctx context.Context, deviceID string) ([]GetDeploymentsByDeviceIdRow, error)
+	GetDevice(ctx context.Context, id string) (Device, error)
+	GetDeviceBundleBlob(ctx context.Context, arg GetDeviceBundleBlobParams) (GetDeviceBundleBlobRow, error)
+	GetDeviceCapabilities(ctx context.Context, deviceID string) (DeviceCapability, error)
+	GetDeviceCapabilityRoles(ctx context.Context, deviceID string) ([]string, error)
+	GetDeviceCertificateByFingerprint(ctx context.Context, fingerprint string) (DeviceCertificate, error)
+	GetDeviceComponentBlob(ctx context.Context, arg GetDeviceComponentBlobParams) (GetDeviceComponentBlobRow, error)
+	GetDeviceDeploymentBlob(ctx context.Context, arg GetDeviceDeploymentBlobParams) (GetDeviceDeploymentBlobRow, error)
+	GetDeviceGateway(ctx context.Context, deviceID string) (string, error)
+	GetDeviceGatewaysByDeviceIds(ctx context.Context, deviceIds []string) ([]DeviceGateway, error)
+	GetDeviceId(ctx context.Context, id string) (string, error)
+	GetDeviceLabels(ctx context.Context, deviceID string) ([]DeviceLabel, error)
+	GetDeviceLabelsByDeviceIds(ctx context.Context, deviceIds []string) ([]DeviceLabel, error)
+	GetDevicesByIds(ctx context.Context, deviceIds []string) ([]Device, error)
+	GetFleetDeployment(ctx context.Context, id string) (GetFleetDeploymentRow, error)
+	GetFleetDeploymentDevices(ctx context.Context, fleetDeploymentID string) ([]string, error)
+	GetFleetDeploymentSelectorLabels(ctx context.Context, fleetDeploymentID string) ([]FleetDeploymentSelectorLabel, error)
+	GetGatewayManifest(ctx context.Context, gatewayID string) (GetGatewayManifestRow, error)
+	GetLatestManifestEventId(ctx context.Context) (int64, error)
+	GetManifestByDeviceId(ctx context.Context, deviceID string) (GetManifestByDeviceIdRow, error)
+	GetManifestEventDeploymentsByEventIds(ctx context.Context, eventIds []int64) ([]ManifestEventDeployment, error)
+	GetManifestHistory(ctx context.Context, arg GetManifestHistoryParams) (GetManifestHistoryRow, error)
+	GetManifestHistoryComponentBlobs(ctx context.Context, arg GetManifestHistoryComponentBlobsParams) ([]GetManifestHistoryComponentBlobsRow, error)
+	GetManifestHistoryComponents(ctx context.Context, arg GetManifestHistoryComponentsParams) ([]GetManifestHistoryComponentsRow, error)
+	GetManifestHistoryDeploymentBlobs(ctx context.Context, arg GetManifestHistoryDeploymentBlobsParams) ([]GetManifestHistoryDeploymentBlobsRow, error)
+	GetManifestHistoryDeployments(ctx context.Context, arg GetManifestHistoryDeploymentsParams) ([]GetManifestHistoryDeploymentsRow, error)
+	InsertBundleBlob(ctx context.Context, arg InsertBundleBlobParams) error
+	InsertDeploymentBlob(ctx context.Context, arg InsertDeploymentBlobParams) error
+	InsertDeploymentComponent(ctx context.Context, arg InsertDeploymentComponentParams) error
+	InsertDeploymentComponentStatus(ctx context.Context, arg InsertDeploymentComponentStatusParams) error
+	InsertDeploymentStatus(ctx context.Context, arg InsertDeploymentStatusParams) (int64, error)
+	InsertDeviceBundleBlob(ctx context.Context, arg InsertDeviceBundleBlobParams) error
+	InsertDeviceCapabilityRole(ctx context.Context, arg InsertDeviceCapabilityRoleParams) error
+	InsertDeviceCertificate(ctx context.Context, arg InsertDeviceCertificateParams) error
+	InsertDeviceComponentBlob(ctx context.Context, arg InsertDeviceComponentBlobParams) error
+	InsertDeviceDeploymentBlob(ctx context.Context, arg InsertDeviceDeploymentBlobParams) error
+	InsertDeviceLabel(ctx context.Context, arg InsertDeviceLabelParams) error
+	InsertFleetDeploymentSelectorLabel(ctx context.Context, arg InsertFleetDeploymentSelectorLabelParams) error
+	InsertManifestEvent(ctx context.Context, arg InsertManifestEventParams) (int64, error)
+	InsertManifestEventDeployment(ctx context.Context, arg InsertManifestEventDeploymentParams) error
+	InsertManifestHistory(ctx context.Context, arg InsertManifestHistoryParams) error
+	InsertManifestHistoryComponent(ctx context.Context, arg InsertManifestHistoryComponentParams) error
+	InsertManifestHistoryDeployment(ctx context.Context, arg InsertManifestHistoryDeploymentParams) error
+	ListAllDevices(ctx context.Context) ([]Device, error)
+	ListDeploymentStatuses(ctx context.Context, arg ListDeploymentStatusesParams) ([]DeploymentStatus, error)
+	ListDeviceIdsByLabel(ctx context.Context, arg ListDeviceIdsByLabelParams) ([]string, error)
+	ListDevices(ctx context.Context, arg ListDevicesParams) ([]Device, error)
+	ListFleetDeploymentDevices(ctx context.Context) ([]ListFleetDeploymentDevicesRow, error)
+	ListFleetDeploymentSelectorLabels(ctx context.Context) ([]FleetDeploymentSelectorLabel, error)
+	ListFleetDeployments(ctx context.Context) ([]ListFleetDeploymentsRow, error)
+	ListGatewayChildCapabilities(ctx context.Context, gatewayID string) ([]DeviceCapability, error)
+	ListGatewayChildren(ctx context.Context, gatewayID string) ([]string, error)
+	ListManifestEvents(ctx context.Context, arg ListManifestEventsParams) ([]ManifestEvent, error)
+	ListManifestHistory(ctx context.Context, arg ListManifestHistoryParams) ([]ListManifestHistoryRow, error)
+	MarkUnreferencedBundleBlobs(ctx context.Context) (int64, error)
+	MarkUnreferencedDeploymentBlobs(ctx context.Context) (int64, error)
+	// Deletes the history entries superseded by a version published before the cutoff. The
+	// current version of a device is never superseded and hence kept.
+	PruneManifestHistory(ctx context.Context, supersededBefore time.Time) (int64, error)
+	UnmarkReferencedBundleBlobs(ctx context.Context) (int64, error)
+	UnmarkReferencedDeploymentBlobs(ctx context.Context) (int64, error)
+	UpdateDevice(ctx context.Context, arg UpdateDeviceParams) error
+	UpdateFleetDeployment(ctx context.Context, arg UpdateFleetDeploymentParams) error
+	UpsertDecommissionedDevice(ctx context.Context, arg UpsertDecommissionedDeviceParams) error
+	UpsertDeployment(ctx context.Context, arg UpsertDeploymentParams) error
+	UpsertDeviceCapabilities(ctx context.Context, arg UpsertDeviceCapabilitiesParams) error
+	UpsertDeviceGateway(ctx context.Context, arg UpsertDeviceGatewayParams) error
+	UpsertGatewayManifest(ctx context.Context, arg UpsertGatewayManifestParams) error
+	UpsertManifest(ctx context.Context, arg UpsertManifestParams) error
+}
+
+var _ Querier = (*Queries)(nil)
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceAuthenticator binds requests to device-scoped routes to the verified TLS client
+// certificate. A certificate identifies the device it was onboarded for as well as the
+// device IDs named by its subject CN and DNS SANs.
+type DeviceAuthenticator struct {
+	svc port.OnboardingService
+}
+
+func NewDeviceAuthenticator(svc port.OnboardingService) *DeviceAuthenticator {
+	return &DeviceAuthenticator{
+		svc,
+	}
+}
+
+// RequireDevice wraps next so that it only serves requests whose pathValue names a device
+// the client certificate identifies.
+func (a *DeviceAuthenticator) RequireDevice(pathValue string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deviceId := r.PathValue(pathValue)
+
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			logrus.WithFields(logrus.Fields{
+				"deviceId":   deviceId,
+				"remoteAddr": r.RemoteAddr,
+			}).Warn("Device request without verified client certificate")
+			http.Error(w, "Client certificate required", http.StatusUnauthorized)
+			return
+		}
+		cert := r.TLS.VerifiedChains[0][0]
+
+		deviceIds, err := a.deviceIds(r.Context(), cert)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"deviceId": deviceId,
+				"subject":  cert.Subject.String(),
+				"error":    err,
+			}).Error("Failed to resolve client certificate identity")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		for _, id := range deviceIds {
+			if id == deviceId {
+				next(w, r)
+				return
+			}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"deviceId":  deviceId,
+			"subject":   cert.Subject.String(),
+			"deviceIds": deviceIds,
+		}).Warn("Client certificate does not identify the requested device")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}
+}
+
+func (a *DeviceAuthenticator) deviceIds(ctx context.Context, cert *x509.Certificate) ([]string, error) {
+	var ids []string
+	onboarded, err := a.svc.GetDeviceIdByCertificate(ctx, cert.Raw)
+	switch {
+	case err == nil:
+		ids = append(ids, onboarded)
+	case !errors.Is(err, domain.ErrDeviceNotFound):
+		return nil, err
+	}
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	return append(ids, cert.DNSNames...), nil
+}
+
+// OperatorAuthenticator restricts the operator routes to clients whose TLS certificate
+// chains to the operator CAs. Device certificates, which chain to the client CAs, are
+// not accepted, so that devices cannot manage the fleet.
+type OperatorAuthenticator struct {
+	roots *x509.CertPool
+}
+
+func NewOperatorAuthenticator(roots *x509.CertPool) *OperatorAuthenticator {
+	return &OperatorAuthenticator{
+		roots,
+	}
+}
+
+// RequireOperator wraps next so that it only serves requests with an operator certificate.
+func (a *OperatorAuthenticator) RequireOperator(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			logrus.WithFields(logrus.Fields{
+				"path":       r.URL.Path,
+				"remoteAddr": r.RemoteAddr,
+			}).Warn("Operator request without verified client certificate")
+			http.Error(w, "Client certificate required", http.StatusUnauthorized)
+			return
+		}
+		cert := r.TLS.PeerCertificates[0]
+
+		// Without operator CAs Verify would fall back to the system roots
+		if a.roots == nil {
+			logrus.WithField("path", r.URL.Path).Warn("Operator request while no operator CA is configured")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		intermediates := x509.NewCertPool()
+		for _, intermediate := range r.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(intermediate)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:         a.roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":    r.URL.Path,
+				"subject": cert.Subject.String(),
+				"error":   err,
+			}).Warn("Client certificate is not an operator certificate")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+
+	"github.com/sirupsen/logrus"
+)
+
+type CapabilitiesHandler struct {
+	svc port.CapabilitiesService
+}
+
+func NewCapabilitiesHandler(svc port.CapabilitiesService) *CapabilitiesHandler {
+	return &CapabilitiesHandler{
+		svc,
+	}
+}
+
+func (s *CapabilitiesHandler) ReportCapabilities(w http.ResponseWriter, r *http.Request) {
+	clientId := r.PathValue("clientId")
+
+	var request common.DeviceCapabilities
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"clientId": clientId,
+			"error":    err,
+		}).Warn("Failed to decode device capabilities")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if request.Kind != common.DeviceCapabilitiesKind || request.Properties.Id != clientId {
+		logrus.WithFields(logrus.Fields{
+			"clientId":   clientId,
+			"kind":       request.Kind,
+			"propertyId": request.Properties.Id,
+		}).Warn("Device capabilities kind or id mismatch")
+		http.Error(w, "Invalid device capabilities", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.svc.ReportCapabilities(r.Context(), domain.DeviceCapabilities{
+		DeviceId:     clientId,
+		ApiVersion:   request.ApiVersion,
+		Vendor:       request.Properties.Vendor,
+		ModelNumber:  request.Properties.ModelNumber,
+		SerialNumber: request.Properties.SerialNumber,
+		Roles:        request.Properties.Roles,
+		CpuCores:     request.Properties.Resources.Cpu.Cores,
+		Memory:       request.Properties.Resources.Memory,
+		Storage:      request.Properties.Resources.Storage,
+	}); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidCapabilities):
+			logrus.WithFields(logrus.Fields{
+				"clientId": clientId,
+				"error":    err,
+			}).Warn("Invalid device capabilities")
+			http.Error(w, "Invalid device capabilities", http.StatusBadRequest)
+			return
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{
+				"clientId": clientId,
+				"error":    err,
+			}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"clientId": clientId,
+				"error":    err,
+			}).Error("Failed to store device capabilities")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GetCapabilities returns the last reported capabilities in the format devices report
+// them. Last-Modified carries the time of that report.
+func (s *CapabilitiesHandler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+
+	capabilities, err := s.svc.GetCapabilities(r.Context(), deviceId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrCapabilitiesNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device capabilities not reported")
+			http.Error(w, "Device capabilities not reported", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Error("Failed to retrieve device capabilities")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Last-Modified", capabilities.ReportedAt.UTC().Format(http.TimeFormat))
+	writeJSON(w, http.StatusOK, common.DeviceCapabilities{
+		ApiVersion: capabilities.ApiVersion,
+		Kind:       common.DeviceCapabilitiesKind,
+		Properties: common.DeviceCapabilitiesProperties{
+			Id:           capabilities.DeviceId,
+			Vendor:       capabilities.Vendor,
+			ModelNumber:  capabilities.ModelNumber,
+			SerialNumber: capabilities.SerialNumber,
+			Roles:        capabilities.Roles,
+			Resources: common.DeviceResources{
+				Cpu:     common.DeviceCpu{Cores: capabilities.CpuCores},
+				Memory:  capabilities.Memory,
+				Storage: capabilities.Storage,
+			},
+		},
+	})
+}
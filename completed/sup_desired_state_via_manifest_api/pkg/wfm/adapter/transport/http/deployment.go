@@ -1,26 +1,39 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"skeleton/pkg/common"
 	"skeleton/pkg/wfm/core/domain"
 	"skeleton/pkg/wfm/core/port"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// manifestWriteTimeout is the time left to write the manifest once a watch ends.
+const manifestWriteTimeout = 30 * time.Second
+
 type DeploymentHandler struct {
-	svc port.DeploymentService
+	svc    port.DeploymentService
+	signer *common.JWSSigner // nil disables the signed manifest representation
+	// maxManifestWait caps how long conditional manifest requests are held open; zero
+	// disables watch mode
+	maxManifestWait time.Duration
 }
 
-func NewDeploymentHandler(svc port.DeploymentService) *DeploymentHandler {
+func NewDeploymentHandler(svc port.DeploymentService, signer *common.JWSSigner, maxManifestWait time.Duration) *DeploymentHandler {
 	return &DeploymentHandler{
 		svc,
+		signer,
+		maxManifestWait,
 	}
 }
 
@@ -52,7 +65,7 @@ func (s *DeploymentHandler) CreateDeployment(w http.ResponseWriter, r *http.Requ
 				"deviceId": deviceId,
 				"error":    err,
 			}).Warn("Invalid deployment descriptor")
-			http.Error(w, "Invalid deployment descriptor", http.StatusBadRequest)
+			writeInvalidDescriptor(w, err)
 			return
 		default:
 			logrus.WithFields(logrus.Fields{
@@ -105,13 +118,21 @@ func (s *DeploymentHandler) UpdateDeployment(w http.ResponseWriter, r *http.Requ
 			}).Warn("Deployment not found")
 			http.Error(w, "Deployment not found", http.StatusNotFound)
 			return
+		case errors.Is(err, domain.ErrFleetManagedDeployment):
+			logrus.WithFields(logrus.Fields{
+				"deviceId":     deviceId,
+				"deploymentId": deploymentId,
+				"error":        err,
+			}).Warn("Deployment is managed by a fleet deployment")
+			http.Error(w, "Deployment is managed by a fleet deployment", http.StatusConflict)
+			return
 		case errors.Is(err, domain.ErrInvalidDeploymentDescriptor):
 			logrus.WithFields(logrus.Fields{
 				"deviceId":     deviceId,
 				"deploymentId": deploymentId,
 				"error":        err,
 			}).Warn("Invalid deployment descriptor")
-			http.Error(w, "Invalid deployment descriptor", http.StatusBadRequest)
+			writeInvalidDescriptor(w, err)
 			return
 		default:
 			logrus.WithFields(logrus.Fields{
@@ -153,6 +174,14 @@ func (s *DeploymentHandler) DeleteDeployment(w http.ResponseWriter, r *http.Requ
 			}).Warn("Deployment not found")
 			http.Error(w, "Deployment not found", http.StatusNotFound)
 			return
+		case errors.Is(err, domain.ErrFleetManagedDeployment):
+			logrus.WithFields(logrus.Fields{
+				"deviceId":     deviceId,
+				"deploymentId": deploymentId,
+				"error":        err,
+			}).Warn("Deployment is managed by a fleet deployment")
+			http.Error(w, "Deployment is managed by a fleet deployment", http.StatusConflict)
+			return
 		default:
 			logrus.WithFields(logrus.Fields{
 				"deviceId":     deviceId,
@@ -170,8 +199,18 @@ func (s *DeploymentHandler) DeleteDeployment(w http.ResponseWriter, r *http.Requ
 func (s *DeploymentHandler) GetDeploymentManifest(w http.ResponseWriter, r *http.Request) {
 	deviceId := r.PathValue("deviceId")
 
-	// Check Accept header for supported media types
-	if !validateManifestAcceptHeader(r) {
+	// The representation depends on the Accept header, so caches must key on it
+	w.Header().Set("Vary", "Accept")
+
+	// Select the best representation for the Accept header. The unsigned manifest is
+	// listed first so it is served when the client has no preference (SUP default);
+	// clients ask for the signed manifest by giving it a higher q-value.
+	offers := []string{common.ManifestMediaType}
+	if s.signer != nil {
+		offers = append(offers, common.SignedManifestMediaType)
+	}
+	mediaType, ok := negotiateContentType(r, offers)
+	if !ok {
 		logrus.WithFields(logrus.Fields{
 			"deviceId": deviceId,
 			"accept":   r.Header.Get("Accept"),
@@ -180,30 +219,132 @@ func (s *DeploymentHandler) GetDeploymentManifest(w http.ResponseWriter, r *http
 		return
 	}
 
-	manifest, err := s.svc.GetDeploymentManifest(r.Context(), deviceId)
+	wait, err := s.manifestWait(r)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrManifestNotFound):
-			manifest = &domain.ApplicationDeploymentManifest{
-				Version: 1, // empty state manifest
-			}
-		case errors.Is(err, domain.ErrDeviceNotFound):
-			logrus.WithFields(logrus.Fields{
-				"deviceId": deviceId,
-				"error":    err,
-			}).Warn("Device not found")
-			http.Error(w, "Device not found", http.StatusNotFound)
-			return
-		default:
+		logrus.WithFields(logrus.Fields{
+			"deviceId": deviceId,
+			"wait":     r.URL.Query().Get("wait"),
+		}).Warn("Invalid manifest wait")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.maxManifestWait > 0 {
+		// Advertise watch mode, so that clients switch from polling to waiting
+		w.Header().Set(common.ManifestMaxWaitHeader, strconv.Itoa(int(s.maxManifestWait/time.Second)))
+	}
+
+	// Subscribe before reading the manifest, so that a change committed in between wakes
+	// the request up instead of being missed
+	var changed <-chan struct{}
+	if wait > 0 {
+		changed = s.svc.ManifestChanged(deviceId)
+	}
+	body, err := s.manifestBody(r.Context(), deviceId, mediaType)
+	if err != nil {
+		writeManifestError(w, deviceId, err)
+		return
+	}
+	// The ETag is the digest of the exact response body, hence it differs per representation
+	manifestETag := fmt.Sprintf("\"%s\"", common.CalculateDigest(body))
+
+	// Watch mode: hold the request open while the client has the current manifest, until
+	// it changes, the wait expires or the server shuts down. If-None-Match: * matches every
+	// manifest, so it never waits and is answered by the conditional check below
+	if wait > 0 && clientHasExactETag(r.Header, manifestETag) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + manifestWriteTimeout)); err != nil {
 			logrus.WithFields(logrus.Fields{
 				"deviceId": deviceId,
 				"error":    err,
-			}).Error("Failed to retrieve deployment manifest")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+			}).Debug("Failed to extend write deadline; the manifest wait may be cut short")
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+	watch:
+		for clientHasExactETag(r.Header, manifestETag) {
+			select {
+			case <-changed:
+			case <-timer.C:
+				break watch
+			case <-r.Context().Done():
+				break watch
+			}
+			// Changes may not alter this representation (e.g. a deployment re-created
+			// with the same descriptor), so compare the ETag again before responding
+			changed = s.svc.ManifestChanged(deviceId)
+			if body, err = s.manifestBody(r.Context(), deviceId, mediaType); err != nil {
+				writeManifestError(w, deviceId, err)
+				return
+			}
+			manifestETag = fmt.Sprintf("\"%s\"", common.CalculateDigest(body))
+		}
+	}
+
+	// Conditional request check against manifest ETag
+	if clientHasETag(r.Header, manifestETag) {
+		w.Header().Set("ETag", manifestETag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", manifestETag)
+	w.Header().Set("Content-Type", mediaType)
+	w.Write(body)
+}
+
+// manifestWait returns how long a conditional manifest request may be held open, from the
+// wait query parameter in seconds capped at the configured maximum. It is zero when the
+// parameter is absent or watch mode is disabled.
+func (s *DeploymentHandler) manifestWait(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("wait")
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid wait %q: expected a non-negative number of seconds", value)
+	}
+	return min(time.Duration(seconds)*time.Second, s.maxManifestWait), nil
+}
+
+// manifestBody builds the manifest response body of the device in the given representation.
+func (s *DeploymentHandler) manifestBody(ctx context.Context, deviceId, mediaType string) ([]byte, error) {
+	manifest, err := s.svc.GetDeploymentManifest(ctx, deviceId)
+	if err != nil {
+		if !errors.Is(err, domain.ErrManifestNotFound) {
+			return nil, err
 		}
+		manifest = &domain.ApplicationDeploymentManifest{
+			Version: 1, // empty state manifest
+		}
+	}
+
+	jsonData, err := json.Marshal(manifestResponse(deviceId, manifest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal deployment manifest response: %w", err)
 	}
+	if mediaType != common.SignedManifestMediaType {
+		return jsonData, nil
+	}
+	// The unsigned manifest becomes the JWS payload. Signatures are deterministic,
+	// so the signed body (and thus its ETag) is stable for an unchanged manifest.
+	signed, err := s.signer.Sign(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign deployment manifest: %w", err)
+	}
+	return signed, nil
+}
 
+// ManifestETag returns the ETag of the unsigned manifest representation. It implements
+// port.ManifestETagFunc for the manifest event log.
+func ManifestETag(deviceId string, manifest *domain.ApplicationDeploymentManifest) (string, error) {
+	jsonData, err := json.Marshal(manifestResponse(deviceId, manifest))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal deployment manifest response: %w", err)
+	}
+	return fmt.Sprintf("\"%s\"", common.CalculateDigest(jsonData)), nil
+}
+
+func manifestResponse(deviceId string, manifest *domain.ApplicationDeploymentManifest) common.GetDeploymentManifestResponse {
 	response := common.GetDeploymentManifestResponse{
 		ManifestVersion: manifest.Version,
 		Bundle:          nil,
@@ -213,6 +354,7 @@ func (s *DeploymentHandler) GetDeploymentManifest(w http.ResponseWriter, r *http
 		response.Bundle = &common.BundleDTO{
 			MediaType: "application/vnd.margo.bundle.v1+tar+gzip",
 			Digest:    manifest.BundleDigest,
+			SizeBytes: manifest.BundleSize,
 			URL:       fmt.Sprintf("/api/v1/devices/%s/bundles/%s", deviceId, manifest.BundleDigest),
 		}
 	}
@@ -220,32 +362,37 @@ func (s *DeploymentHandler) GetDeploymentManifest(w http.ResponseWriter, r *http
 		response.Deployments[i] = common.DeploymentDTO{
 			DeploymentId: dep.Id,
 			Digest:       dep.DescriptorDigest,
+			SizeBytes:    dep.DescriptorSize,
 			URL:          fmt.Sprintf("/api/v1/devices/%s/deployments/%s/%s", deviceId, dep.Id, dep.DescriptorDigest),
+			Target:       dep.Target,
+		}
+		for _, component := range dep.Components {
+			response.Deployments[i].Components = append(response.Deployments[i].Components, common.ComponentDTO{
+				Name:      component.Name,
+				Digest:    component.Digest,
+				SizeBytes: component.Size,
+				URL:       fmt.Sprintf("/api/v1/devices/%s/deployments/%s/components/%s/%s", deviceId, dep.Id, url.PathEscape(component.Name), component.Digest),
+			})
 		}
 	}
+	return response
+}
 
-	jsonData, err := json.Marshal(response)
-	if err != nil {
+func writeManifestError(w http.ResponseWriter, deviceId string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDeviceNotFound):
+		logrus.WithFields(logrus.Fields{
+			"deviceId": deviceId,
+			"error":    err,
+		}).Warn("Device not found")
+		http.Error(w, "Device not found", http.StatusNotFound)
+	default:
 		logrus.WithFields(logrus.Fields{
 			"deviceId": deviceId,
 			"error":    err,
-		}).Error("Failed to marshal deployment manifest response")
+		}).Error("Failed to retrieve deployment manifest")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	manifestETag := fmt.Sprintf("\"%s\"", common.CalculateDigest(jsonData))
-
-	// Conditional request check against manifest ETag
-	if clientHasETag(r.Header, manifestETag) {
-		w.Header().Set("ETag", manifestETag)
-		w.WriteHeader(http.StatusNotModified)
-		return
 	}
-
-	w.Header().Set("ETag", manifestETag)
-	w.Header().Set("Content-Type", "application/vnd.margo.manifest.v1+json")
-	w.Write(jsonData)
 }
 
 func (s *DeploymentHandler) GetDeployment(w http.ResponseWriter, r *http.Request) {
@@ -321,7 +468,54 @@ func (s *DeploymentHandler) GetBundle(w http.ResponseWriter, r *http.Request) {
 	w.Write(archive)
 }
 
+func (s *DeploymentHandler) GetRenderedComponent(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+	deploymentId := r.PathValue("deploymentId")
+	name := r.PathValue("component")
+	digest := r.PathValue("digest")
+
+	component, err := s.svc.GetRenderedComponent(r.Context(), deviceId, deploymentId, name, digest)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrRenderedComponentNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "deploymentId": deploymentId, "component": name, "digest": digest}).Warn("Rendered component not found")
+			http.Error(w, "Rendered component not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "deploymentId": deploymentId, "component": name, "digest": digest, "error": err}).Error("Failed to retrieve rendered component")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Conditional request check against component ETag
+	componentETag := fmt.Sprintf("\"%s\"", component.Digest)
+	if clientHasETag(r.Header, componentETag) {
+		w.Header().Set("ETag", componentETag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", componentETag)
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Write(component.Artifact)
+}
+
 func clientHasETag(header http.Header, currentETag string) bool {
+	return matchIfNoneMatch(header, currentETag, true)
+}
+
+// clientHasExactETag is like clientHasETag, but ignores the wildcard *.
+func clientHasExactETag(header http.Header, currentETag string) bool {
+	return matchIfNoneMatch(header, currentETag, false)
+}
+
+func matchIfNoneMatch(header http.Header, currentETag string, matchAny bool) bool {
 	if currentETag == "" {
 		return false
 	}
@@ -339,7 +533,11 @@ func clientHasETag(header http.Header, currentETag string) bool {
 				break
 			}
 			if remaining[0] == '*' {
-				return true
+				if matchAny {
+					return true
+				}
+				remaining = remaining[1:]
+				continue
 			}
 
 			weak := strings.HasPrefix(remaining, "W/")
@@ -398,28 +596,3 @@ func clientHasETag(header http.Header, currentETag string) bool {
 
 	return false
 }
-
-func validateManifestAcceptHeader(r *http.Request) bool {
-	acceptHeader := r.Header.Get("Accept")
-	if acceptHeader == "" {
-		return true // No Accept header means accept anything
-	}
-
-	// Check if any of the supported media types are acceptable
-	supportedTypes := []string{
-		"application/vnd.margo.manifest.v1+json",
-		"*/*",
-		"application/*",
-		// not implemented yet:
-		// "application/vnd.margo.manifest.v1.jws+json",
-	}
-
-	for _, supportedType := range supportedTypes {
-		if strings.Contains(acceptHeader, supportedType) {
-			return true
-		}
-	}
-
-	// No supported media type found
-	return false
-}
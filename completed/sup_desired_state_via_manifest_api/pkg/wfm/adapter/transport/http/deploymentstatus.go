@@ -0,0 +1,193 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeploymentStatusDTO is a reported status as stored by the server.
+type DeploymentStatusDTO struct {
+	common.DeploymentStatus
+	ReportedAt time.Time `json:"reportedAt"`
+}
+
+type ListDeploymentStatusesResponse struct {
+	Statuses []DeploymentStatusDTO `json:"statuses"`
+}
+
+type DeploymentStatusHandler struct {
+	svc port.DeploymentStatusService
+}
+
+func NewDeploymentStatusHandler(svc port.DeploymentStatusService) *DeploymentStatusHandler {
+	return &DeploymentStatusHandler{
+		svc,
+	}
+}
+
+func (s *DeploymentStatusHandler) ReportDeploymentStatus(w http.ResponseWriter, r *http.Request) {
+	clientId := r.PathValue("clientId")
+	deploymentId := r.PathValue("deploymentId")
+
+	var request common.DeploymentStatus
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"clientId":     clientId,
+			"deploymentId": deploymentId,
+			"error":        err,
+		}).Warn("Failed to decode deployment status")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if request.Kind != common.DeploymentStatusKind || request.DeploymentId != deploymentId {
+		logrus.WithFields(logrus.Fields{
+			"clientId":         clientId,
+			"deploymentId":     deploymentId,
+			"kind":             request.Kind,
+			"bodyDeploymentId": request.DeploymentId,
+		}).Warn("Deployment status kind or deployment ID mismatch")
+		http.Error(w, "Invalid deployment status", http.StatusBadRequest)
+		return
+	}
+
+	status := domain.DeploymentStatus{
+		DeviceId:     clientId,
+		DeploymentId: deploymentId,
+		ApiVersion:   request.ApiVersion,
+		Digest:       request.Digest,
+		State:        request.Status.State,
+		Error:        toDomainStatusError(request.Status.Error),
+		Components:   make([]domain.ComponentStatus, len(request.Components)),
+	}
+	for i, component := range request.Components {
+		status.Components[i] = domain.ComponentStatus{
+			Name:  component.Name,
+			State: component.State,
+			Error: toDomainStatusError(component.Error),
+		}
+	}
+
+	if err := s.svc.ReportDeploymentStatus(r.Context(), status); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidDeploymentStatus):
+			logrus.WithFields(logrus.Fields{
+				"clientId":     clientId,
+				"deploymentId": deploymentId,
+				"error":        err,
+			}).Warn("Invalid deployment status")
+			http.Error(w, "Invalid deployment status", http.StatusBadRequest)
+			return
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{
+				"clientId":     clientId,
+				"deploymentId": deploymentId,
+				"error":        err,
+			}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"clientId":     clientId,
+				"deploymentId": deploymentId,
+				"error":        err,
+			}).Error("Failed to store deployment status")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"clientId":     clientId,
+		"deploymentId": deploymentId,
+		"digest":       request.Digest,
+		"state":        request.Status.State,
+	}).Info("Deployment status reported")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *DeploymentStatusHandler) ListDeploymentStatuses(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+	deploymentId := r.PathValue("deploymentId")
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			logrus.WithField("limit", value).Warn("Invalid deployment status limit")
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	statuses, err := s.svc.ListDeploymentStatuses(r.Context(), deviceId, deploymentId, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"deviceId":     deviceId,
+				"deploymentId": deploymentId,
+				"error":        err,
+			}).Error("Failed to list deployment statuses")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := ListDeploymentStatusesResponse{
+		Statuses: make([]DeploymentStatusDTO, len(statuses)),
+	}
+	for i, status := range statuses {
+		response.Statuses[i] = toDeploymentStatusDTO(status)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func toDeploymentStatusDTO(status domain.DeploymentStatus) DeploymentStatusDTO {
+	dto := DeploymentStatusDTO{
+		DeploymentStatus: common.DeploymentStatus{
+			ApiVersion:   status.ApiVersion,
+			Kind:         common.DeploymentStatusKind,
+			DeploymentId: status.DeploymentId,
+			Digest:       status.Digest,
+			Status: common.DeploymentStatusState{
+				State: status.State,
+				Error: toStatusErrorDTO(status.Error),
+			},
+			Components: make([]common.ComponentStatus, len(status.Components)),
+		},
+		ReportedAt: status.ReportedAt,
+	}
+	for i, component := range status.Components {
+		dto.Components[i] = common.ComponentStatus{
+			Name:  component.Name,
+			State: component.State,
+			Error: toStatusErrorDTO(component.Error),
+		}
+	}
+	return dto
+}
+
+func toDomainStatusError(statusError *common.StatusError) *domain.StatusError {
+	if statusError == nil {
+		return nil
+	}
+	return &domain.StatusError{Code: statusError.Code, Message: statusError.Message}
+}
+
+func toStatusErrorDTO(statusError *domain.StatusError) *common.StatusError {
+	if statusError == nil {
+		return nil
+	}
+	return &common.StatusError{Code: statusError.Code, Message: statusError.Message}
+}
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type DeviceDTO struct {
+	Id          string            `json:"id"`
+	DisplayName string            `json:"displayName"`
+	Labels      map[string]string `json:"labels"`
+	GatewayId   string            `json:"gatewayId,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+type CreateDeviceRequest struct {
+	Id          string            `json:"id"`
+	DisplayName string            `json:"displayName"`
+	Labels      map[string]string `json:"labels"`
+	GatewayId   string            `json:"gatewayId"`
+}
+
+// UpdateDeviceRequest is a partial update: omitted fields are left unchanged,
+// labels, when present, replace the existing set and an empty gatewayId detaches the
+// device from its gateway.
+type UpdateDeviceRequest struct {
+	DisplayName *string           `json:"displayName"`
+	Labels      map[string]string `json:"labels"`
+	GatewayId   *string           `json:"gatewayId"`
+}
+
+type ListDevicesResponse struct {
+	Devices    []DeviceDTO `json:"devices"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+type DeviceHandler struct {
+	svc port.DeviceService
+}
+
+func NewDeviceHandler(svc port.DeviceService) *DeviceHandler {
+	return &DeviceHandler{
+		svc,
+	}
+}
+
+func (s *DeviceHandler) CreateDevice(w http.ResponseWriter, r *http.Request) {
+	var request CreateDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logrus.WithField("error", err).Warn("Failed to decode create device request")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.svc.CreateDevice(r.Context(), domain.Device{
+		Id:          request.Id,
+		DisplayName: request.DisplayName,
+		Labels:      request.Labels,
+		GatewayId:   request.GatewayId,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidDevice):
+			logrus.WithFields(logrus.Fields{
+				"deviceId": request.Id,
+				"error":    err,
+			}).Warn("Invalid device")
+			http.Error(w, "Invalid device", http.StatusBadRequest)
+			return
+		case errors.Is(err, domain.ErrDeviceAlreadyExists):
+			logrus.WithFields(logrus.Fields{
+				"deviceId": request.Id,
+				"error":    err,
+			}).Warn("Device already exists")
+			http.Error(w, "Device already exists", http.StatusConflict)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"deviceId": request.Id,
+				"error":    err,
+			}).Error("Failed to create device")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/devices/%s", created.Id))
+	writeJSON(w, http.StatusCreated, toDeviceDTO(*created))
+}
+
+func (s *DeviceHandler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	cursor := r.URL.Query().Get("cursor")
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			logrus.WithField("limit", value).Warn("Invalid device list limit")
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	devices, nextCursor, err := s.svc.ListDevices(r.Context(), cursor, limit)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"cursor": cursor,
+			"error":  err,
+		}).Error("Failed to list devices")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := ListDevicesResponse{
+		Devices:    make([]DeviceDTO, len(devices)),
+		NextCursor: nextCursor,
+	}
+	for i, device := range devices {
+		response.Devices[i] = toDeviceDTO(device)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *DeviceHandler) GetDevice(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+
+	device, err := s.svc.GetDevice(r.Context(), deviceId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Error("Failed to retrieve device")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toDeviceDTO(*device))
+}
+
+func (s *DeviceHandler) UpdateDevice(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+
+	var request UpdateDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"deviceId": deviceId,
+			"error":    err,
+		}).Warn("Failed to decode update device request")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.svc.UpdateDevice(r.Context(), deviceId, domain.DeviceUpdate{
+		DisplayName: request.DisplayName,
+		Labels:      request.Labels,
+		GatewayId:   request.GatewayId,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{
+				"deviceId": deviceId,
+				"error":    err,
+			}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrInvalidDevice):
+			logrus.WithFields(logrus.Fields{
+				"deviceId": deviceId,
+				"error":    err,
+			}).Warn("Invalid device")
+			http.Error(w, "Invalid device", http.StatusBadRequest)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"deviceId": deviceId,
+				"error":    err,
+			}).Error("Failed to update device")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toDeviceDTO(*updated))
+}
+
+func (s *DeviceHandler) DecommissionDevice(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+
+	if err := s.svc.DecommissionDevice(r.Context(), deviceId); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{
+				"deviceId": deviceId,
+				"error":    err,
+			}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"deviceId": deviceId,
+				"error":    err,
+			}).Error("Failed to decommission device")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toDeviceDTO(device domain.Device) DeviceDTO {
+	return DeviceDTO{
+		Id:          device.Id,
+		DisplayName: device.DisplayName,
+		Labels:      device.Labels,
+		GatewayId:   device.GatewayId,
+		CreatedAt:   device.CreatedAt,
+		UpdatedAt:   device.UpdatedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to marshal response")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonData)
+}
@@ -0,0 +1,230 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// FleetDeploymentRequest is accepted as YAML or JSON. Deployment is the ApplicationDeployment
+// descriptor rolled out to every selected device.
+type FleetDeploymentRequest struct {
+	Selector   DeviceSelectorDTO `yaml:"selector"`
+	Deployment yaml.Node         `yaml:"deployment"`
+}
+
+type DeviceSelectorDTO struct {
+	MatchLabels map[string]string `yaml:"matchLabels" json:"matchLabels,omitempty"`
+	Group       string            `yaml:"group" json:"group,omitempty"`
+}
+
+type FleetDeploymentDTO struct {
+	Id         string            `json:"id"`
+	Selector   DeviceSelectorDTO `json:"selector"`
+	Digest     string            `json:"digest"`
+	Deployment string            `json:"deployment"` // ApplicationDeployment YAML
+	Devices    []string          `json:"devices"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+type ListFleetDeploymentsResponse struct {
+	FleetDeployments []FleetDeploymentDTO `json:"fleetDeployments"`
+}
+
+type FleetDeploymentHandler struct {
+	svc port.FleetDeploymentService
+}
+
+func NewFleetDeploymentHandler(svc port.FleetDeploymentService) *FleetDeploymentHandler {
+	return &FleetDeploymentHandler{
+		svc,
+	}
+}
+
+func (s *FleetDeploymentHandler) CreateFleetDeployment(w http.ResponseWriter, r *http.Request) {
+	selector, descriptor, err := readFleetDeploymentRequest(r)
+	if err != nil {
+		logrus.WithField("error", err).Warn("Failed to decode create fleet deployment request")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.svc.CreateFleetDeployment(r.Context(), selector, descriptor)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidFleetDeployment):
+			logrus.WithField("error", err).Warn("Invalid fleet deployment")
+			http.Error(w, "Invalid fleet deployment", http.StatusBadRequest)
+			return
+		case errors.Is(err, domain.ErrInvalidDeploymentDescriptor):
+			logrus.WithField("error", err).Warn("Invalid deployment descriptor")
+			writeInvalidDescriptor(w, err)
+			return
+		default:
+			logrus.WithField("error", err).Error("Failed to create fleet deployment")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/fleet-deployments/%s", created.Id))
+	writeJSON(w, http.StatusCreated, toFleetDeploymentDTO(*created))
+}
+
+func (s *FleetDeploymentHandler) ListFleetDeployments(w http.ResponseWriter, r *http.Request) {
+	fleets, err := s.svc.ListFleetDeployments(r.Context())
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list fleet deployments")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := ListFleetDeploymentsResponse{
+		FleetDeployments: make([]FleetDeploymentDTO, len(fleets)),
+	}
+	for i, fleet := range fleets {
+		response.FleetDeployments[i] = toFleetDeploymentDTO(fleet)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *FleetDeploymentHandler) GetFleetDeployment(w http.ResponseWriter, r *http.Request) {
+	fleetId := r.PathValue("fleetDeploymentId")
+
+	fleet, err := s.svc.GetFleetDeployment(r.Context(), fleetId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrFleetDeploymentNotFound):
+			logrus.WithFields(logrus.Fields{"fleetDeploymentId": fleetId, "error": err}).Warn("Fleet deployment not found")
+			http.Error(w, "Fleet deployment not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{"fleetDeploymentId": fleetId, "error": err}).Error("Failed to retrieve fleet deployment")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toFleetDeploymentDTO(*fleet))
+}
+
+func (s *FleetDeploymentHandler) UpdateFleetDeployment(w http.ResponseWriter, r *http.Request) {
+	fleetId := r.PathValue("fleetDeploymentId")
+
+	selector, descriptor, err := readFleetDeploymentRequest(r)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"fleetDeploymentId": fleetId,
+			"error":             err,
+		}).Warn("Failed to decode update fleet deployment request")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.svc.UpdateFleetDeployment(r.Context(), fleetId, selector, descriptor)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrFleetDeploymentNotFound):
+			logrus.WithFields(logrus.Fields{
+				"fleetDeploymentId": fleetId,
+				"error":             err,
+			}).Warn("Fleet deployment not found")
+			http.Error(w, "Fleet deployment not found", http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrInvalidFleetDeployment):
+			logrus.WithFields(logrus.Fields{
+				"fleetDeploymentId": fleetId,
+				"error":             err,
+			}).Warn("Invalid fleet deployment")
+			http.Error(w, "Invalid fleet deployment", http.StatusBadRequest)
+			return
+		case errors.Is(err, domain.ErrInvalidDeploymentDescriptor):
+			logrus.WithFields(logrus.Fields{
+				"fleetDeploymentId": fleetId,
+				"error":             err,
+			}).Warn("Invalid deployment descriptor")
+			writeInvalidDescriptor(w, err)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"fleetDeploymentId": fleetId,
+				"error":             err,
+			}).Error("Failed to update fleet deployment")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toFleetDeploymentDTO(*updated))
+}
+
+func (s *FleetDeploymentHandler) DeleteFleetDeployment(w http.ResponseWriter, r *http.Request) {
+	fleetId := r.PathValue("fleetDeploymentId")
+
+	if err := s.svc.DeleteFleetDeployment(r.Context(), fleetId); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrFleetDeploymentNotFound):
+			logrus.WithFields(logrus.Fields{
+				"fleetDeploymentId": fleetId,
+				"error":             err,
+			}).Warn("Fleet deployment not found")
+			http.Error(w, "Fleet deployment not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{
+				"fleetDeploymentId": fleetId,
+				"error":             err,
+			}).Error("Failed to delete fleet deployment")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readFleetDeploymentRequest decodes the request body and serializes the embedded descriptor,
+// which the service validates like the descriptor of a single deployment.
+func readFleetDeploymentRequest(r *http.Request) (domain.DeviceSelector, []byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return domain.DeviceSelector{}, nil, err
+	}
+	var request FleetDeploymentRequest
+	if err = yaml.Unmarshal(body, &request); err != nil {
+		return domain.DeviceSelector{}, nil, err
+	}
+	var descriptor []byte
+	if !request.Deployment.IsZero() {
+		if descriptor, err = yaml.Marshal(&request.Deployment); err != nil {
+			return domain.DeviceSelector{}, nil, err
+		}
+	}
+	return domain.DeviceSelector{
+		MatchLabels: request.Selector.MatchLabels,
+		Group:       request.Selector.Group,
+	}, descriptor, nil
+}
+
+func toFleetDeploymentDTO(fleet domain.FleetDeployment) FleetDeploymentDTO {
+	return FleetDeploymentDTO{
+		Id: fleet.Id,
+		Selector: DeviceSelectorDTO{
+			MatchLabels: fleet.Selector.MatchLabels,
+			Group:       fleet.Selector.Group,
+		},
+		Digest:     fleet.DescriptorDigest,
+		Deployment: string(fleet.Descriptor),
+		Devices:    fleet.Devices,
+		CreatedAt:  fleet.CreatedAt,
+		UpdatedAt:  fleet.UpdatedAt,
+	}
+}
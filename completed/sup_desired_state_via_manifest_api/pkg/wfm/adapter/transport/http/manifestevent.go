@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// manifestEventPageSize is the number of logged events examined per query.
+	manifestEventPageSize = 100
+	// manifestEventKeepAlive is the interval of the comments keeping idle streams open
+	// through proxies; failing writes also detect disconnected clients.
+	manifestEventKeepAlive = 15 * time.Second
+)
+
+type ManifestEventDTO struct {
+	DeviceId        string    `json:"deviceId"`
+	ManifestVersion uint64    `json:"manifestVersion"`
+	ManifestETag    string    `json:"manifestETag"`
+	DeploymentIds   []string  `json:"deploymentIds"`
+	PublishedAt     time.Time `json:"publishedAt"`
+}
+
+type ManifestEventHandler struct {
+	svc port.ManifestEventService
+}
+
+func NewManifestEventHandler(svc port.ManifestEventService) *ManifestEventHandler {
+	return &ManifestEventHandler{
+		svc,
+	}
+}
+
+// StreamManifestEvents streams manifest events as Server-Sent Events. Without a
+// Last-Event-ID header the stream starts with the next published manifest; with one, it
+// resumes after that event from the persisted event log.
+func (s *ManifestEventHandler) StreamManifestEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := manifestEventFilter(r)
+	if err == nil {
+		err = s.svc.ValidateFilter(filter)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"query": r.URL.RawQuery,
+			"error": err,
+		}).Warn("Invalid manifest event filter")
+		http.Error(w, "Invalid filter", http.StatusBadRequest)
+		return
+	}
+
+	// Subscribe before reading the log, so that no event published in between is missed
+	published := s.svc.EventsPublished()
+	var lastId int64
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		if lastId, err = strconv.ParseInt(value, 10, 64); err != nil || lastId < 0 {
+			logrus.WithField("lastEventId", value).Warn("Invalid Last-Event-ID")
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	} else if lastId, err = s.svc.GetLatestManifestEventId(r.Context()); err != nil {
+		logrus.WithField("error", err).Error("Failed to retrieve latest manifest event")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream outlives the server's write timeout; keep-alive writes detect dead clients
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logrus.WithField("error", err).Debug("Failed to clear write deadline; the stream may be cut short")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logrus.WithField("error", err).Error("Streaming not supported")
+		return
+	}
+
+	keepAlive := time.NewTicker(manifestEventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		// Drain the log up to the latest event
+		for {
+			events, next, err := s.svc.ListManifestEvents(r.Context(), lastId, filter, manifestEventPageSize)
+			if err != nil {
+				if r.Context().Err() == nil {
+					logrus.WithField("error", err).Error("Failed to list manifest events")
+				}
+				return
+			}
+			for _, event := range events {
+				if err := writeManifestEvent(w, event); err != nil {
+					return
+				}
+			}
+			if next == lastId {
+				break
+			}
+			lastId = next
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-published:
+			published = s.svc.EventsPublished()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeManifestEvent(w http.ResponseWriter, event domain.ManifestEvent) error {
+	deploymentIds := event.DeploymentIds
+	if deploymentIds == nil {
+		deploymentIds = []string{}
+	}
+	data, err := json.Marshal(ManifestEventDTO{
+		DeviceId:        event.DeviceId,
+		ManifestVersion: event.ManifestVersion,
+		ManifestETag:    event.ManifestETag,
+		DeploymentIds:   deploymentIds,
+		PublishedAt:     event.PublishedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: manifest\ndata: %s\n\n", event.Id, data)
+	return err
+}
+
+// manifestEventFilter reads the filter from the repeatable deviceId and label (key=value)
+// query parameters.
+func manifestEventFilter(r *http.Request) (domain.ManifestEventFilter, error) {
+	query := r.URL.Query()
+	filter := domain.ManifestEventFilter{
+		DeviceIds: query["deviceId"],
+	}
+	for _, label := range query["label"] {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return filter, errors.New("label must be key=value")
+		}
+		if filter.Labels == nil {
+			filter.Labels = map[string]string{}
+		}
+		filter.Labels[key] = value
+	}
+	return filter, nil
+}
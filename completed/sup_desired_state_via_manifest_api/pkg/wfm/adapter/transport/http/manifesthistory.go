@@ -0,0 +1,163 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PublishedManifestDTO is a historical manifest in the representation served to the device,
+// plus the time it was published.
+type PublishedManifestDTO struct {
+	common.GetDeploymentManifestResponse
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+type ListPublishedManifestsResponse struct {
+	Manifests []PublishedManifestDTO `json:"manifests"`
+}
+
+type ManifestHistoryHandler struct {
+	svc port.ManifestHistoryService
+}
+
+func NewManifestHistoryHandler(svc port.ManifestHistoryService) *ManifestHistoryHandler {
+	return &ManifestHistoryHandler{
+		svc,
+	}
+}
+
+func (s *ManifestHistoryHandler) ListPublishedManifests(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+	query := domain.ManifestHistoryQuery{}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 {
+			logrus.WithField("limit", value).Warn("Invalid manifest history limit")
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("before"); value != "" {
+		var err error
+		if query.BeforeVersion, err = strconv.ParseUint(value, 10, 63); err != nil || query.BeforeVersion < 1 {
+			logrus.WithField("before", value).Warn("Invalid manifest history cursor")
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		if query.At, err = time.Parse(time.RFC3339, value); err != nil {
+			logrus.WithField("at", value).Warn("Invalid manifest history time")
+			http.Error(w, "Invalid at: expected an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+
+	manifests, err := s.svc.ListPublishedManifests(r.Context(), deviceId, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Error("Failed to list manifest history")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := ListPublishedManifestsResponse{
+		Manifests: make([]PublishedManifestDTO, len(manifests)),
+	}
+	for i, manifest := range manifests {
+		response.Manifests[i] = toPublishedManifestDTO(manifest)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *ManifestHistoryHandler) GetPublishedManifest(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+	value := r.PathValue("version")
+	version, err := strconv.ParseUint(value, 10, 63)
+	if err != nil || version < 1 {
+		logrus.WithFields(logrus.Fields{"deviceId": deviceId, "version": value}).Warn("Invalid manifest version")
+		http.Error(w, "Invalid manifest version", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := s.svc.GetPublishedManifest(r.Context(), deviceId, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrPublishedManifestNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "version": version}).Warn("Published manifest not found")
+			http.Error(w, "Published manifest not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "version": version, "error": err}).Error("Failed to retrieve published manifest")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Published manifests never change
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	writeJSON(w, http.StatusOK, toPublishedManifestDTO(*manifest))
+}
+
+// RollbackManifest restores the deployments of a published manifest version and responds with
+// the resulting manifest.
+func (s *ManifestHistoryHandler) RollbackManifest(w http.ResponseWriter, r *http.Request) {
+	deviceId := r.PathValue("deviceId")
+	value := r.PathValue("version")
+	version, err := strconv.ParseUint(value, 10, 63)
+	if err != nil || version < 1 {
+		logrus.WithFields(logrus.Fields{"deviceId": deviceId, "version": value}).Warn("Invalid manifest version")
+		http.Error(w, "Invalid manifest version", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := s.svc.RollbackManifest(r.Context(), deviceId, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDeviceNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "error": err}).Warn("Device not found")
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrPublishedManifestNotFound):
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "version": version}).Warn("Published manifest not found")
+			http.Error(w, "Published manifest not found", http.StatusNotFound)
+			return
+		default:
+			logrus.WithFields(logrus.Fields{"deviceId": deviceId, "version": version, "error": err}).Error("Failed to roll back manifest")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"deviceId":        deviceId,
+		"restoredVersion": version,
+		"manifestVersion": manifest.Version,
+	}).Info("Manifest rolled back")
+	writeJSON(w, http.StatusOK, manifestResponse(deviceId, manifest))
+}
+
+func toPublishedManifestDTO(manifest domain.PublishedManifest) PublishedManifestDTO {
+	return PublishedManifestDTO{
+		GetDeploymentManifestResponse: manifestResponse(manifest.DeviceId, &manifest.Manifest),
+		PublishedAt:                   manifest.PublishedAt,
+	}
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// Counter is a monotonically increasing value served by the metrics endpoint.
+type Counter struct {
+	Name  string
+	Help  string
+	Value int64
+}
+
+// CounterSource returns the current values of a set of counters.
+type CounterSource func() []Counter
+
+type MetricsHandler struct {
+	sources []CounterSource
+}
+
+func NewMetricsHandler(sources ...CounterSource) *MetricsHandler {
+	return &MetricsHandler{
+		sources,
+	}
+}
+
+// GetMetrics serves the counters in the Prometheus text exposition format.
+func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, _ *http.Request) {
+	var body bytes.Buffer
+	for _, source := range h.sources {
+		for _, counter := range source() {
+			fmt.Fprintf(&body, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", counter.Name, counter.Help, counter.Name, counter.Name, counter.Value)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(body.Bytes())
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a single element of an Accept header (RFC 9110, Section 12.5.1).
+type mediaRange struct {
+	Type    string
+	Subtype string
+	Params  map[string]string
+	Q       float64
+}
+
+// specificity ranks how precisely a media range names a media type:
+// "*/*" < "type/*" < "type/subtype" < "type/subtype;param=value".
+func (mr mediaRange) specificity() int {
+	switch {
+	case mr.Type == "*":
+		return 0
+	case mr.Subtype == "*":
+		return 1
+	case len(mr.Params) == 0:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// matches reports whether the media range includes the offered media type. A range with
+// parameters only includes offers carrying the same parameter values, so that e.g.
+// "text/plain;format=flowed;q=0" doesn't reject a plain "text/plain" offer.
+func (mr mediaRange) matches(offer mediaRange) bool {
+	if mr.Type != "*" && mr.Type != offer.Type {
+		return false
+	}
+	if mr.Subtype != "*" && mr.Subtype != offer.Subtype {
+		return false
+	}
+	for name, value := range mr.Params {
+		if offered, ok := offer.Params[name]; !ok || offered != value {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAccept parses all Accept header values into media ranges. Malformed
+// ranges are skipped as RFC 9110 permits.
+func parseAccept(values []string) []mediaRange {
+	var ranges []mediaRange
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			element = strings.TrimSpace(element)
+			if element == "" {
+				continue
+			}
+			mr, ok := parseMediaRange(element)
+			if !ok {
+				continue
+			}
+			ranges = append(ranges, mr)
+		}
+	}
+	return ranges
+}
+
+func parseMediaRange(element string) (mediaRange, bool) {
+	parts := strings.Split(element, ";")
+	typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(parts[0])), "/")
+	if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+		return mediaRange{}, false
+	}
+
+	mr := mediaRange{Type: typ, Subtype: subtype, Q: 1}
+	for _, param := range parts[1:] {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return mediaRange{}, false
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if name == "q" {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				return mediaRange{}, false
+			}
+			mr.Q = q
+			// parameters following the weight are accept-ext and don't affect matching
+			break
+		}
+		if mr.Params == nil {
+			mr.Params = map[string]string{}
+		}
+		mr.Params[name] = value
+	}
+	return mr, true
+}
+
+// negotiateContentType selects the best of the offered media types for the request's
+// Accept header. Each offer is weighted with the q-value of the most specific media
+// range that matches it; the highest weight wins, then the more specific match, then
+// the offer listed first. It returns false when no offer is acceptable (q=0 or no match).
+// A missing (or entirely unparsable) Accept header accepts the first offer.
+func negotiateContentType(r *http.Request, offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	ranges := parseAccept(r.Header.Values("Accept"))
+	if len(ranges) == 0 {
+		return offers[0], true
+	}
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		offered, ok := parseMediaRange(offer)
+		if !ok {
+			continue
+		}
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			if mr.matches(offered) && mr.specificity() > specificity {
+				q, specificity = mr.Q, mr.specificity()
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best, best != ""
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OnboardingHandler serves the onboarding endpoints of the WIP Margo workload API.
+// Unlike the PoC endpoints, errors are reported with the JSON envelope that API defines.
+type OnboardingHandler struct {
+	svc port.OnboardingService
+}
+
+func NewOnboardingHandler(svc port.OnboardingService) *OnboardingHandler {
+	return &OnboardingHandler{
+		svc,
+	}
+}
+
+func (s *OnboardingHandler) GetRootCertificate(w http.ResponseWriter, r *http.Request) {
+	certificate, err := s.svc.GetRootCertificate(r.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRootCertificateNotFound):
+			logrus.WithField("error", err).Warn("Root CA certificate requested but not configured")
+			writeOnboardingError(w, http.StatusNotFound, "Root CA certificate not available")
+			return
+		default:
+			logrus.WithField("error", err).Error("Failed to retrieve root CA certificate")
+			writeOnboardingError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, common.RootCertificateResponse{
+		Certificate: base64.StdEncoding.EncodeToString(certificate),
+	})
+}
+
+func (s *OnboardingHandler) OnboardDevice(w http.ResponseWriter, r *http.Request) {
+	var request common.OnboardingRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logrus.WithField("error", err).Warn("Failed to decode onboarding request")
+		writeOnboardingError(w, http.StatusBadRequest, "Invalid certificate")
+		return
+	}
+	certificate, err := base64.StdEncoding.DecodeString(request.PublicCertificate)
+	if err != nil || len(certificate) == 0 {
+		logrus.WithField("error", err).Warn("Onboarding certificate is not valid Base64")
+		writeOnboardingError(w, http.StatusBadRequest, "Invalid certificate")
+		return
+	}
+	proof := domain.OnboardingProof{SignedAt: request.SignedAt}
+	if proof.Signature, err = base64.StdEncoding.DecodeString(request.Signature); err != nil {
+		logrus.WithField("error", err).Warn("Onboarding signature is not valid Base64")
+		writeOnboardingError(w, http.StatusBadRequest, "Invalid signature")
+		return
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		proof.PeerCertificate = r.TLS.PeerCertificates[0].Raw
+	}
+
+	clientId, created, err := s.svc.OnboardDevice(r.Context(), certificate, proof)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidCertificate):
+			logrus.WithField("error", err).Warn("Invalid onboarding certificate")
+			writeOnboardingError(w, http.StatusBadRequest, "Invalid certificate")
+			return
+		case errors.Is(err, domain.ErrCertificateRejected):
+			logrus.WithField("error", err).Warn("Onboarding certificate rejected")
+			writeOnboardingError(w, http.StatusForbidden, "Client rejected")
+			return
+		default:
+			logrus.WithField("error", err).Error("Failed to onboard device")
+			writeOnboardingError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, common.OnboardingResponse{ClientId: clientId})
+}
+
+func writeOnboardingError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, common.OnboardingErrorResponse{Error: message})
+}
@@ -2,39 +2,102 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// ClientAuthMode controls whether devices authenticate with TLS client certificates.
+type ClientAuthMode string
+
+const (
+	// ClientAuthNone does not request client certificates; device and operator routes are not protected.
+	ClientAuthNone ClientAuthMode = "none"
+	// ClientAuthOptional verifies client certificates when presented. Onboarding remains usable
+	// without one; device routes require a device and operator routes an operator certificate.
+	ClientAuthOptional ClientAuthMode = "optional"
+	// ClientAuthRequire rejects TLS handshakes without a valid client certificate.
+	ClientAuthRequire ClientAuthMode = "require"
+)
+
 type Config struct {
 	// Address of the WFM API server
 	BindAddress string
+	// PEM server certificate and key; TLS is disabled when TLSCertFile is empty
+	TLSCertFile string
+	TLSKeyFile  string
+	// CAs that device and operator client certificates must chain to, required unless
+	// ClientAuth is ClientAuthNone
+	ClientCAs  *x509.CertPool
+	ClientAuth ClientAuthMode
 }
 
 type Server struct {
-	srv *http.Server
+	srv    *http.Server
+	config Config
 }
 
-func NewServer(config Config, deploymentHandler DeploymentHandler) *Server {
+func NewServer(config Config, authenticator DeviceAuthenticator, operatorAuthenticator OperatorAuthenticator, deploymentHandler DeploymentHandler, deviceHandler DeviceHandler, onboardingHandler OnboardingHandler, capabilitiesHandler CapabilitiesHandler, statusHandler DeploymentStatusHandler, fleetHandler FleetDeploymentHandler, eventHandler ManifestEventHandler, historyHandler ManifestHistoryHandler, metricsHandler MetricsHandler) *Server {
 	mux := http.NewServeMux()
 
 	noContent := func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
 	}
 
+	// Device routes are bound to the client certificate unless client authentication is off
+	device := func(pathValue string, next http.HandlerFunc) http.HandlerFunc {
+		if config.ClientAuth == ClientAuthNone || config.ClientAuth == "" {
+			return next
+		}
+		return authenticator.RequireDevice(pathValue, next)
+	}
+	// Operator routes require an operator certificate under the same condition
+	operator := func(next http.HandlerFunc) http.HandlerFunc {
+		if config.ClientAuth == ClientAuthNone || config.ClientAuth == "" {
+			return next
+		}
+		return operatorAuthenticator.RequireOperator(next)
+	}
+
 	// Endpoints proposed by the SUP. Those routes are expected
 	// to be implemented by compliant WFM API servers.
-	mux.HandleFunc("GET /api/v1/devices/{deviceId}/deployments", deploymentHandler.GetDeploymentManifest)
-	mux.HandleFunc("GET /api/v1/devices/{deviceId}/deployments/{deploymentId}/{digest}", deploymentHandler.GetDeployment)
-	mux.HandleFunc("GET /api/v1/devices/{deviceId}/bundles/{digest}", deploymentHandler.GetBundle)
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/deployments", device("deviceId", deploymentHandler.GetDeploymentManifest))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/deployments/{deploymentId}/{digest}", device("deviceId", deploymentHandler.GetDeployment))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/bundles/{digest}", device("deviceId", deploymentHandler.GetBundle))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/deployments/{deploymentId}/components/{component}/{digest}", device("deviceId", deploymentHandler.GetRenderedComponent))
+	// Onboarding endpoints of the (work in progress) Margo workload API.
+	mux.HandleFunc("GET /api/v1/onboarding/certificate", onboardingHandler.GetRootCertificate)
+	mux.HandleFunc("POST /api/v1/onboarding", onboardingHandler.OnboardDevice)
+	mux.HandleFunc("POST /api/v1/client/{clientId}/capabilities", device("clientId", capabilitiesHandler.ReportCapabilities))
+	mux.HandleFunc("POST /api/v1/client/{clientId}/deployment/{deploymentId}/status", device("clientId", statusHandler.ReportDeploymentStatus))
 	// Non-standard endpoints used for demo purposes only. Those routes
 	// are NOT expected to be implemented by compliant WFM API servers.
-	mux.HandleFunc("POST /api/v1/devices/{deviceId}/deployments", deploymentHandler.CreateDeployment)
-	mux.HandleFunc("PUT /api/v1/devices/{deviceId}/deployments/{deploymentId}", deploymentHandler.UpdateDeployment)
-	mux.HandleFunc("DELETE /api/v1/devices/{deviceId}/deployments/{deploymentId}", deploymentHandler.DeleteDeployment)
+	mux.HandleFunc("POST /api/v1/devices/{deviceId}/deployments", operator(deploymentHandler.CreateDeployment))
+	mux.HandleFunc("PUT /api/v1/devices/{deviceId}/deployments/{deploymentId}", operator(deploymentHandler.UpdateDeployment))
+	mux.HandleFunc("DELETE /api/v1/devices/{deviceId}/deployments/{deploymentId}", operator(deploymentHandler.DeleteDeployment))
+	mux.HandleFunc("POST /api/v1/deployments/validate", operator(deploymentHandler.ValidateDeployment))
+	mux.HandleFunc("POST /api/v1/fleet-deployments", operator(fleetHandler.CreateFleetDeployment))
+	mux.HandleFunc("GET /api/v1/fleet-deployments", operator(fleetHandler.ListFleetDeployments))
+	mux.HandleFunc("GET /api/v1/fleet-deployments/{fleetDeploymentId}", operator(fleetHandler.GetFleetDeployment))
+	mux.HandleFunc("PUT /api/v1/fleet-deployments/{fleetDeploymentId}", operator(fleetHandler.UpdateFleetDeployment))
+	mux.HandleFunc("DELETE /api/v1/fleet-deployments/{fleetDeploymentId}", operator(fleetHandler.DeleteFleetDeployment))
+	mux.HandleFunc("POST /api/v1/devices", operator(deviceHandler.CreateDevice))
+	mux.HandleFunc("GET /api/v1/devices", operator(deviceHandler.ListDevices))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}", operator(deviceHandler.GetDevice))
+	mux.HandleFunc("PATCH /api/v1/devices/{deviceId}", operator(deviceHandler.UpdateDevice))
+	mux.HandleFunc("DELETE /api/v1/devices/{deviceId}", operator(deviceHandler.DecommissionDevice))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/capabilities", operator(capabilitiesHandler.GetCapabilities))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/deployments/{deploymentId}/status", operator(statusHandler.ListDeploymentStatuses))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/manifests", operator(historyHandler.ListPublishedManifests))
+	mux.HandleFunc("GET /api/v1/devices/{deviceId}/manifests/{version}", operator(historyHandler.GetPublishedManifest))
+	mux.HandleFunc("POST /api/v1/devices/{deviceId}/manifests/{version}/rollback", operator(historyHandler.RollbackManifest))
+	mux.HandleFunc("GET /api/v1/manifest-events", operator(eventHandler.StreamManifestEvents))
+	mux.HandleFunc("GET /metrics", operator(metricsHandler.GetMetrics))
 	mux.HandleFunc("GET /healthz", noContent)
 	RegisterOpenAPIRoutes(mux)
 
@@ -45,19 +108,45 @@ func NewServer(config Config, deploymentHandler DeploymentHandler) *Server {
 		WriteTimeout:      30 * time.Second,
 		IdleTimeout:       120 * time.Second,
 	}
+	// Cancel the request contexts on shutdown, so that manifest watches return at once
+	// instead of holding the shutdown until their wait expires
+	baseCtx, cancel := context.WithCancel(context.Background())
+	srv.BaseContext = func(net.Listener) context.Context { return baseCtx }
+	srv.RegisterOnShutdown(cancel)
+	if config.TLSCertFile != "" {
+		srv.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS13,
+			ClientCAs:  config.ClientCAs,
+		}
+		switch config.ClientAuth {
+		case ClientAuthOptional:
+			srv.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		case ClientAuthRequire:
+			srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
 
-	return &Server{srv}
+	return &Server{srv, config}
 }
 
 func (s *Server) Run(ctx context.Context) error {
-	baseURL := fmt.Sprintf("http://%s", s.srv.Addr)
+	scheme := "http"
+	if s.srv.TLSConfig != nil {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s", scheme, s.srv.Addr)
 	logrus.WithFields(logrus.Fields{
-		"docs":    baseURL + "/docs",
-		"swagger": baseURL + "/swagger",
+		"docs":       baseURL + "/docs",
+		"swagger":    baseURL + "/swagger",
+		"clientAuth": s.config.ClientAuth,
 	}).Info("WFM API ready")
 
 	errCh := make(chan error, 1)
 	go func() {
+		if s.srv.TLSConfig != nil {
+			errCh <- s.srv.ListenAndServeTLS(s.config.TLSCertFile, s.config.TLSKeyFile)
+			return
+		}
 		errCh <- s.srv.ListenAndServe()
 	}()
 
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"skeleton/pkg/wfm/core/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+type DescriptorViolationDTO struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type ValidateDeploymentResponse struct {
+	Valid      bool                     `json:"valid"`
+	Violations []DescriptorViolationDTO `json:"violations"`
+}
+
+// ValidateDeployment reports all violations of a descriptor without storing it. The response
+// is 200 for valid and invalid descriptors alike; valid tells them apart.
+func (s *DeploymentHandler) ValidateDeployment(w http.ResponseWriter, r *http.Request) {
+	descriptor, err := io.ReadAll(r.Body)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to read HTTP body")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	violations, err := s.svc.ValidateDeployment(r.Context(), descriptor)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to validate deployment descriptor")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("violations", len(violations)).Info("Deployment descriptor validated")
+	writeJSON(w, http.StatusOK, toValidateDeploymentResponse(violations))
+}
+
+// writeInvalidDescriptor answers a rejected create or update with the violations found, if any.
+func writeInvalidDescriptor(w http.ResponseWriter, err error) {
+	var validationErr *domain.DescriptorValidationError
+	if !errors.As(err, &validationErr) {
+		http.Error(w, "Invalid deployment descriptor", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, toValidateDeploymentResponse(validationErr.Violations))
+}
+
+func toValidateDeploymentResponse(violations []domain.DescriptorViolation) ValidateDeploymentResponse {
+	response := ValidateDeploymentResponse{
+		Valid:      len(violations) == 0,
+		Violations: make([]DescriptorViolationDTO, len(violations)),
+	}
+	for i, violation := range violations {
+		response.Violations[i] = DescriptorViolationDTO{Path: violation.Path, Message: violation.Message}
+	}
+	return response
+}
@@ -1,14 +1,53 @@
 package domain
 
+import (
+	"strings"
+)
+
 type ApplicationDeploymentManifest struct {
 	Version       uint64
 	BundleArchive []byte
 	BundleDigest  string
+	BundleSize    uint64
 	Deployments   []ApplicationDeployment
 }
 
 type ApplicationDeployment struct {
-	Id               string
-	Descriptor       []byte
-	DescriptorDigest string
+	Id                string
+	Descriptor        []byte
+	DescriptorDigest  string
+	DescriptorSize    uint64
+	Components        []RenderedComponent // empty unless the WFM renders deployments
+	FleetDeploymentId string              // empty unless rolled out by a fleet deployment
+	// Target is the device the deployment is for in the aggregated manifest of a gateway;
+	// empty otherwise.
+	Target string
+}
+
+// RenderedComponent is a deployment component with the parameters resolved into its values.
+type RenderedComponent struct {
+	Name     string
+	Artifact []byte
+	Digest   string
+	Size     uint64
+}
+
+// DescriptorViolation is a violation of a deployment descriptor, located by its YAML path
+// (e.g. spec.parameters.cpuLimit.targets[0].pointer).
+type DescriptorViolation struct {
+	Path    string
+	Message string
+}
+
+// DescriptorValidationError carries every violation found in a deployment descriptor.
+type DescriptorValidationError struct {
+	Violations []DescriptorViolation
+}
+
+func (e *DescriptorValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Path + ": " + violation.Message
+	}
+	return "svc: descriptor violations: " + strings.Join(messages, "; ")
 }
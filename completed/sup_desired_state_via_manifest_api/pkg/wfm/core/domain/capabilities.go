@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Device roles defined by the DeviceCapabilities schema.
+const (
+	RoleStandaloneCluster = "Standalone Cluster"
+	RoleClusterLeader     = "Cluster Leader"
+	RoleStandaloneDevice  = "Standalone Device"
+)
+
+// DeviceCapabilities is the hardware profile a device last reported about itself.
+// Memory and storage are kept as reported (e.g. "16Gi").
+type DeviceCapabilities struct {
+	DeviceId     string
+	ApiVersion   string
+	Vendor       string
+	ModelNumber  string
+	SerialNumber string
+	Roles        []string
+	CpuCores     float64
+	Memory       string
+	Storage      string
+	ReportedAt   time.Time
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// Deployment and component states reported by devices.
+const (
+	DeploymentStatePending    = "Pending"
+	DeploymentStateInstalling = "Installing"
+	DeploymentStateInstalled  = "Installed"
+	DeploymentStateFailed     = "Failed"
+	DeploymentStateRemoved    = "Removed"
+)
+
+// DeploymentStatus is one status report of a device about a deployment. Reports are
+// kept as history; the most recent one reflects the current state on the device.
+type DeploymentStatus struct {
+	DeviceId     string
+	DeploymentId string
+	ApiVersion   string
+	Digest       string // descriptor digest the state refers to, empty if unknown
+	State        string
+	Error        *StatusError
+	Components   []ComponentStatus
+	ReportedAt   time.Time
+}
+
+type ComponentStatus struct {
+	Name  string
+	State string
+	Error *StatusError
+}
+
+type StatusError struct {
+	Code    string
+	Message string
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+type Device struct {
+	Id          string
+	DisplayName string
+	Labels      map[string]string
+	GatewayId   string // gateway serving the device, if any; gateways are never children
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// DeviceUpdate describes a partial device update. Nil fields are left unchanged;
+// a non-nil Labels map replaces all existing labels and an empty GatewayId detaches the
+// device from its gateway.
+type DeviceUpdate struct {
+	DisplayName *string
+	Labels      map[string]string
+	GatewayId   *string
+}
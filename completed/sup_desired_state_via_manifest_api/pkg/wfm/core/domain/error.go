@@ -8,7 +8,21 @@ var (
 	ErrInternal                    = errors.New("internal error")
 	ErrInvalidDeploymentDescriptor = errors.New("invalid ApplicationDeployment descriptor")
 	ErrDeviceNotFound              = errors.New("device not found")
+	ErrDeviceAlreadyExists         = errors.New("device already exists")
+	ErrInvalidDevice               = errors.New("invalid device")
+	ErrInvalidCertificate          = errors.New("invalid certificate")
+	ErrCertificateRejected         = errors.New("certificate rejected")
+	ErrRootCertificateNotFound     = errors.New("root CA certificate not configured")
+	ErrInvalidCapabilities         = errors.New("invalid device capabilities")
+	ErrCapabilitiesNotFound        = errors.New("device capabilities not reported")
+	ErrInvalidDeploymentStatus     = errors.New("invalid deployment status")
 	ErrManifestNotFound            = errors.New("application deployment manifest not found")
 	ErrDeploymentNotFound          = errors.New("application deployment not found")
 	ErrBundleNotFound              = errors.New("application deployment bundle not found")
+	ErrRenderedComponentNotFound   = errors.New("rendered deployment component not found")
+	ErrInvalidFleetDeployment      = errors.New("invalid fleet deployment")
+	ErrFleetDeploymentNotFound     = errors.New("fleet deployment not found")
+	ErrFleetManagedDeployment      = errors.New("application deployment is managed by a fleet deployment")
+	ErrInvalidManifestEventFilter  = errors.New("invalid manifest event filter")
+	ErrPublishedManifestNotFound   = errors.New("published manifest not found")
 )
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// DeviceGroupLabel is the device label naming the group a device belongs to. A fleet
+// deployment referencing a group targets the devices carrying this label with the group name.
+const DeviceGroupLabel = "group"
+
+// FleetDeployment is an application deployment rolled out to every device its selector
+// matches. The WFM keeps one ApplicationDeployment per targeted device, sharing the ID and
+// descriptor of the fleet deployment.
+type FleetDeployment struct {
+	Id               string
+	Selector         DeviceSelector
+	Descriptor       []byte
+	DescriptorDigest string
+	Devices          []string // IDs of the devices currently carrying the deployment
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// DeviceSelector selects devices by labels and/or group. A device matches when it carries
+// all MatchLabels and, if Group is set, belongs to the group.
+type DeviceSelector struct {
+	MatchLabels map[string]string
+	Group       string
+}
+
+func (s DeviceSelector) IsEmpty() bool {
+	return len(s.MatchLabels) == 0 && s.Group == ""
+}
+
+// Matches reports whether the device is selected. An empty selector matches no device.
+func (s DeviceSelector) Matches(device Device) bool {
+	if s.IsEmpty() {
+		return false
+	}
+	if s.Group != "" && device.Labels[DeviceGroupLabel] != s.Group {
+		return false
+	}
+	for key, value := range s.MatchLabels {
+		if actual, ok := device.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// ManifestEvent records the publication of a new manifest version of a device. Events are
+// ordered by Id, which also serves as the resume position of the event stream.
+type ManifestEvent struct {
+	Id              int64
+	DeviceId        string
+	ManifestVersion uint64
+	ManifestETag    string   // ETag of the unsigned manifest representation
+	DeploymentIds   []string // deployments added, changed or removed by the new version
+	PublishedAt     time.Time
+	DeviceLabels    map[string]string // current labels of the device, empty once it is deleted
+}
+
+// ManifestEventFilter selects manifest events. An event matches when its device is one of
+// DeviceIds, if any are given, and carries all Labels. An empty filter matches every event.
+type ManifestEventFilter struct {
+	DeviceIds []string
+	Labels    map[string]string
+}
+
+func (f ManifestEventFilter) Matches(event ManifestEvent) bool {
+	if len(f.DeviceIds) > 0 {
+		found := false
+		for _, deviceId := range f.DeviceIds {
+			if deviceId == event.DeviceId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for key, value := range f.Labels {
+		if actual, ok := event.DeviceLabels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
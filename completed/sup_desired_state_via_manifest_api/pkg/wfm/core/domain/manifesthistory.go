@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// PublishedManifest is a manifest version as it was published to a device. Published
+// manifests are immutable; descriptors and component artifacts are referenced by digest.
+type PublishedManifest struct {
+	DeviceId    string
+	Manifest    ApplicationDeploymentManifest
+	PublishedAt time.Time
+}
+
+// ManifestHistoryQuery pages through the manifest history, most recent version first.
+type ManifestHistoryQuery struct {
+	BeforeVersion uint64    // only versions below, unless zero
+	At            time.Time // only versions published at or before, unless zero
+	Limit         int
+}
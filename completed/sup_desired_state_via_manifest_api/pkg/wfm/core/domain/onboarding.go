@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// DeviceCertificate is the client certificate a device presented during onboarding.
+// It is identified by the SHA-256 fingerprint of its DER encoding.
+type DeviceCertificate struct {
+	Fingerprint string
+	DeviceId    string
+	Certificate []byte // DER
+	Subject     string
+	NotAfter    time.Time
+	CreatedAt   time.Time
+}
+
+// OnboardingProof proves that the onboarding client holds the private key of the certificate
+// it presents: either the same certificate was presented in the TLS handshake, or the client
+// signed the certificate's fingerprint and SignedAt with the key.
+type OnboardingProof struct {
+	PeerCertificate []byte // DER, nil without a TLS client certificate
+	SignedAt        time.Time
+	Signature       []byte
+}
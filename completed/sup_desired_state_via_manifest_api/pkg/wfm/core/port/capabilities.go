@@ -0,0 +1,20 @@
+package port
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type CapabilitiesRepository interface {
+	// UpsertCapabilities replaces the capabilities previously reported by the device.
+	UpsertCapabilities(ctx context.Context, capabilities domain.DeviceCapabilities) error
+	GetCapabilities(ctx context.Context, deviceId string) (*domain.DeviceCapabilities, error)
+	ListGatewayChildCapabilities(ctx context.Context, gatewayId string) ([]domain.DeviceCapabilities, error)
+}
+
+type CapabilitiesService interface {
+	ReportCapabilities(ctx context.Context, capabilities domain.DeviceCapabilities) error
+	// GetCapabilities returns the capabilities a device reported. A gateway is presented with
+	// the combined resources of its children.
+	GetCapabilities(ctx context.Context, deviceId string) (*domain.DeviceCapabilities, error)
+}
@@ -5,18 +5,32 @@ import (
 	"skeleton/pkg/wfm/core/domain"
 )
 
+// ManifestAggregateFunc rebuilds the bundle of the aggregated manifest of a gateway, whose
+// deployments carry their target device, and increments its version when the bundle changed.
+// Repositories call it in every transaction that changes a manifest the gateway aggregates.
+type ManifestAggregateFunc func(manifest *domain.ApplicationDeploymentManifest) error
+
 type DeploymentRepository interface {
 	UpsertDeployments(ctx context.Context, deviceId string, updateFn func(manifest *domain.ApplicationDeploymentManifest) error) error
+	// GetDeploymentManifest returns the manifest of a device. A gateway is returned its
+	// aggregated manifest, covering the deployments of the gateway and its children, once one
+	// was built.
 	GetDeploymentManifest(ctx context.Context, deviceId string) (*domain.ApplicationDeploymentManifest, error)
 	GetDeployment(ctx context.Context, deviceId, deploymentId, digest string) (*domain.ApplicationDeployment, error)
 	GetBundle(ctx context.Context, deviceId, digest string) ([]byte, error)
+	GetRenderedComponent(ctx context.Context, deviceId, deploymentId, name, digest string) (*domain.RenderedComponent, error)
 }
 
 type DeploymentService interface {
 	CreateDeployment(ctx context.Context, deviceId string, descriptor []byte) (*domain.ApplicationDeployment, error)
 	UpdateDeployment(ctx context.Context, deviceId, deploymentId string, descriptor []byte) (*domain.ApplicationDeployment, error)
 	DeleteDeployment(ctx context.Context, deviceId, deploymentId string) error
+	ValidateDeployment(ctx context.Context, descriptor []byte) ([]domain.DescriptorViolation, error)
 	GetDeploymentManifest(ctx context.Context, deviceId string) (*domain.ApplicationDeploymentManifest, error)
+	// ManifestChanged returns a channel that is closed when the manifest of the device may
+	// have changed.
+	ManifestChanged(deviceId string) <-chan struct{}
 	GetDeployment(ctx context.Context, deviceId, deploymentId, digest string) (*domain.ApplicationDeployment, error)
 	GetBundle(ctx context.Context, deviceId, digest string) ([]byte, error)
+	GetRenderedComponent(ctx context.Context, deviceId, deploymentId, name, digest string) (*domain.RenderedComponent, error)
 }
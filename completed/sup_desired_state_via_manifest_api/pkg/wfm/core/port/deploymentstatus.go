@@ -0,0 +1,17 @@
+package port
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type DeploymentStatusRepository interface {
+	InsertDeploymentStatus(ctx context.Context, status domain.DeploymentStatus) error
+	// ListDeploymentStatuses returns up to limit status reports, most recent first.
+	ListDeploymentStatuses(ctx context.Context, deviceId, deploymentId string, limit int) ([]domain.DeploymentStatus, error)
+}
+
+type DeploymentStatusService interface {
+	ReportDeploymentStatus(ctx context.Context, status domain.DeploymentStatus) error
+	ListDeploymentStatuses(ctx context.Context, deviceId, deploymentId string, limit int) ([]domain.DeploymentStatus, error)
+}
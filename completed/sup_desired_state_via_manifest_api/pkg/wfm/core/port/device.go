@@ -0,0 +1,26 @@
+package port
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type DeviceRepository interface {
+	// CreateDevice stores the device. A syncFn other than nil syncs its manifest with the
+	// fleet deployments in the same transaction.
+	CreateDevice(ctx context.Context, device *domain.Device, syncFn FleetSyncFunc) error
+	ListDevices(ctx context.Context, after string, limit int) ([]domain.Device, error)
+	GetDevice(ctx context.Context, deviceId string) (*domain.Device, error)
+	// UpdateDevice lets the caller mutate the device. A syncFn other than nil syncs its
+	// manifest with the fleet deployments in the same transaction.
+	UpdateDevice(ctx context.Context, deviceId string, updateFn func(device *domain.Device) error, syncFn FleetSyncFunc) (*domain.Device, error)
+	DeleteDevice(ctx context.Context, deviceId string) error
+}
+
+type DeviceService interface {
+	CreateDevice(ctx context.Context, device domain.Device) (*domain.Device, error)
+	ListDevices(ctx context.Context, cursor string, limit int) (devices []domain.Device, nextCursor string, err error)
+	GetDevice(ctx context.Context, deviceId string) (*domain.Device, error)
+	UpdateDevice(ctx context.Context, deviceId string, update domain.DeviceUpdate) (*domain.Device, error)
+	DecommissionDevice(ctx context.Context, deviceId string) error
+}
@@ -0,0 +1,34 @@
+package port
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+// FleetSyncFunc reconciles the manifest of a device with all fleet deployments. Repositories
+// call it within the transaction that changed the fleet deployments or the device, so that a
+// change is rolled out to all affected devices or to none.
+type FleetSyncFunc func(device domain.Device, fleets []domain.FleetDeployment, manifest *domain.ApplicationDeploymentManifest) error
+
+type FleetDeploymentRepository interface {
+	// CreateFleetDeployment stores the fleet deployment and syncs the devices its selector matches.
+	CreateFleetDeployment(ctx context.Context, fleet *domain.FleetDeployment, syncFn FleetSyncFunc) error
+	// UpdateFleetDeployment lets the caller mutate the fleet deployment and syncs the devices
+	// carrying it as well as those its new selector matches.
+	UpdateFleetDeployment(ctx context.Context, fleetId string, updateFn func(fleet *domain.FleetDeployment) error, syncFn FleetSyncFunc) (*domain.FleetDeployment, error)
+	// DeleteFleetDeployment removes the fleet deployment and syncs the devices carrying it.
+	DeleteFleetDeployment(ctx context.Context, fleetId string, syncFn FleetSyncFunc) error
+	ListFleetDeployments(ctx context.Context) ([]domain.FleetDeployment, error)
+	GetFleetDeployment(ctx context.Context, fleetId string) (*domain.FleetDeployment, error)
+}
+
+type FleetDeploymentService interface {
+	CreateFleetDeployment(ctx context.Context, selector domain.DeviceSelector, descriptor []byte) (*domain.FleetDeployment, error)
+	UpdateFleetDeployment(ctx context.Context, fleetId string, selector domain.DeviceSelector, descriptor []byte) (*domain.FleetDeployment, error)
+	DeleteFleetDeployment(ctx context.Context, fleetId string) error
+	ListFleetDeployments(ctx context.Context) ([]domain.FleetDeployment, error)
+	GetFleetDeployment(ctx context.Context, fleetId string) (*domain.FleetDeployment, error)
+	// SyncFunc returns the FleetSyncFunc that rolls the fleet deployments matching a device out
+	// to it and removes those that no longer match, e.g. after its labels changed.
+	SyncFunc() FleetSyncFunc
+}
@@ -0,0 +1,31 @@
+package port
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+// ManifestETagFunc returns the ETag the manifest endpoint serves for the unsigned manifest
+// representation, so that manifest events can be matched against conditional requests.
+type ManifestETagFunc func(deviceId string, manifest *domain.ApplicationDeploymentManifest) (string, error)
+
+type ManifestEventRepository interface {
+	// ListManifestEvents returns up to limit events following the event with ID after, oldest
+	// first.
+	ListManifestEvents(ctx context.Context, after int64, limit int) ([]domain.ManifestEvent, error)
+	// GetLatestManifestEventId returns the ID of the latest event, zero if there is none.
+	GetLatestManifestEventId(ctx context.Context) (int64, error)
+}
+
+type ManifestEventService interface {
+	// ListManifestEvents returns the events matching the filter among up to limit events
+	// following the event with ID after, and the ID of the last event examined. The ID
+	// equals after when there are no further events.
+	ListManifestEvents(ctx context.Context, after int64, filter domain.ManifestEventFilter, limit int) ([]domain.ManifestEvent, int64, error)
+	GetLatestManifestEventId(ctx context.Context) (int64, error)
+	// ValidateFilter checks the device IDs and labels of the filter.
+	ValidateFilter(filter domain.ManifestEventFilter) error
+	// EventsPublished returns a channel that is closed when new events may have been
+	// published.
+	EventsPublished() <-chan struct{}
+}
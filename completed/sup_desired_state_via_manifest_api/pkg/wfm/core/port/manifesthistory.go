@@ -0,0 +1,23 @@
+package port
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type ManifestHistoryRepository interface {
+	// ListPublishedManifests returns up to query.Limit published manifests, most recent first.
+	ListPublishedManifests(ctx context.Context, deviceId string, query domain.ManifestHistoryQuery) ([]domain.PublishedManifest, error)
+	GetPublishedManifest(ctx context.Context, deviceId string, version uint64) (*domain.PublishedManifest, error)
+	// GetPublishedDeployments returns the deployments of a published manifest including their
+	// descriptors and rendered component artifacts.
+	GetPublishedDeployments(ctx context.Context, deviceId string, version uint64) ([]domain.ApplicationDeployment, error)
+}
+
+type ManifestHistoryService interface {
+	ListPublishedManifests(ctx context.Context, deviceId string, query domain.ManifestHistoryQuery) ([]domain.PublishedManifest, error)
+	GetPublishedManifest(ctx context.Context, deviceId string, version uint64) (*domain.PublishedManifest, error)
+	// RollbackManifest publishes the deployments of an earlier manifest version again, as a
+	// new manifest version.
+	RollbackManifest(ctx context.Context, deviceId string, version uint64) (*domain.ApplicationDeploymentManifest, error)
+}
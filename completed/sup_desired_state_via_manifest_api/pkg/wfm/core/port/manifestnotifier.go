@@ -0,0 +1,11 @@
+package port
+
+// ManifestNotifier signals changes of device manifests to requests waiting for them.
+type ManifestNotifier interface {
+	// Notify signals that the manifests of the devices may have changed.
+	Notify(deviceIds ...string)
+	// Changed returns a channel that is closed by the next Notify for the device.
+	Changed(deviceId string) <-chan struct{}
+	// AnyChanged returns a channel that is closed by the next Notify for any device.
+	AnyChanged() <-chan struct{}
+}
@@ -0,0 +1,22 @@
+package port
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+)
+
+type OnboardingRepository interface {
+	// OnboardDevice returns the device already bound to the certificate or, if there is
+	// none, creates the device and binds the certificate to it. created reports which case applied.
+	OnboardDevice(ctx context.Context, certificate domain.DeviceCertificate, device domain.Device) (deviceId string, created bool, err error)
+	GetDeviceIdByCertificateFingerprint(ctx context.Context, fingerprint string) (string, error)
+}
+
+type OnboardingService interface {
+	GetRootCertificate(ctx context.Context) ([]byte, error)
+	// OnboardDevice requires proof that the caller holds the private key of the certificate,
+	// both before creating a device and before returning the ID of an existing one.
+	OnboardDevice(ctx context.Context, certificate []byte, proof domain.OnboardingProof) (clientId string, created bool, err error)
+	// GetDeviceIdByCertificate returns the device onboarded with the given DER encoded certificate.
+	GetDeviceIdByCertificate(ctx context.Context, certificate []byte) (string, error)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxCapabilityFieldLength = 256
+
+// quantitySuffixes are the binary and decimal suffixes of reported memory and storage sizes,
+// largest first.
+var quantitySuffixes = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"Ei", 1 << 60}, {"Pi", 1 << 50}, {"Ti", 1 << 40}, {"Gi", 1 << 30}, {"Mi", 1 << 20}, {"Ki", 1 << 10},
+	{"E", 1e18}, {"P", 1e15}, {"T", 1e12}, {"G", 1e9}, {"M", 1e6}, {"k", 1e3},
+}
+
+var deviceRoles = map[string]struct{}{
+	domain.RoleStandaloneCluster: {},
+	domain.RoleClusterLeader:     {},
+	domain.RoleStandaloneDevice:  {},
+}
+
+type CapabilitiesService struct {
+	capabilitiesRepo port.CapabilitiesRepository
+}
+
+func NewCapabilitiesService(capabilitiesRepo port.CapabilitiesRepository) *CapabilitiesService {
+	return &CapabilitiesService{
+		capabilitiesRepo: capabilitiesRepo,
+	}
+}
+
+func (cs *CapabilitiesService) ReportCapabilities(ctx context.Context, capabilities domain.DeviceCapabilities) error {
+	for _, field := range []struct{ name, value string }{
+		{"apiVersion", capabilities.ApiVersion},
+		{"vendor", capabilities.Vendor},
+		{"modelNumber", capabilities.ModelNumber},
+		{"serialNumber", capabilities.SerialNumber},
+		{"memory", capabilities.Memory},
+		{"storage", capabilities.Storage},
+	} {
+		if field.value == "" || len(field.value) > maxCapabilityFieldLength {
+			return errors.Join(domain.ErrInvalidCapabilities, fmt.Errorf("svc: %s must be 1-%d characters", field.name, maxCapabilityFieldLength))
+		}
+	}
+	if capabilities.CpuCores <= 0 || math.IsInf(capabilities.CpuCores, 0) || math.IsNaN(capabilities.CpuCores) {
+		return errors.Join(domain.ErrInvalidCapabilities, fmt.Errorf("svc: invalid cpu cores %v", capabilities.CpuCores))
+	}
+	if len(capabilities.Roles) == 0 {
+		return errors.Join(domain.ErrInvalidCapabilities, errors.New("svc: at least one role is required"))
+	}
+	seen := make(map[string]struct{}, len(capabilities.Roles))
+	for _, role := range capabilities.Roles {
+		if _, ok := deviceRoles[role]; !ok {
+			return errors.Join(domain.ErrInvalidCapabilities, fmt.Errorf("svc: unknown role %q", role))
+		}
+		if _, ok := seen[role]; ok {
+			return errors.Join(domain.ErrInvalidCapabilities, fmt.Errorf("svc: duplicate role %q", role))
+		}
+		seen[role] = struct{}{}
+	}
+
+	roles := append([]string(nil), capabilities.Roles...)
+	sort.Strings(roles)
+	capabilities.Roles = roles
+	return cs.capabilitiesRepo.UpsertCapabilities(ctx, capabilities)
+}
+
+func (cs *CapabilitiesService) GetCapabilities(ctx context.Context, deviceId string) (*domain.DeviceCapabilities, error) {
+	capabilities, err := cs.capabilitiesRepo.GetCapabilities(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	children, err := cs.capabilitiesRepo.ListGatewayChildCapabilities(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) > 0 {
+		aggregateCapabilities(capabilities, children)
+	}
+	return capabilities, nil
+}
+
+// aggregateCapabilities presents a gateway as one device (opaque gateway model): its identity
+// and roles are kept, while its resources become the sum of the resources its children
+// reported. Children with sizes that cannot be parsed are left out.
+func aggregateCapabilities(gateway *domain.DeviceCapabilities, children []domain.DeviceCapabilities) {
+	var cpuCores, memory, storage float64
+	for _, child := range children {
+		childMemory, err := parseQuantity(child.Memory)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"deviceId": child.DeviceId, "error": err}).Warn("svc: child capabilities left out of gateway aggregate")
+			continue
+		}
+		childStorage, err := parseQuantity(child.Storage)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"deviceId": child.DeviceId, "error": err}).Warn("svc: child capabilities left out of gateway aggregate")
+			continue
+		}
+		cpuCores += child.CpuCores
+		memory += childMemory
+		storage += childStorage
+		if child.ReportedAt.After(gateway.ReportedAt) {
+			gateway.ReportedAt = child.ReportedAt
+		}
+	}
+	gateway.CpuCores = cpuCores
+	gateway.Memory = formatQuantity(memory)
+	gateway.Storage = formatQuantity(storage)
+}
+
+// parseQuantity returns the number of bytes of a size such as "512Mi", "1.5G" or "1024".
+func parseQuantity(quantity string) (float64, error) {
+	number, multiplier := quantity, 1.0
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(quantity, s.suffix) {
+			number, multiplier = strings.TrimSuffix(quantity, s.suffix), s.bytes
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("svc: invalid quantity %q", quantity)
+	}
+	return value * multiplier, nil
+}
+
+// formatQuantity formats a number of bytes with the largest binary suffix it is a whole
+// multiple of.
+func formatQuantity(bytes float64) string {
+	bytes = math.Round(bytes)
+	for _, s := range quantitySuffixes {
+		if !strings.HasSuffix(s.suffix, "i") {
+			continue
+		}
+		if bytes >= s.bytes && math.Mod(bytes, s.bytes) == 0 {
+			return strconv.FormatFloat(bytes/s.bytes, 'f', -1, 64) + s.suffix
+		}
+	}
+	return strconv.FormatFloat(bytes, 'f', -1, 64)
+}
@@ -19,39 +19,35 @@ import (
 
 type DeploymentService struct {
 	deploymentRepo port.DeploymentRepository
+	notifier       port.ManifestNotifier
 	validate       *validator.Validate
+	render         bool // render the components of every deployment (move templating to the WFM)
 }
 
-func NewDeploymentService(deploymentRepo port.DeploymentRepository) *DeploymentService {
+func NewDeploymentService(deploymentRepo port.DeploymentRepository, notifier port.ManifestNotifier, render bool) *DeploymentService {
 	return &DeploymentService{
 		deploymentRepo: deploymentRepo,
-		validate:       validator.New(),
+		notifier:       notifier,
+		validate:       newDescriptorValidator(),
+		render:         render,
 	}
 }
 
 func (ds *DeploymentService) CreateDeployment(ctx context.Context, deviceId string, serializedDescriptor []byte) (*domain.ApplicationDeployment, error) {
-	var descriptor common.ApplicationDeploymentDescriptor
-	if err := yaml.Unmarshal(serializedDescriptor, &descriptor); err != nil {
-		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to unmarshal ApplicationDeployment YAML: %w", err))
-	}
-	if err := ds.validate.Struct(descriptor); err != nil {
-		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to validate ApplicationDeployment YAML: %w", err))
+	descriptor, err := parseDescriptor(ds.validate, serializedDescriptor)
+	if err != nil {
+		return nil, err
 	}
 
 	// The deployment ID is embedded in the descriptor. Hence, we need to patch it in the
 	// descriptor and serialize the descriptor with the changed deployment ID.
 	descriptor.Metadata.Annotations.Id = uuid.New().String()
-	rendered, err := yaml.Marshal(descriptor)
+	applicationDeployment, err := newApplicationDeployment(descriptor, ds.render)
 	if err != nil {
-		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to marshal ApplicationDeployment YAML: %w", err))
+		return nil, err
 	}
 
 	// Persist the deployment and update the bundle
-	applicationDeployment := domain.ApplicationDeployment{
-		Id:               descriptor.Metadata.Annotations.Id,
-		Descriptor:       rendered,
-		DescriptorDigest: common.CalculateDigest(rendered),
-	}
 	err = ds.deploymentRepo.UpsertDeployments(ctx, deviceId, func(manifest *domain.ApplicationDeploymentManifest) error {
 		// Add the deployment to the device's manifest
 		manifest.Deployments = append(manifest.Deployments, applicationDeployment)
@@ -66,29 +62,24 @@ func (ds *DeploymentService) CreateDeployment(ctx context.Context, deviceId stri
 }
 
 func (ds *DeploymentService) UpdateDeployment(ctx context.Context, deviceId, deploymentId string, serializedDescriptor []byte) (*domain.ApplicationDeployment, error) {
-	var descriptor common.ApplicationDeploymentDescriptor
-	if err := yaml.Unmarshal(serializedDescriptor, &descriptor); err != nil {
-		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to unmarshal ApplicationDeployment YAML: %w", err))
-	}
-	if err := ds.validate.Struct(descriptor); err != nil {
-		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to validate ApplicationDeployment YAML: %w", err))
+	descriptor, err := parseDescriptor(ds.validate, serializedDescriptor)
+	if err != nil {
+		return nil, err
 	}
 	if descriptor.Metadata.Annotations.Id != "" && descriptor.Metadata.Annotations.Id != deploymentId {
 		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: descriptor deployment ID %q does not match path deployment ID %q", descriptor.Metadata.Annotations.Id, deploymentId))
 	}
 	descriptor.Metadata.Annotations.Id = deploymentId
-	rendered, err := yaml.Marshal(descriptor)
+	updatedDeployment, err := newApplicationDeployment(descriptor, ds.render)
 	if err != nil {
-		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to marshal ApplicationDeployment YAML: %w", err))
-	}
-	updatedDeployment := domain.ApplicationDeployment{
-		Id:               deploymentId,
-		Descriptor:       rendered,
-		DescriptorDigest: common.CalculateDigest(rendered),
+		return nil, err
 	}
 	err = ds.deploymentRepo.UpsertDeployments(ctx, deviceId, func(manifest *domain.ApplicationDeploymentManifest) error {
 		for i := range manifest.Deployments {
 			if manifest.Deployments[i].Id == deploymentId {
+				if manifest.Deployments[i].FleetDeploymentId != "" {
+					return domain.ErrFleetManagedDeployment
+				}
 				manifest.Deployments[i] = updatedDeployment
 				return rebuildManifestBundle(manifest)
 			}
@@ -102,6 +93,12 @@ func (ds *DeploymentService) UpdateDeployment(ctx context.Context, deviceId, dep
 	return &updatedDeployment, nil
 }
 
+// ValidateDeployment returns all violations of a descriptor without storing it.
+func (ds *DeploymentService) ValidateDeployment(ctx context.Context, serializedDescriptor []byte) ([]domain.DescriptorViolation, error) {
+	_, violations := validateDescriptor(ds.validate, serializedDescriptor)
+	return violations, nil
+}
+
 func (ds *DeploymentService) DeleteDeployment(ctx context.Context, deviceId, deploymentId string) error {
 	return ds.deploymentRepo.UpsertDeployments(ctx, deviceId, func(manifest *domain.ApplicationDeploymentManifest) error {
 		idx := -1
@@ -114,6 +111,9 @@ func (ds *DeploymentService) DeleteDeployment(ctx context.Context, deviceId, dep
 		if idx == -1 {
 			return domain.ErrDeploymentNotFound
 		}
+		if manifest.Deployments[idx].FleetDeploymentId != "" {
+			return domain.ErrFleetManagedDeployment
+		}
 		manifest.Deployments = append(manifest.Deployments[:idx], manifest.Deployments[idx+1:]...)
 		return rebuildManifestBundle(manifest)
 	})
@@ -123,6 +123,10 @@ func (ds *DeploymentService) GetDeploymentManifest(ctx context.Context, deviceId
 	return ds.deploymentRepo.GetDeploymentManifest(ctx, deviceId)
 }
 
+func (ds *DeploymentService) ManifestChanged(deviceId string) <-chan struct{} {
+	return ds.notifier.Changed(deviceId)
+}
+
 func (ds *DeploymentService) GetDeployment(ctx context.Context, deviceId, deploymentId, digest string) (*domain.ApplicationDeployment, error) {
 	return ds.deploymentRepo.GetDeployment(ctx, deviceId, deploymentId, digest)
 }
@@ -138,6 +142,31 @@ func (ds *DeploymentService) GetBundle(ctx context.Context, deviceId, expectedDi
 	return archive, nil
 }
 
+func (ds *DeploymentService) GetRenderedComponent(ctx context.Context, deviceId, deploymentId, name, digest string) (*domain.RenderedComponent, error) {
+	return ds.deploymentRepo.GetRenderedComponent(ctx, deviceId, deploymentId, name, digest)
+}
+
+// newApplicationDeployment serializes a descriptor, whose deployment ID has been set, and
+// renders its components if enabled.
+func newApplicationDeployment(descriptor common.ApplicationDeploymentDescriptor, render bool) (domain.ApplicationDeployment, error) {
+	serialized, err := yaml.Marshal(descriptor)
+	if err != nil {
+		return domain.ApplicationDeployment{}, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to marshal ApplicationDeployment YAML: %w", err))
+	}
+	deployment := domain.ApplicationDeployment{
+		Id:               descriptor.Metadata.Annotations.Id,
+		Descriptor:       serialized,
+		DescriptorDigest: common.CalculateDigest(serialized),
+		DescriptorSize:   uint64(len(serialized)),
+	}
+	if render {
+		if deployment.Components, err = renderComponents(descriptor); err != nil {
+			return domain.ApplicationDeployment{}, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: failed to render components: %w", err))
+		}
+	}
+	return deployment, nil
+}
+
 type file struct {
 	Name    string
 	Content []byte
@@ -172,11 +201,24 @@ func createBundleArchive(files []file) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// AggregateManifest is the port.ManifestAggregateFunc of the repositories: it rebuilds the
+// aggregated manifest of a gateway like that of any other device.
+func AggregateManifest(manifest *domain.ApplicationDeploymentManifest) error {
+	return rebuildManifestBundle(manifest)
+}
+
+// rebuildManifestBundle bundles the descriptors of the manifest and increments its version
+// when the bundle changed. In the aggregated manifest of a gateway the descriptors are
+// grouped by target device, since fleet deployments share their ID across devices.
 func rebuildManifestBundle(manifest *domain.ApplicationDeploymentManifest) error {
 	files := make([]file, 0, len(manifest.Deployments))
 	for _, deployment := range manifest.Deployments {
+		name := fmt.Sprintf("%s.yaml", deployment.Id)
+		if deployment.Target != "" {
+			name = fmt.Sprintf("%s/%s", deployment.Target, name)
+		}
 		files = append(files, file{
-			Name:    fmt.Sprintf("%s.yaml", deployment.Id),
+			Name:    name,
 			Content: deployment.Descriptor,
 		})
 	}
@@ -185,6 +227,7 @@ func rebuildManifestBundle(manifest *domain.ApplicationDeploymentManifest) error
 	if len(files) == 0 {
 		manifest.BundleArchive = nil
 		manifest.BundleDigest = ""
+		manifest.BundleSize = 0
 	} else {
 		archive, err := createBundleArchive(files)
 		if err != nil {
@@ -192,10 +235,11 @@ func rebuildManifestBundle(manifest *domain.ApplicationDeploymentManifest) error
 		}
 		manifest.BundleArchive = archive
 		manifest.BundleDigest = common.CalculateDigest(archive)
+		manifest.BundleSize = uint64(len(archive))
 	}
 
 	if manifest.BundleDigest == previousDigest {
-		logrus.WithField("deployments", len(manifest.Deployments)).Info("svc: manifest bundle unchanged; skipping version increment")
+		logrus.WithField("deployments", len(manifest.Deployments)).Debug("svc: manifest bundle unchanged; skipping version increment")
 		return nil
 	}
 
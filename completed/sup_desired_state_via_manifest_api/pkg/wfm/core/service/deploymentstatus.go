@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+)
+
+const (
+	defaultStatusHistoryLimit = 20
+	maxStatusHistoryLimit     = 500
+	maxStatusErrorLength      = 1024
+	maxComponentStatuses      = 256
+)
+
+var (
+	statusDigestRe = regexp.MustCompile(`^([a-z0-9_\-]+:[0-9a-f]{64})?$`)
+
+	deploymentStates = map[string]struct{}{
+		domain.DeploymentStatePending:    {},
+		domain.DeploymentStateInstalling: {},
+		domain.DeploymentStateInstalled:  {},
+		domain.DeploymentStateFailed:     {},
+		domain.DeploymentStateRemoved:    {},
+	}
+)
+
+type DeploymentStatusService struct {
+	statusRepo port.DeploymentStatusRepository
+}
+
+func NewDeploymentStatusService(statusRepo port.DeploymentStatusRepository) *DeploymentStatusService {
+	return &DeploymentStatusService{
+		statusRepo: statusRepo,
+	}
+}
+
+func (dss *DeploymentStatusService) ReportDeploymentStatus(ctx context.Context, status domain.DeploymentStatus) error {
+	if status.DeploymentId == "" || status.ApiVersion == "" {
+		return errors.Join(domain.ErrInvalidDeploymentStatus, errors.New("svc: deployment ID and apiVersion are required"))
+	}
+	if !statusDigestRe.MatchString(status.Digest) {
+		return errors.Join(domain.ErrInvalidDeploymentStatus, fmt.Errorf("svc: invalid digest %q", status.Digest))
+	}
+	if err := validateStatus("deployment", status.State, status.Error); err != nil {
+		return err
+	}
+	if len(status.Components) > maxComponentStatuses {
+		return errors.Join(domain.ErrInvalidDeploymentStatus, fmt.Errorf("svc: at most %d component statuses allowed", maxComponentStatuses))
+	}
+	seen := make(map[string]struct{}, len(status.Components))
+	for _, component := range status.Components {
+		if component.Name == "" {
+			return errors.Join(domain.ErrInvalidDeploymentStatus, errors.New("svc: component name is required"))
+		}
+		if _, ok := seen[component.Name]; ok {
+			return errors.Join(domain.ErrInvalidDeploymentStatus, fmt.Errorf("svc: duplicate component %q", component.Name))
+		}
+		seen[component.Name] = struct{}{}
+		if err := validateStatus(fmt.Sprintf("component %q", component.Name), component.State, component.Error); err != nil {
+			return err
+		}
+	}
+
+	return dss.statusRepo.InsertDeploymentStatus(ctx, status)
+}
+
+// ListDeploymentStatuses returns the status history of a deployment, most recent first.
+func (dss *DeploymentStatusService) ListDeploymentStatuses(ctx context.Context, deviceId, deploymentId string, limit int) ([]domain.DeploymentStatus, error) {
+	if limit <= 0 {
+		limit = defaultStatusHistoryLimit
+	}
+	if limit > maxStatusHistoryLimit {
+		limit = maxStatusHistoryLimit
+	}
+	return dss.statusRepo.ListDeploymentStatuses(ctx, deviceId, deploymentId, limit)
+}
+
+func validateStatus(subject, state string, statusError *domain.StatusError) error {
+	if _, ok := deploymentStates[state]; !ok {
+		return errors.Join(domain.ErrInvalidDeploymentStatus, fmt.Errorf("svc: %s has unknown state %q", subject, state))
+	}
+	if statusError != nil && (len(statusError.Code) > maxStatusErrorLength || len(statusError.Message) > maxStatusErrorLength) {
+		return errors.Join(domain.ErrInvalidDeploymentStatus, fmt.Errorf("svc: %s error exceeds %d characters", subject, maxStatusErrorLength))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDeviceListLimit = 100
+	maxDeviceListLimit     = 1000
+	maxDisplayNameLength   = 256
+)
+
+var (
+	// device IDs are used as URL path segments
+	deviceIdRe   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:\-]{0,127}$`)
+	labelKeyRe   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/\-]{0,61}[A-Za-z0-9])?$`)
+	labelValueRe = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._\-]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+type DeviceService struct {
+	deviceRepo       port.DeviceRepository
+	fleetDeployments port.FleetDeploymentService // rolls fleet deployments out to devices whose labels changed
+}
+
+func NewDeviceService(deviceRepo port.DeviceRepository, fleetDeployments port.FleetDeploymentService) *DeviceService {
+	return &DeviceService{
+		deviceRepo:       deviceRepo,
+		fleetDeployments: fleetDeployments,
+	}
+}
+
+func (ds *DeviceService) CreateDevice(ctx context.Context, device domain.Device) (*domain.Device, error) {
+	if device.Id == "" {
+		device.Id = uuid.New().String()
+	}
+	if !deviceIdRe.MatchString(device.Id) {
+		return nil, errors.Join(domain.ErrInvalidDevice, fmt.Errorf("svc: invalid device ID %q", device.Id))
+	}
+	if err := validateDisplayName(device.DisplayName); err != nil {
+		return nil, err
+	}
+	if err := validateLabels(device.Labels); err != nil {
+		return nil, err
+	}
+	if err := validateGateway(device.Id, device.GatewayId); err != nil {
+		return nil, err
+	}
+
+	// Labels may select the device for fleet deployments
+	var syncFn port.FleetSyncFunc
+	if len(device.Labels) > 0 {
+		syncFn = ds.fleetDeployments.SyncFunc()
+	}
+	if err := ds.deviceRepo.CreateDevice(ctx, &device, syncFn); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// ListDevices returns a page of devices ordered by ID, starting after cursor. The returned
+// cursor is empty on the last page.
+func (ds *DeviceService) ListDevices(ctx context.Context, cursor string, limit int) ([]domain.Device, string, error) {
+	if limit <= 0 {
+		limit = defaultDeviceListLimit
+	}
+	if limit > maxDeviceListLimit {
+		limit = maxDeviceListLimit
+	}
+
+	// fetch one extra device to learn whether another page exists
+	devices, err := ds.deviceRepo.ListDevices(ctx, cursor, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(devices) <= limit {
+		return devices, "", nil
+	}
+	devices = devices[:limit]
+	return devices, devices[limit-1].Id, nil
+}
+
+func (ds *DeviceService) GetDevice(ctx context.Context, deviceId string) (*domain.Device, error) {
+	return ds.deviceRepo.GetDevice(ctx, deviceId)
+}
+
+func (ds *DeviceService) UpdateDevice(ctx context.Context, deviceId string, update domain.DeviceUpdate) (*domain.Device, error) {
+	if update.DisplayName != nil {
+		if err := validateDisplayName(*update.DisplayName); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateLabels(update.Labels); err != nil {
+		return nil, err
+	}
+	if update.GatewayId != nil {
+		if err := validateGateway(deviceId, *update.GatewayId); err != nil {
+			return nil, err
+		}
+	}
+
+	// Label changes may move the device into or out of fleet deployments; the device is
+	// synced along with the update
+	var syncFn port.FleetSyncFunc
+	if update.Labels != nil {
+		syncFn = ds.fleetDeployments.SyncFunc()
+	}
+	return ds.deviceRepo.UpdateDevice(ctx, deviceId, func(device *domain.Device) error {
+		if update.DisplayName != nil {
+			device.DisplayName = *update.DisplayName
+		}
+		if update.Labels != nil {
+			device.Labels = update.Labels
+		}
+		if update.GatewayId != nil {
+			device.GatewayId = *update.GatewayId
+		}
+		return nil
+	}, syncFn)
+}
+
+// DecommissionDevice removes the device from the registry. Its deployments and manifest
+// are removed along with it; a device registered again under the same ID continues after
+// the last manifest version of the decommissioned one.
+func (ds *DeviceService) DecommissionDevice(ctx context.Context, deviceId string) error {
+	return ds.deviceRepo.DeleteDevice(ctx, deviceId)
+}
+
+func validateDisplayName(displayName string) error {
+	if len(displayName) > maxDisplayNameLength {
+		return errors.Join(domain.ErrInvalidDevice, fmt.Errorf("svc: display name exceeds %d characters", maxDisplayNameLength))
+	}
+	return nil
+}
+
+// validateGateway checks the gateway ID of a device; whether the gateway exists and may
+// serve the device is checked by the repository.
+func validateGateway(deviceId, gatewayId string) error {
+	if gatewayId == "" {
+		return nil
+	}
+	if !deviceIdRe.MatchString(gatewayId) {
+		return errors.Join(domain.ErrInvalidDevice, fmt.Errorf("svc: invalid gateway ID %q", gatewayId))
+	}
+	if gatewayId == deviceId {
+		return errors.Join(domain.ErrInvalidDevice, errors.New("svc: a device cannot be its own gateway"))
+	}
+	return nil
+}
+
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelKeyRe.MatchString(key) {
+			return errors.Join(domain.ErrInvalidDevice, fmt.Errorf("svc: invalid label key %q", key))
+		}
+		if !labelValueRe.MatchString(value) {
+			return errors.Join(domain.ErrInvalidDevice, fmt.Errorf("svc: invalid value %q for label %q", value, key))
+		}
+	}
+	return nil
+}
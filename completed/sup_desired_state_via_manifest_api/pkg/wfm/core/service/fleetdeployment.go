@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"slices"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+type FleetDeploymentService struct {
+	fleetRepo port.FleetDeploymentRepository
+	validate  *validator.Validate
+	render    bool // render the components of every deployment (move templating to the WFM)
+}
+
+func NewFleetDeploymentService(fleetRepo port.FleetDeploymentRepository, render bool) *FleetDeploymentService {
+	return &FleetDeploymentService{
+		fleetRepo: fleetRepo,
+		validate:  newDescriptorValidator(),
+		render:    render,
+	}
+}
+
+func (fs *FleetDeploymentService) CreateFleetDeployment(ctx context.Context, selector domain.DeviceSelector, serializedDescriptor []byte) (*domain.FleetDeployment, error) {
+	if err := validateSelector(selector); err != nil {
+		return nil, err
+	}
+	descriptor, err := parseDescriptor(fs.validate, serializedDescriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every device carries the deployment under the ID of the fleet deployment
+	descriptor.Metadata.Annotations.Id = uuid.New().String()
+	deployment, err := newApplicationDeployment(descriptor, fs.render)
+	if err != nil {
+		return nil, err
+	}
+
+	fleet := domain.FleetDeployment{
+		Id:               deployment.Id,
+		Selector:         selector,
+		Descriptor:       deployment.Descriptor,
+		DescriptorDigest: deployment.DescriptorDigest,
+	}
+	if err = fs.fleetRepo.CreateFleetDeployment(ctx, &fleet, fs.newSyncFunc()); err != nil {
+		return nil, err
+	}
+	return &fleet, nil
+}
+
+// UpdateFleetDeployment replaces the selector and descriptor of a fleet deployment. Devices
+// no longer selected drop the deployment, newly selected devices receive it.
+func (fs *FleetDeploymentService) UpdateFleetDeployment(ctx context.Context, fleetId string, selector domain.DeviceSelector, serializedDescriptor []byte) (*domain.FleetDeployment, error) {
+	if err := validateSelector(selector); err != nil {
+		return nil, err
+	}
+	descriptor, err := parseDescriptor(fs.validate, serializedDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	if descriptor.Metadata.Annotations.Id != "" && descriptor.Metadata.Annotations.Id != fleetId {
+		return nil, errors.Join(domain.ErrInvalidDeploymentDescriptor, fmt.Errorf("svc: descriptor deployment ID %q does not match fleet deployment ID %q", descriptor.Metadata.Annotations.Id, fleetId))
+	}
+	descriptor.Metadata.Annotations.Id = fleetId
+	deployment, err := newApplicationDeployment(descriptor, fs.render)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.fleetRepo.UpdateFleetDeployment(ctx, fleetId, func(fleet *domain.FleetDeployment) error {
+		fleet.Selector = selector
+		fleet.Descriptor = deployment.Descriptor
+		fleet.DescriptorDigest = deployment.DescriptorDigest
+		return nil
+	}, fs.newSyncFunc())
+}
+
+// DeleteFleetDeployment removes the fleet deployment from all devices carrying it.
+func (fs *FleetDeploymentService) DeleteFleetDeployment(ctx context.Context, fleetId string) error {
+	return fs.fleetRepo.DeleteFleetDeployment(ctx, fleetId, fs.newSyncFunc())
+}
+
+func (fs *FleetDeploymentService) ListFleetDeployments(ctx context.Context) ([]domain.FleetDeployment, error) {
+	return fs.fleetRepo.ListFleetDeployments(ctx)
+}
+
+func (fs *FleetDeploymentService) GetFleetDeployment(ctx context.Context, fleetId string) (*domain.FleetDeployment, error) {
+	return fs.fleetRepo.GetFleetDeployment(ctx, fleetId)
+}
+
+func (fs *FleetDeploymentService) SyncFunc() port.FleetSyncFunc {
+	return fs.newSyncFunc()
+}
+
+// newSyncFunc returns a port.FleetSyncFunc that makes the fleet-managed deployments of a
+// device match the fleet deployments selecting it. Deployments created for the device alone
+// are left untouched. The manifest version is only bumped when the deployments changed.
+func (fs *FleetDeploymentService) newSyncFunc() port.FleetSyncFunc {
+	// the same fleet deployments are rolled out to many devices; build each deployment once
+	built := map[string]domain.ApplicationDeployment{}
+
+	return func(device domain.Device, fleets []domain.FleetDeployment, manifest *domain.ApplicationDeploymentManifest) error {
+		desired := map[string]domain.ApplicationDeployment{}
+		for _, fleet := range fleets {
+			if !fleet.Selector.Matches(device) {
+				continue
+			}
+			deployment, ok := built[fleet.DescriptorDigest]
+			if !ok {
+				var err error
+				if deployment, err = fs.fleetApplicationDeployment(fleet); err != nil {
+					return err
+				}
+				built[fleet.DescriptorDigest] = deployment
+			}
+			desired[fleet.Id] = deployment
+		}
+
+		changed := false
+		deployments := make([]domain.ApplicationDeployment, 0, len(manifest.Deployments)+len(desired))
+		for _, deployment := range manifest.Deployments {
+			if deployment.FleetDeploymentId == "" {
+				deployments = append(deployments, deployment)
+				continue
+			}
+			want, ok := desired[deployment.FleetDeploymentId]
+			if !ok {
+				changed = true
+				continue
+			}
+			delete(desired, deployment.FleetDeploymentId)
+			if want.DescriptorDigest != deployment.DescriptorDigest || !sameComponents(want.Components, deployment.Components) {
+				changed = true
+			}
+			deployments = append(deployments, want)
+		}
+		for _, fleet := range fleets {
+			if want, ok := desired[fleet.Id]; ok {
+				deployments = append(deployments, want)
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		manifest.Deployments = deployments
+		return rebuildManifestBundle(manifest)
+	}
+}
+
+// fleetApplicationDeployment returns the per-device deployment of a fleet deployment. The
+// stored descriptor already carries the fleet deployment ID.
+func (fs *FleetDeploymentService) fleetApplicationDeployment(fleet domain.FleetDeployment) (domain.ApplicationDeployment, error) {
+	deployment := domain.ApplicationDeployment{
+		Id:                fleet.Id,
+		Descriptor:        fleet.Descriptor,
+		DescriptorDigest:  fleet.DescriptorDigest,
+		DescriptorSize:    uint64(len(fleet.Descriptor)),
+		FleetDeploymentId: fleet.Id,
+	}
+	if fs.render {
+		var descriptor common.ApplicationDeploymentDescriptor
+		if err := yaml.Unmarshal(fleet.Descriptor, &descriptor); err != nil {
+			return domain.ApplicationDeployment{}, errors.Join(domain.ErrInternal, fmt.Errorf("svc: failed to parse descriptor of fleet deployment %s: %w", fleet.Id, err))
+		}
+		var err error
+		if deployment.Components, err = renderComponents(descriptor); err != nil {
+			return domain.ApplicationDeployment{}, errors.Join(domain.ErrInternal, fmt.Errorf("svc: failed to render fleet deployment %s: %w", fleet.Id, err))
+		}
+	}
+	return deployment, nil
+}
+
+func sameComponents(a, b []domain.RenderedComponent) bool {
+	return slices.EqualFunc(a, b, func(x, y domain.RenderedComponent) bool {
+		return x.Name == y.Name && x.Digest == y.Digest
+	})
+}
+
+// validateSelector rejects selectors matching no device, which would silently deploy nothing,
+// and labels that devices cannot carry.
+func validateSelector(selector domain.DeviceSelector) error {
+	if selector.IsEmpty() {
+		return errors.Join(domain.ErrInvalidFleetDeployment, errors.New("svc: selector requires matchLabels or a group"))
+	}
+	if selector.Group != "" && !labelValueRe.MatchString(selector.Group) {
+		return errors.Join(domain.ErrInvalidFleetDeployment, fmt.Errorf("svc: invalid group %q", selector.Group))
+	}
+	for key, value := range selector.MatchLabels {
+		if !labelKeyRe.MatchString(key) {
+			return errors.Join(domain.ErrInvalidFleetDeployment, fmt.Errorf("svc: invalid label key %q", key))
+		}
+		if !labelValueRe.MatchString(value) {
+			return errors.Join(domain.ErrInvalidFleetDeployment, fmt.Errorf("svc: invalid value %q for label %q", value, key))
+		}
+	}
+	return nil
+}
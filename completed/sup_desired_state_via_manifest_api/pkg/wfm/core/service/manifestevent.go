@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+)
+
+type ManifestEventService struct {
+	eventRepo port.ManifestEventRepository
+	notifier  port.ManifestNotifier
+}
+
+func NewManifestEventService(eventRepo port.ManifestEventRepository, notifier port.ManifestNotifier) *ManifestEventService {
+	return &ManifestEventService{
+		eventRepo: eventRepo,
+		notifier:  notifier,
+	}
+}
+
+func (es *ManifestEventService) ListManifestEvents(ctx context.Context, after int64, filter domain.ManifestEventFilter, limit int) ([]domain.ManifestEvent, int64, error) {
+	events, err := es.eventRepo.ListManifestEvents(ctx, after, limit)
+	if err != nil {
+		return nil, after, err
+	}
+	matching := make([]domain.ManifestEvent, 0, len(events))
+	for _, event := range events {
+		if filter.Matches(event) {
+			matching = append(matching, event)
+		}
+		after = event.Id
+	}
+	return matching, after, nil
+}
+
+func (es *ManifestEventService) GetLatestManifestEventId(ctx context.Context) (int64, error) {
+	return es.eventRepo.GetLatestManifestEventId(ctx)
+}
+
+func (es *ManifestEventService) ValidateFilter(filter domain.ManifestEventFilter) error {
+	for _, deviceId := range filter.DeviceIds {
+		if !deviceIdRe.MatchString(deviceId) {
+			return errors.Join(domain.ErrInvalidManifestEventFilter, fmt.Errorf("svc: invalid device ID %q", deviceId))
+		}
+	}
+	for key, value := range filter.Labels {
+		if !labelKeyRe.MatchString(key) {
+			return errors.Join(domain.ErrInvalidManifestEventFilter, fmt.Errorf("svc: invalid label key %q", key))
+		}
+		if !labelValueRe.MatchString(value) {
+			return errors.Join(domain.ErrInvalidManifestEventFilter, fmt.Errorf("svc: invalid value of label %q", key))
+		}
+	}
+	return nil
+}
+
+// EventsPublished relies on the manifest notifier: every manifest publication notifies the
+// device after commit.
+func (es *ManifestEventService) EventsPublished() <-chan struct{} {
+	return es.notifier.AnyChanged()
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultManifestHistoryLimit = 20
+	maxManifestHistoryLimit     = 100
+)
+
+type ManifestHistoryService struct {
+	historyRepo    port.ManifestHistoryRepository
+	deploymentRepo port.DeploymentRepository
+}
+
+func NewManifestHistoryService(historyRepo port.ManifestHistoryRepository, deploymentRepo port.DeploymentRepository) *ManifestHistoryService {
+	return &ManifestHistoryService{
+		historyRepo:    historyRepo,
+		deploymentRepo: deploymentRepo,
+	}
+}
+
+// ListPublishedManifests returns the manifest history of a device, most recent first. With
+// query.At set, the first entry is the manifest the device was given at that time.
+func (hs *ManifestHistoryService) ListPublishedManifests(ctx context.Context, deviceId string, query domain.ManifestHistoryQuery) ([]domain.PublishedManifest, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultManifestHistoryLimit
+	}
+	if query.Limit > maxManifestHistoryLimit {
+		query.Limit = maxManifestHistoryLimit
+	}
+	return hs.historyRepo.ListPublishedManifests(ctx, deviceId, query)
+}
+
+func (hs *ManifestHistoryService) GetPublishedManifest(ctx context.Context, deviceId string, version uint64) (*domain.PublishedManifest, error) {
+	return hs.historyRepo.GetPublishedManifest(ctx, deviceId, version)
+}
+
+// RollbackManifest restores the deployments of a published manifest version. The restored set
+// is published as the next manifest version, so clients never see the version go backwards;
+// nothing is published when it equals the current set. Fleet deployments are left as they
+// are, since their selectors decide which devices run them. Rolling a gateway back to an
+// aggregated manifest restores the deployments of the gateway itself, not of its children;
+// the returned manifest is the one the gateway is served, that is its aggregate.
+func (hs *ManifestHistoryService) RollbackManifest(ctx context.Context, deviceId string, version uint64) (*domain.ApplicationDeploymentManifest, error) {
+	// Published deployments never change, so they are read ahead of the update
+	published, err := hs.historyRepo.GetPublishedDeployments(ctx, deviceId, version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = hs.deploymentRepo.UpsertDeployments(ctx, deviceId, func(manifest *domain.ApplicationDeploymentManifest) error {
+		deployments := make([]domain.ApplicationDeployment, 0, len(published)+len(manifest.Deployments))
+		for _, deployment := range manifest.Deployments {
+			if deployment.FleetDeploymentId != "" {
+				deployments = append(deployments, deployment)
+			}
+		}
+		for _, deployment := range published {
+			if deployment.FleetDeploymentId == "" && (deployment.Target == "" || deployment.Target == deviceId) {
+				deployment.Target = ""
+				deployments = append(deployments, deployment)
+			}
+		}
+		// Keep the order the repository loads deployments in, so that the bundle is stable
+		slices.SortFunc(deployments, func(a, b domain.ApplicationDeployment) int {
+			return strings.Compare(a.Id, b.Id)
+		})
+		manifest.Deployments = deployments
+		return rebuildManifestBundle(manifest)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hs.deploymentRepo.GetDeploymentManifest(ctx, deviceId)
+}
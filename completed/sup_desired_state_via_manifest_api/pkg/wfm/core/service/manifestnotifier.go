@@ -0,0 +1,52 @@
+package service
+
+import "sync"
+
+// ManifestNotifier is an in-process port.ManifestNotifier. Every device has at most one
+// pending channel, shared by all waiters and closed (then dropped) by the next Notify.
+type ManifestNotifier struct {
+	mu         sync.Mutex
+	changed    map[string]chan struct{}
+	anyChanged chan struct{}
+}
+
+func NewManifestNotifier() *ManifestNotifier {
+	return &ManifestNotifier{
+		changed: map[string]chan struct{}{},
+	}
+}
+
+func (mn *ManifestNotifier) Notify(deviceIds ...string) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	if mn.anyChanged != nil && len(deviceIds) > 0 {
+		close(mn.anyChanged)
+		mn.anyChanged = nil
+	}
+	for _, deviceId := range deviceIds {
+		if ch, ok := mn.changed[deviceId]; ok {
+			close(ch)
+			delete(mn.changed, deviceId)
+		}
+	}
+}
+
+func (mn *ManifestNotifier) Changed(deviceId string) <-chan struct{} {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	ch, ok := mn.changed[deviceId]
+	if !ok {
+		ch = make(chan struct{})
+		mn.changed[deviceId] = ch
+	}
+	return ch
+}
+
+func (mn *ManifestNotifier) AnyChanged() <-chan struct{} {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	if mn.anyChanged == nil {
+		mn.anyChanged = make(chan struct{})
+	}
+	return mn.anyChanged
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+	"skeleton/pkg/wfm/core/port"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// onboardingProofMaxAge bounds the difference between the signing time of an onboarding
+// proof and the server's clock, in both directions to allow for clock skew.
+const onboardingProofMaxAge = 5 * time.Minute
+
+type OnboardingService struct {
+	onboardingRepo  port.OnboardingRepository
+	rootCertificate []byte         // PEM served to devices, nil when not configured
+	clientCAs       *x509.CertPool // nil accepts any well-formed certificate (trust on first use)
+}
+
+func NewOnboardingService(onboardingRepo port.OnboardingRepository, rootCertificate []byte, clientCAs *x509.CertPool) *OnboardingService {
+	return &OnboardingService{
+		onboardingRepo:  onboardingRepo,
+		rootCertificate: rootCertificate,
+		clientCAs:       clientCAs,
+	}
+}
+
+func (obs *OnboardingService) GetRootCertificate(ctx context.Context) ([]byte, error) {
+	if len(obs.rootCertificate) == 0 {
+		return nil, domain.ErrRootCertificateNotFound
+	}
+	return obs.rootCertificate, nil
+}
+
+// OnboardDevice registers the device identified by the given PEM or DER encoded client
+// certificate and returns its client ID. Presenting the same certificate again returns
+// the existing client ID. Either case requires the proof that the caller holds the
+// certificate's private key.
+func (obs *OnboardingService) OnboardDevice(ctx context.Context, certificate []byte, proof domain.OnboardingProof) (string, bool, error) {
+	cert, err := parseCertificate(certificate)
+	if err != nil {
+		return "", false, errors.Join(domain.ErrInvalidCertificate, err)
+	}
+	if err := verifyOnboardingProof(cert, proof); err != nil {
+		return "", false, errors.Join(domain.ErrCertificateRejected, err)
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return "", false, errors.Join(domain.ErrCertificateRejected, fmt.Errorf("svc: certificate not valid at %s (validity %s - %s)", now.Format(time.RFC3339), cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339)))
+	}
+	if obs.clientCAs != nil {
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:     obs.clientCAs,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			return "", false, errors.Join(domain.ErrCertificateRejected, fmt.Errorf("svc: certificate not trusted: %w", err))
+		}
+	}
+
+	deviceId, created, err := obs.onboardingRepo.OnboardDevice(ctx, domain.DeviceCertificate{
+		Fingerprint: common.CalculateDigest(cert.Raw),
+		Certificate: cert.Raw,
+		Subject:     cert.Subject.String(),
+		NotAfter:    cert.NotAfter,
+	}, domain.Device{
+		Id:          uuid.New().String(),
+		DisplayName: cert.Subject.CommonName,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if created {
+		logrus.WithFields(logrus.Fields{
+			"deviceId": deviceId,
+			"subject":  cert.Subject.String(),
+		}).Info("svc: onboarded new device")
+	}
+	return deviceId, created, nil
+}
+
+func (obs *OnboardingService) GetDeviceIdByCertificate(ctx context.Context, certificate []byte) (string, error) {
+	return obs.onboardingRepo.GetDeviceIdByCertificateFingerprint(ctx, common.CalculateDigest(certificate))
+}
+
+// verifyOnboardingProof accepts a TLS client certificate equal to cert, whose private key
+// the handshake has proven, or otherwise a recent signature made with the key of cert.
+func verifyOnboardingProof(cert *x509.Certificate, proof domain.OnboardingProof) error {
+	if proof.PeerCertificate != nil && bytes.Equal(proof.PeerCertificate, cert.Raw) {
+		return nil
+	}
+	if len(proof.Signature) == 0 {
+		return errors.New("svc: no proof of possession: certificate differs from the TLS client certificate and is not signed")
+	}
+	if age := time.Since(proof.SignedAt); age > onboardingProofMaxAge || age < -onboardingProofMaxAge {
+		return fmt.Errorf("svc: proof of possession signed at %s is outside the accepted window of %s", proof.SignedAt.Format(time.RFC3339), onboardingProofMaxAge)
+	}
+	if err := common.VerifyOnboardingProof(cert, proof.SignedAt, proof.Signature); err != nil {
+		return fmt.Errorf("svc: %w", err)
+	}
+	return nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("svc: unexpected PEM block %q", block.Type)
+		}
+		data = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("svc: failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renderComponents resolves the parameters of a descriptor into one RenderedComponent
+// document per component, in descriptor order. Devices apply these documents as they are,
+// without templating of their own.
+func renderComponents(descriptor common.ApplicationDeploymentDescriptor) ([]domain.RenderedComponent, error) {
+	components := make([]domain.RenderedComponent, 0, len(descriptor.Spec.DeploymentProfile.Components))
+	seen := make(map[string]struct{}, len(descriptor.Spec.DeploymentProfile.Components))
+	for _, component := range descriptor.Spec.DeploymentProfile.Components {
+		if _, ok := seen[component.Name]; ok {
+			return nil, fmt.Errorf("duplicate component %q", component.Name)
+		}
+		seen[component.Name] = struct{}{}
+
+		values, err := common.ComponentValues(&descriptor, component.Name)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", component.Name, err)
+		}
+		artifact, err := yaml.Marshal(common.RenderedComponent{
+			ApiVersion: descriptor.ApiVersion,
+			Kind:       common.RenderedComponentKind,
+			Metadata: common.RenderedComponentMetadata{
+				DeploymentId: descriptor.Metadata.Annotations.Id,
+				Name:         component.Name,
+				Namespace:    descriptor.Metadata.Namespace,
+			},
+			Spec: common.RenderedComponentSpec{
+				Type:       descriptor.Spec.DeploymentProfile.Type,
+				Properties: component.Properties,
+				Values:     values,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("component %s: failed to marshal: %w", component.Name, err)
+		}
+		components = append(components, domain.RenderedComponent{
+			Name:     component.Name,
+			Artifact: artifact,
+			Digest:   common.CalculateDigest(artifact),
+			Size:     uint64(len(artifact)),
+		})
+	}
+	return components, nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"skeleton/pkg/common"
+	"skeleton/pkg/wfm/core/domain"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// pointerRe matches dot separated value paths with non-empty segments, e.g. settings.limits.cpu.
+var pointerRe = regexp.MustCompile(`^[^.\s]+(\.[^.\s]+)*$`)
+
+// newDescriptorValidator returns a validator that names fields by their YAML keys, so that
+// violations carry YAML paths.
+func newDescriptorValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate
+}
+
+// parseDescriptor unmarshals and validates a descriptor. All violations are returned as a
+// *domain.DescriptorValidationError joined with domain.ErrInvalidDeploymentDescriptor.
+func parseDescriptor(validate *validator.Validate, serializedDescriptor []byte) (common.ApplicationDeploymentDescriptor, error) {
+	descriptor, violations := validateDescriptor(validate, serializedDescriptor)
+	if len(violations) > 0 {
+		return descriptor, errors.Join(domain.ErrInvalidDeploymentDescriptor, &domain.DescriptorValidationError{Violations: violations})
+	}
+	return descriptor, nil
+}
+
+// validateDescriptor checks the structure of a descriptor and then its semantics: component
+// names are unique, parameter targets use well-formed pointers and name existing components,
+// and no two targets write the same (or an enclosing) value of a component.
+func validateDescriptor(validate *validator.Validate, serializedDescriptor []byte) (common.ApplicationDeploymentDescriptor, []domain.DescriptorViolation) {
+	var descriptor common.ApplicationDeploymentDescriptor
+	if err := yaml.Unmarshal(serializedDescriptor, &descriptor); err != nil {
+		return descriptor, []domain.DescriptorViolation{{Path: "", Message: fmt.Sprintf("invalid YAML: %v", err)}}
+	}
+
+	var violations []domain.DescriptorViolation
+	var validationErrors validator.ValidationErrors
+	if err := validate.Struct(descriptor); errors.As(err, &validationErrors) {
+		for _, fieldError := range validationErrors {
+			// strip the struct name from the namespace, e.g. ApplicationDeploymentDescriptor.metadata.name
+			_, path, _ := strings.Cut(fieldError.Namespace(), ".")
+			violations = append(violations, domain.DescriptorViolation{Path: path, Message: fieldErrorMessage(fieldError)})
+		}
+	} else if err != nil {
+		violations = append(violations, domain.DescriptorViolation{Path: "", Message: err.Error()})
+	}
+
+	// The semantic checks also catch some values the structural ones report, e.g. an empty
+	// pointer or a missing list of targets; each path is reported once, by the structural check
+	reported := make(map[string]struct{}, len(violations))
+	for _, violation := range violations {
+		reported[violation.Path] = struct{}{}
+	}
+	for _, violation := range semanticViolations(descriptor) {
+		if _, ok := reported[violation.Path]; !ok {
+			violations = append(violations, violation)
+		}
+	}
+	return descriptor, violations
+}
+
+func fieldErrorMessage(fieldError validator.FieldError) string {
+	if fieldError.Tag() == "required" {
+		return "is required"
+	}
+	return fmt.Sprintf("fails the %q rule", fieldError.Tag())
+}
+
+// valueWrite is a parameter target writing a value of a component.
+type valueWrite struct {
+	pointer string
+	path    string
+}
+
+func semanticViolations(descriptor common.ApplicationDeploymentDescriptor) []domain.DescriptorViolation {
+	var violations []domain.DescriptorViolation
+
+	components := make(map[string]struct{}, len(descriptor.Spec.DeploymentProfile.Components))
+	componentNames := make([]string, 0, len(descriptor.Spec.DeploymentProfile.Components))
+	for i, component := range descriptor.Spec.DeploymentProfile.Components {
+		if _, ok := components[component.Name]; ok {
+			violations = append(violations, domain.DescriptorViolation{
+				Path:    fmt.Sprintf("spec.deploymentProfile.components[%d].name", i),
+				Message: fmt.Sprintf("duplicate component %q", component.Name),
+			})
+			continue
+		}
+		components[component.Name] = struct{}{}
+		componentNames = append(componentNames, component.Name)
+	}
+
+	names := make([]string, 0, len(descriptor.Spec.Parameters))
+	for name := range descriptor.Spec.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writes := make(map[string][]valueWrite, len(componentNames)) // component -> values written
+	for _, name := range names {
+		param := descriptor.Spec.Parameters[name]
+		paramPath := "spec.parameters." + name
+		// The structural check only rejects a missing value, since values are not typed
+		if param.Value == "" {
+			violations = append(violations, domain.DescriptorViolation{Path: paramPath + ".value", Message: "is required"})
+		}
+		if len(param.Targets) == 0 {
+			violations = append(violations, domain.DescriptorViolation{Path: paramPath + ".targets", Message: "at least one target is required"})
+		}
+		for i, target := range param.Targets {
+			targetPath := fmt.Sprintf("%s.targets[%d]", paramPath, i)
+			if !pointerRe.MatchString(target.Pointer) {
+				violations = append(violations, domain.DescriptorViolation{
+					Path:    targetPath + ".pointer",
+					Message: fmt.Sprintf("malformed pointer %q, expected dot separated keys", target.Pointer),
+				})
+				continue
+			}
+
+			targeted := target.Components
+			if len(targeted) == 0 {
+				targeted = componentNames // a target without components applies to all of them
+			}
+			for j, component := range targeted {
+				if _, ok := components[component]; !ok {
+					violations = append(violations, domain.DescriptorViolation{
+						Path:    fmt.Sprintf("%s.components[%d]", targetPath, j),
+						Message: fmt.Sprintf("unknown component %q", component),
+					})
+					continue
+				}
+				write := valueWrite{pointer: target.Pointer, path: targetPath + ".pointer"}
+				for _, other := range writes[component] {
+					if pointersOverlap(other.pointer, write.pointer) {
+						violations = append(violations, domain.DescriptorViolation{
+							Path:    write.path,
+							Message: fmt.Sprintf("pointer %q of component %q conflicts with %s (%q)", write.pointer, component, other.path, other.pointer),
+						})
+					}
+				}
+				writes[component] = append(writes[component], write)
+			}
+		}
+	}
+	return violations
+}
+
+// pointersOverlap reports whether two pointers write the same value or one encloses the other.
+func pointersOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}